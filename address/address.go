@@ -0,0 +1,77 @@
+// Package address encodes, decodes and validates base58check blockchain addresses
+package address
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// MainNetVersion is the version byte of addresses of public keys
+	MainNetVersion = 0x00
+	// HashSize is the size of the RIPEMD-160 hash an address encodes
+	HashSize = ripemd160.Size
+)
+
+var (
+	// ErrChecksum is returned for an address whose checksum does not match
+	ErrChecksum = errors.New("address checksum mismatch")
+	// ErrFormat is returned for a string that is not a base58check encoded 20 byte hash
+	ErrFormat = errors.New("invalid address format")
+)
+
+// FromPublicKey returns the main network address of a public key
+func FromPublicKey(publicKey *ecdsa.PublicKey) string {
+	// 1 - Perform SHA-256 hashing on the public key
+	// 0b7c28c9b7290c98d7438e70b3d3f7c848fbd7d1dc194ff83f4f7cc9b1378e98
+	h := sha256.New()
+	h.Write(publicKey.X.Bytes())
+	h.Write(publicKey.Y.Bytes())
+
+	// 2 - Perform RIPEMD-160 hashing on the result of SHA-256
+	// f54a5851e9372b87810a8e60cdd2e7cfd80b6e31
+	r := ripemd160.New()
+	r.Write(h.Sum(nil))
+	return FromHash(r.Sum(nil), MainNetVersion)
+}
+
+// FromHash returns the address of a 20 byte hash with the version byte.
+// This is the Base58Check encoding of version + hash + the first 4 bytes of its double SHA-256.
+// 1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs
+func FromHash(hash []byte, version byte) string {
+	return base58.CheckEncode(hash, version)
+}
+
+// Decode returns the hash and the version byte of an address, verifying its checksum
+func Decode(address string) ([]byte, byte, error) {
+	hash, version, err := base58.CheckDecode(address)
+	switch {
+	case err == base58.ErrChecksum:
+		return nil, 0, ErrChecksum
+	case err != nil || len(hash) != HashSize:
+		return nil, 0, ErrFormat
+	}
+	return hash, version, nil
+}
+
+// Validate returns an error unless address is well formed and has one of the versions,
+// any version when none is given
+func Validate(address string, versions ...byte) error {
+	_, version, err := Decode(address)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	for _, v := range versions {
+		if v == version {
+			return nil
+		}
+	}
+	return errors.New("unexpected address version")
+}
@@ -0,0 +1,51 @@
+package block
+
+import "fmt"
+
+// AccountNonce is where the nonces of a sender stand: Confirmed is the highest nonce in the chain,
+// Pending the number of its pending transactions carrying one and Next the nonce to use next.
+type AccountNonce struct {
+	Address   string `json:"address"`
+	Next      uint64 `json:"nonce"`
+	Confirmed uint64 `json:"confirmed_nonce"`
+	Pending   int    `json:"pending"`
+}
+
+// NextNonce returns the AccountNonce of sender. Next is one more than the highest nonce sender used in
+// the chain or the pool, so a transaction with it never replaces a pending one. Blocks known only by their
+// headers are not searched.
+func (bc *Blockchain) NextNonce(sender string) AccountNonce {
+	idx, _ := bc.balanceIndex()
+	confirmed, _ := idx.sent(sender, [32]byte{})
+	bc.muxChain.RUnlock()
+
+	a := AccountNonce{Address: sender, Confirmed: confirmed}
+	highest := a.Confirmed
+	for _, t := range bc.mempool.Transactions() {
+		if t.senderBlockchainAddress != sender || t.nonce == 0 {
+			continue
+		}
+		a.Pending++
+		if t.nonce > highest {
+			highest = t.nonce
+		}
+	}
+	a.Next = highest + 1
+	return a
+}
+
+// checkReplay returns ErrDuplicateTransaction when t is already in the chain or, carrying a nonce, when
+// its sender used the nonce or a higher one there, so a signed transaction is accepted only once
+func (bc *Blockchain) checkReplay(t *Transaction) error {
+	idx, _ := bc.balanceIndex()
+	defer bc.muxChain.RUnlock()
+
+	confirmed, mined := idx.sent(t.senderBlockchainAddress, t.Hash())
+	if mined {
+		return fmt.Errorf("%w: already in the chain, sign it with a new nonce to pay again", ErrDuplicateTransaction)
+	}
+	if t.nonce != 0 && t.nonce <= confirmed {
+		return fmt.Errorf("%w: nonce %d already used, the next is %d", ErrDuplicateTransaction, t.nonce, confirmed+1)
+	}
+	return nil
+}
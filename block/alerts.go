@@ -0,0 +1,192 @@
+package block
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// AlertHistory is the number of recent double-spend alerts a node keeps
+const AlertHistory = 100
+
+const (
+	// AlertSourceMempool is the source of an alert for a transaction conflicting with a pending one
+	AlertSourceMempool = "mempool"
+	// AlertSourceBlock is the source of an alert for a block transaction conflicting with a pending one
+	AlertSourceBlock = "block"
+)
+
+// DoubleSpendAlert reports two transactions of the same sender and nonce that pay differently, so at
+// most one of them can be honored. Conflicting is the later one, seen in the pool or in the block at
+// Height. The node that detected it signs the alert, which its neighbors relay once.
+type DoubleSpendAlert struct {
+	Sender      string       `json:"sender"`
+	Nonce       uint64       `json:"nonce"`
+	Pending     *Transaction `json:"pending"`
+	Conflicting *Transaction `json:"conflicting"`
+	Source      string       `json:"source"`
+	Height      int          `json:"height,omitempty"`
+	DetectedAt  int64        `json:"detected_at"`
+	PublicKey   string       `json:"public_key"`
+	Signature   string       `json:"signature"`
+}
+
+// Hash returns the SHA-256 of the alert without its signature
+func (a *DoubleSpendAlert) Hash() [32]byte {
+	m, _ := json.Marshal(struct {
+		Sender      string       `json:"sender"`
+		Nonce       uint64       `json:"nonce"`
+		Pending     *Transaction `json:"pending"`
+		Conflicting *Transaction `json:"conflicting"`
+		Source      string       `json:"source"`
+		Height      int          `json:"height,omitempty"`
+		DetectedAt  int64        `json:"detected_at"`
+	}{a.Sender, a.Nonce, a.Pending, a.Conflicting, a.Source, a.Height, a.DetectedAt})
+	return sha256.Sum256(m)
+}
+
+// Sign signs the alert with key
+func (a *DoubleSpendAlert) Sign(key wallet.Signer) error {
+	publicKey, signature, err := signHash(key, a.Hash())
+	if err != nil {
+		return err
+	}
+	a.PublicKey, a.Signature = publicKey, signature
+	return nil
+}
+
+// Verify checks that the alert is signed and that its transactions do conflict
+func (a *DoubleSpendAlert) Verify() error {
+	if a.Pending == nil || a.Conflicting == nil {
+		return fmt.Errorf("%w: alert without both transactions", ErrInvalidTransaction)
+	}
+	if a.Pending.senderBlockchainAddress != a.Sender || a.Pending.nonce != a.Nonce || !conflicts(a.Pending, a.Conflicting) {
+		return fmt.Errorf("%w: alert transactions do not conflict", ErrInvalidTransaction)
+	}
+	if !verifyHash(a.PublicKey, a.Signature, a.Hash()) {
+		return fmt.Errorf("%w: alert", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// key identifies the double spend whoever detected it
+func (a *DoubleSpendAlert) key() string {
+	p, c := a.Pending.Hash(), a.Conflicting.Hash()
+	if string(c[:]) < string(p[:]) {
+		p, c = c, p
+	}
+	return fmt.Sprintf("%x%x", p, c)
+}
+
+// conflicts reports whether a and b are transactions of the same sender and nonce that pay differently.
+// A replacement only raising the fee is not a conflict.
+func conflicts(a *Transaction, b *Transaction) bool {
+	if a.nonce == 0 || a.senderBlockchainAddress != b.senderBlockchainAddress || a.nonce != b.nonce {
+		return false
+	}
+	return a.recipientBlockchainAddress != b.recipientBlockchainAddress || a.value != b.value ||
+		a.lockUntil != b.lockUntil || a.name != b.name || a.data != b.data
+}
+
+// alertStore keeps the recent alerts, newest last, and the keys of the alerts seen
+type alertStore struct {
+	alerts []*DoubleSpendAlert
+	seen   map[string]bool
+	mux    sync.Mutex
+}
+
+func newAlertStore() *alertStore {
+	return &alertStore{seen: make(map[string]bool)}
+}
+
+// add stores a, false when the same double spend was already reported
+func (s *alertStore) add(a *DoubleSpendAlert) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	k := a.key()
+	if s.seen[k] {
+		return false
+	}
+	s.seen[k] = true
+	s.alerts = append(s.alerts, a)
+	if len(s.alerts) > AlertHistory {
+		delete(s.seen, s.alerts[0].key())
+		s.alerts = s.alerts[1:]
+	}
+	return true
+}
+
+// Alerts returns the recent double-spend alerts, newest first
+func (bc *Blockchain) Alerts() []*DoubleSpendAlert {
+	s := bc.alerts
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	alerts := make([]*DoubleSpendAlert, 0, len(s.alerts))
+	for i := len(s.alerts) - 1; i >= 0; i-- {
+		alerts = append(alerts, s.alerts[i])
+	}
+	return alerts
+}
+
+// ReceiveAlert stores an alert sent by a neighbor and relays it to the neighbors the first time it is seen
+func (bc *Blockchain) ReceiveAlert(ctx context.Context, a *DoubleSpendAlert) error {
+	if err := a.Verify(); err != nil {
+		return err
+	}
+	if bc.alerts.add(a) {
+		bc.logger.Printf("WARNING: double spend of %s nonce %d reported by %.16s", a.Sender, a.Nonce, a.PublicKey)
+		bc.broadcastAlert(ctx, a)
+	}
+	return nil
+}
+
+// raiseAlert signs and stores an alert for the conflicting transaction and sends it to the neighbors in
+// the background, so it may be called holding mux
+func (bc *Blockchain) raiseAlert(pending *Transaction, conflicting *Transaction, source string, height int) {
+	a := &DoubleSpendAlert{
+		Sender:      pending.senderBlockchainAddress,
+		Nonce:       pending.nonce,
+		Pending:     pending,
+		Conflicting: conflicting,
+		Source:      source,
+		Height:      height,
+		DetectedAt:  bc.clock.Now().Unix(),
+	}
+	bc.muxAlertKey.Lock()
+	if bc.alertKey == nil {
+		bc.alertKey = wallet.NewWallet()
+	}
+	err := a.Sign(bc.alertKey)
+	bc.muxAlertKey.Unlock()
+	if err != nil {
+		bc.logger.Printf("ERROR: double spend alert: %v", err)
+		return
+	}
+	if !bc.alerts.add(a) {
+		return
+	}
+	bc.logger.Printf("WARNING: double spend of %s nonce %d in the %s", a.Sender, a.Nonce, source)
+	go bc.broadcastAlert(context.Background(), a)
+}
+
+// checkConflicts raises an alert for every transaction of the adopted blocks, the first at height from,
+// that conflicts with a pending transaction. The caller must hold mux.
+func (bc *Blockchain) checkConflicts(blocks []*Block, from int) {
+	for i, b := range blocks {
+		for _, t := range b.transactions {
+			if pending, ok := bc.mempool.findNonce(t.senderBlockchainAddress, t.nonce); ok && conflicts(pending, t) {
+				bc.raiseAlert(pending, t, AlertSourceBlock, from+i)
+			}
+		}
+	}
+}
+
+func (bc *Blockchain) broadcastAlert(ctx context.Context, a *DoubleSpendAlert) BroadcastResult {
+	return bc.broadcast(ctx, "double spend alert", func(ctx context.Context, n string) error {
+		return bc.transport.SendAlert(ctx, n, a)
+	})
+}
@@ -0,0 +1,107 @@
+package block
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// AnchorSender is the sender and recipient of document anchoring data transactions
+	AnchorSender = "THE BLOCKCHAIN ANCHOR"
+	// MaxPendingAnchors is the number of anchors the pool holds at most. Anchors pay no fee, so this
+	// limits how many a node accepts per block.
+	MaxPendingAnchors = 100
+)
+
+// AnchorProof is a proof that a document hash was included in a block
+type AnchorProof struct {
+	DocumentHash string        `json:"document_hash"`
+	BlockHash    string        `json:"block_hash"`
+	BlockIndex   int           `json:"block_index"`
+	Timestamp    int64         `json:"timestamp"`
+	MerkleRoot   string        `json:"merkle_root"`
+	MerklePath   []*MerkleNode `json:"merkle_path"`
+}
+
+func hexHash(h [32]byte) string {
+	return fmt.Sprintf("%x", h)
+}
+
+// ValidDocumentHash reports whether h is a hex encoded SHA-256 hash
+func ValidDocumentHash(h string) bool {
+	b, err := hex.DecodeString(h)
+	return err == nil && len(b) == 32
+}
+
+// NewAnchorTransaction returns a data Transaction carrying a document hash
+func NewAnchorTransaction(documentHash string) *Transaction {
+	t := NewTransaction(AnchorSender, AnchorSender, 0, 0)
+	t.data = documentHash
+	return t
+}
+
+// CreateAnchor adds a document anchoring transaction and sends it to the neighbors
+func (bc *Blockchain) CreateAnchor(ctx context.Context, documentHash string) (BroadcastResult, error) {
+	if err := bc.AddAnchor(documentHash); err != nil {
+		return BroadcastResult{}, err
+	}
+	sender := AnchorSender
+	var value float32
+	return bc.broadcastTransaction(ctx, &TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &sender,
+		Value:                      &value,
+		Data:                       &documentHash,
+	}), nil
+}
+
+// AddAnchor is create a document anchoring Transaction and add BlockChain struct. A document hash is anchored
+// once, and at most MaxPendingAnchors anchors are pending.
+func (bc *Blockchain) AddAnchor(documentHash string) error {
+	if !ValidDocumentHash(documentHash) {
+		return fmt.Errorf("%w: invalid document hash", ErrInvalidTransaction)
+	}
+	t := NewAnchorTransaction(documentHash).seal()
+	h := t.Hash()
+	idx, _ := bc.balanceIndex()
+	_, anchored := idx.find(h)
+	bc.muxChain.RUnlock()
+	if anchored {
+		return fmt.Errorf("%w: document hash already anchored", ErrDuplicateTransaction)
+	}
+	pending := 0
+	_, err := bc.mempool.add(t, func(p *Transaction) error {
+		if p.Hash() == h {
+			return fmt.Errorf("%w: document hash already pending", ErrDuplicateTransaction)
+		}
+		if p.senderBlockchainAddress == AnchorSender {
+			if pending++; pending >= MaxPendingAnchors {
+				return fmt.Errorf("%w: %d anchors already pending", ErrRateLimited, pending)
+			}
+		}
+		return nil
+	})
+	return bc.accepted(t, err)
+}
+
+// FindAnchor returns the inclusion proof of the first block anchoring documentHash
+func (bc *Blockchain) FindAnchor(documentHash string) (*AnchorProof, bool) {
+	chain, _ := bc.view()
+	for i, b := range chain {
+		for j, t := range b.transactions {
+			if t.senderBlockchainAddress != AnchorSender || t.data != documentHash {
+				continue
+			}
+			return &AnchorProof{
+				DocumentHash: documentHash,
+				BlockHash:    hexHash(b.Hash()),
+				BlockIndex:   i,
+				Timestamp:    b.timestamp,
+				MerkleRoot:   hexHash(b.MerkleRoot()),
+				MerklePath:   MerklePath(b.transactions, j),
+			}, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,272 @@
+package block
+
+// applyBalances adds the transfers of transactions to balances
+func applyBalances(balances map[string]float32, transactions []*Transaction) {
+	for _, t := range transactions {
+		balances[t.senderBlockchainAddress] -= t.value + t.fee
+		balances[t.recipientBlockchainAddress] += t.value
+	}
+}
+
+// posting is a change of the balance of an address by a transaction of the block at height, with the
+// balance after it, for the sender the nonce of the transaction, and the highest nonce of the address up
+// to it. The balance carried over from a Snapshot is a posting without a transaction at the height of the
+// last block of the snapshot.
+type posting struct {
+	height  int
+	txid    [32]byte
+	nonce   uint64
+	highest uint64
+	amount  float32
+	balance float32
+}
+
+// txLocation is where a transaction is in the chain: the height of its block and its index there
+type txLocation struct {
+	height int
+	index  int
+}
+
+// balanceIndex is the ledger of every address after a chain, its postings in chain order, and where every
+// transaction is. Balances and highest nonces are running values added in the order of the chain, so
+// appending and dropping blocks gives the same index as building it anew.
+type balanceIndex struct {
+	postings map[string][]posting
+	// locations are the locations of a txid, lowest first. The rewards of different blocks may share a txid.
+	locations map[[32]byte][]txLocation
+}
+
+// newBalanceIndex returns the balanceIndex after chain, starting from the balances of base, if not nil
+func newBalanceIndex(chain []*Block, base *Snapshot) *balanceIndex {
+	idx := &balanceIndex{postings: make(map[string][]posting), locations: make(map[[32]byte][]txLocation)}
+	height := 0
+	if base != nil {
+		for a, v := range base.Balances {
+			idx.postings[a] = []posting{{height: base.Height - 1, amount: v, balance: v}}
+		}
+		height = base.Height
+	}
+	for i := height; i < len(chain); i++ {
+		idx.apply(i, chain[i])
+	}
+	return idx
+}
+
+// post adds amount to the balance of address
+func (idx *balanceIndex) post(address string, height int, txid [32]byte, nonce uint64, amount float32) {
+	p := idx.postings[address]
+	var balance float32
+	highest := nonce
+	if len(p) > 0 {
+		balance = p[len(p)-1].balance
+		if last := p[len(p)-1].highest; last > highest {
+			highest = last
+		}
+	}
+	idx.postings[address] = append(p, posting{height: height, txid: txid, nonce: nonce, highest: highest, amount: amount, balance: balance + amount})
+}
+
+// apply adds the transfers of b, the block at height
+func (idx *balanceIndex) apply(height int, b *Block) {
+	for i, t := range b.transactions {
+		txid := t.Hash()
+		idx.locations[txid] = append(idx.locations[txid], txLocation{height, i})
+		idx.post(t.senderBlockchainAddress, height, txid, t.nonce, -(t.value + t.fee))
+		idx.post(t.recipientBlockchainAddress, height, txid, 0, t.value)
+	}
+}
+
+// revert drops the postings of blocks, the blocks of the chain from height fork
+func (idx *balanceIndex) revert(fork int, blocks []*Block) {
+	for _, b := range blocks {
+		for _, t := range b.transactions {
+			idx.truncate(t.senderBlockchainAddress, fork)
+			idx.truncate(t.recipientBlockchainAddress, fork)
+			idx.unindex(t.Hash(), fork)
+		}
+	}
+}
+
+// unindex drops the locations of txid from height fork
+func (idx *balanceIndex) unindex(txid [32]byte, fork int) {
+	l := idx.locations[txid]
+	i := len(l)
+	for i > 0 && l[i-1].height >= fork {
+		i--
+	}
+	if i == 0 {
+		delete(idx.locations, txid)
+		return
+	}
+	idx.locations[txid] = l[:i]
+}
+
+// find returns the location of the transaction with txid, the last one when the txid is in several blocks
+func (idx *balanceIndex) find(txid [32]byte) (txLocation, bool) {
+	l := idx.locations[txid]
+	if len(l) == 0 {
+		return txLocation{}, false
+	}
+	return l[len(l)-1], true
+}
+
+// findAt returns the index of the transaction with txid in the block at height
+func (idx *balanceIndex) findAt(txid [32]byte, height int) (int, bool) {
+	for _, l := range idx.locations[txid] {
+		if l.height == height {
+			return l.index, true
+		}
+	}
+	return 0, false
+}
+
+// truncate drops the postings of address from height fork
+func (idx *balanceIndex) truncate(address string, fork int) {
+	p := idx.postings[address]
+	i := len(p)
+	for i > 0 && p[i-1].height >= fork {
+		i--
+	}
+	if i == 0 {
+		delete(idx.postings, address)
+		return
+	}
+	idx.postings[address] = p[:i]
+}
+
+// balance returns the balance of address
+func (idx *balanceIndex) balance(address string) float32 {
+	p := idx.postings[address]
+	if len(p) == 0 {
+		return 0
+	}
+	return p[len(p)-1].balance
+}
+
+// sent returns the highest nonce address used in the chain and whether the transaction with txid is there
+func (idx *balanceIndex) sent(address string, txid [32]byte) (uint64, bool) {
+	var nonce uint64
+	if p := idx.postings[address]; len(p) > 0 {
+		nonce = p[len(p)-1].highest
+	}
+	_, mined := idx.find(txid)
+	return nonce, mined
+}
+
+// replayed returns the index of the first transaction of transactions, the transactions of a block after
+// the chain of the index, that is already in the chain or the block, or carries a nonce its sender used
+// there, -1 when there is none. The coinbase and anchor transactions are not checked.
+func (idx *balanceIndex) replayed(transactions []*Transaction) int {
+	type senderNonce struct {
+		sender string
+		nonce  uint64
+	}
+	txids := make(map[[32]byte]bool, len(transactions))
+	nonces := make(map[senderNonce]bool, len(transactions))
+	for i, t := range transactions {
+		sender := t.senderBlockchainAddress
+		if sender == MiningSender || sender == AnchorSender {
+			continue
+		}
+		txid := t.Hash()
+		highest, mined := idx.sent(sender, txid)
+		if mined || txids[txid] {
+			return i
+		}
+		// The nonces of a sender may be in any order within the block, ordered by fee
+		if t.nonce != 0 && (t.nonce <= highest || nonces[senderNonce{sender, t.nonce}]) {
+			return i
+		}
+		txids[txid] = true
+		nonces[senderNonce{sender, t.nonce}] = true
+	}
+	return -1
+}
+
+// spendable returns the balance of address less what it received in the blocks from height from
+func (idx *balanceIndex) spendable(address string, from int) float32 {
+	p := idx.postings[address]
+	i := len(p)
+	for i > 0 && p[i-1].height >= from {
+		i--
+	}
+	total := idx.balance(address)
+	for _, q := range p[i:] {
+		if q.amount > 0 && q.txid != ([32]byte{}) {
+			total -= q.amount
+		}
+	}
+	return total
+}
+
+// UnspentOutput is a payment to an address not yet spent by its later payments, which spend the oldest
+// payments first. Value is what is left of it.
+type UnspentOutput struct {
+	TxID   string  `json:"txid,omitempty"`
+	Height int     `json:"height"`
+	Value  float32 `json:"value"`
+}
+
+// unspent returns the UnspentOutput of address, oldest first
+func (idx *balanceIndex) unspent(address string) []UnspentOutput {
+	outputs := make([]UnspentOutput, 0)
+	var spent float32
+	for _, p := range idx.postings[address] {
+		if p.amount < 0 {
+			spent -= p.amount
+			continue
+		}
+		if p.amount == 0 {
+			continue
+		}
+		o := UnspentOutput{Height: p.height, Value: p.amount}
+		if p.txid != ([32]byte{}) {
+			o.TxID = hexHash(p.txid)
+		}
+		outputs = append(outputs, o)
+	}
+	// Spend the oldest outputs first
+	i := 0
+	for i < len(outputs) && spent >= outputs[i].Value {
+		spent -= outputs[i].Value
+		i++
+	}
+	outputs = outputs[i:]
+	if len(outputs) > 0 {
+		outputs[0].Value -= spent
+	}
+	return outputs
+}
+
+// balanceIndex returns the balance index and the chain it was built from, holding muxChain for reading,
+// which the caller must release. The index is built on first use, updated as blocks are appended and
+// as the chain is replaced from the same Snapshot.
+func (bc *Blockchain) balanceIndex() (*balanceIndex, []*Block) {
+	for {
+		bc.muxChain.RLock()
+		if bc.balances != nil {
+			return bc.balances, bc.chain
+		}
+		bc.muxChain.RUnlock()
+
+		bc.muxChain.Lock()
+		if bc.balances == nil {
+			bc.balances = newBalanceIndex(bc.chain, bc.base)
+		}
+		bc.muxChain.Unlock()
+	}
+}
+
+// balance returns the balance of address and the chain it was computed from
+func (bc *Blockchain) balance(address string) (float32, []*Block) {
+	idx, chain := bc.balanceIndex()
+	defer bc.muxChain.RUnlock()
+	return idx.balance(address), chain
+}
+
+// UnspentOutputs returns the payments to blockchainAddress its balance is made of, oldest first
+func (bc *Blockchain) UnspentOutputs(blockchainAddress string) []UnspentOutput {
+	idx, _ := bc.balanceIndex()
+	defer bc.muxChain.RUnlock()
+	return idx.unspent(blockchainAddress)
+}
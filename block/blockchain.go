@@ -1,28 +1,32 @@
 package block
 
 import (
-	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/hirasawayuki/block_chain/address"
 	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
 )
 
 const (
 	// MiningDifficulty is the difficuluty of mining
 	MiningDifficulty = 3
+	// RegtestDifficulty is the difficuluty of mining in regtest mode
+	RegtestDifficulty = 1
 	// MiningSender is Blockchain network address
 	MiningSender = "THE BLOCKCHAIN"
-	// MiningReward is a mining reward
+	// MiningReward is the mining reward before any halving
 	MiningReward = 1.0
+	// ProofOfWorkCheckInterval is the number of nonces tried between checks for cancellation
+	ProofOfWorkCheckInterval = 1 << 12
 	// MiningTimerSec is mining time interval
 	MiningTimerSec              = 20
 	BlockchainPortRangeStart    = 5000
@@ -30,18 +34,41 @@ const (
 	NeighborIpRangeStart        = 0
 	NeighborIpRangeEnd          = 1
 	BlockchainNeiborSyncTimeSec = 20
+	// BurnAddress is the canonical unspendable address (version 0, all zero hash). Coins sent to it are burned
+	BurnAddress = "1111111111111111111114oLvT2"
+	// DefaultChainID is the chain ID of the main network
+	DefaultChainID = "mainnet"
+	// RegtestChainID is the chain ID of regtest networks
+	RegtestChainID = "regtest"
+	// LockTimeThreshold separates lock_until values: below it they are block heights, otherwise unix timestamps
+	LockTimeThreshold = 500000000
 )
 
-// Block is a structure with nonce, previousHash, timestamp, transactions
+// GenesisTime is the timestamp of the genesis block of every network, unless set with WithGenesisTime
+var GenesisTime = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Block is a structure with nonce, previousHash, timestamp, bits, transactions.
+// bits is the compact proof of work target the block was mined at, 0 for the genesis block.
+// A pruned block, loaded from a Snapshot, has no transactions and keeps the hash of its header.
 type Block struct {
 	timestamp    int64
 	nonce        int
 	previousHash [32]byte
+	bits         uint32
+	extraData    string
+	// merkleRoot is the root of the transactions the block hash commits to, zero in blocks made before
+	// blocks carried it
+	merkleRoot   [32]byte
 	transactions []*Transaction
+	pruned       bool
+	hash         [32]byte
 }
 
 // Hash convert Block to SHA256 []byte and returns []byte
 func (b *Block) Hash() [32]byte {
+	if b.pruned {
+		return b.hash
+	}
 	m, _ := json.Marshal(b)
 	return sha256.Sum256([]byte(m))
 }
@@ -49,36 +76,31 @@ func (b *Block) Hash() [32]byte {
 // MarshalJSON is returns a struct
 func (b *Block) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Timestamp    int64          `j:"timestamp"`
-		Nonce        int            `j:"nonce"`
-		PreviousHash string         `j:"previous_hash"`
-		Transactions []*Transaction `j:"transactions"`
+		Timestamp    int64          `json:"timestamp"`
+		Nonce        int            `json:"nonce"`
+		PreviousHash string         `json:"previous_hash"`
+		Bits         uint32         `json:"bits,omitempty"`
+		ExtraData    string         `json:"extra_data,omitempty"`
+		MerkleRoot   string         `json:"merkle_root,omitempty"`
+		Transactions []*Transaction `json:"transactions"`
 	}{
 		Timestamp:    b.timestamp,
 		Nonce:        b.nonce,
 		PreviousHash: fmt.Sprintf("%x", b.previousHash),
+		Bits:         b.bits,
+		ExtraData:    b.extraData,
+		MerkleRoot:   b.committedRoot(),
 		Transactions: b.transactions,
 	})
 }
 
+// UnmarshalJSON decodes a Block with DecodeBlock
 func (b *Block) UnmarshalJSON(data []byte) error {
-	var previousHash string
-	v := &struct {
-		Timestamp    *int64  `json:"timestamp"`
-		Nonce        *int    `json:"nonce"`
-		PreviousHash *string `json:"previous_hash"`
-		Transactions *[]*Transaction
-	}{
-		Timestamp:    &b.timestamp,
-		Nonce:        &b.nonce,
-		PreviousHash: &previousHash,
-		Transactions: &b.transactions,
-	}
-	if err := json.Unmarshal(data, &v); err != nil {
+	d, err := DecodeBlock(data)
+	if err != nil {
 		return err
 	}
-	ph, _ := hex.DecodeString(*v.PreviousHash)
-	copy(b.previousHash[:], ph[:32])
+	*b = *d
 	return nil
 }
 
@@ -89,9 +111,15 @@ func NewBlock(nonce int, previousHash [32]byte, transactions []*Transaction) *Bl
 	b.previousHash = previousHash
 	b.timestamp = time.Now().UnixNano()
 	b.transactions = transactions
+	b.merkleRoot = MerkleRoot(transactions)
 	return b
 }
 
+// Timestamp returns the block time in unix nanoseconds
+func (b *Block) Timestamp() int64 {
+	return b.timestamp
+}
+
 func (b *Block) PreviousHash() [32]byte {
 	return b.previousHash
 }
@@ -100,8 +128,38 @@ func (b *Block) Nonce() int {
 	return b.nonce
 }
 
+// Bits returns the compact proof of work target of the block
+func (b *Block) Bits() uint32 {
+	return b.bits
+}
+
+// ExtraData returns the tag the miner put in the block, "" when none
+func (b *Block) ExtraData() string {
+	return b.extraData
+}
+
+// MerkleRoot returns the merkle root of the transactions of the block. A pruned block only knows the root
+// its hash commits to, if any.
+func (b *Block) MerkleRoot() [32]byte {
+	if b.pruned {
+		return b.merkleRoot
+	}
+	return MerkleRoot(b.transactions)
+}
+
+// committedRoot returns the hex encoded merkle root the block hash commits to, "" when none
+func (b *Block) committedRoot() string {
+	if b.merkleRoot == ([32]byte{}) {
+		return ""
+	}
+	return hexHash(b.merkleRoot)
+}
+
+// Transactions returns a copy of the transactions of the block
 func (b *Block) Transactions() []*Transaction {
-	return b.transactions
+	transactions := make([]*Transaction, len(b.transactions))
+	copy(transactions, b.transactions)
+	return transactions
 }
 
 // Print is formats and outputs
@@ -109,6 +167,13 @@ func (b *Block) Print() {
 	fmt.Printf("timestamp:     %d\n", b.timestamp)
 	fmt.Printf("nonce:         %d\n", b.nonce)
 	fmt.Printf("previousHash:  %x\n", b.previousHash)
+	fmt.Printf("bits:          %08x\n", b.bits)
+	if b.extraData != "" {
+		fmt.Printf("extraData:     %q\n", b.extraData)
+	}
+	if b.merkleRoot != ([32]byte{}) {
+		fmt.Printf("merkleRoot:    %x\n", b.merkleRoot)
+	}
 
 	for _, t := range b.transactions {
 		t.Print()
@@ -117,65 +182,268 @@ func (b *Block) Print() {
 
 // Blockchain is a struct with transactionsPool, chain
 type Blockchain struct {
-	transactionPool   []*Transaction
+	mempool           *Mempool
 	chain             []*Block
 	blockchainAddress string
+	chainID           string
+	extraData         string
 	port              uint16
+	transport         Transport
+	clock             Clock
+	difficulty        int
+	miningDifficulty  int
+	miningInterval    time.Duration
+	miningWorkers     int
+	genesis           []*Transaction
+	genesisTime       time.Time
+	genesisBlock      *Block
+	syncInterval      time.Duration
+	gcInterval        time.Duration
+	mempoolExpiry     time.Duration
+	retarget          Retarget
+	emission          Emission
+	regtest           bool
+	mineEmpty         bool
+	logger            utils.Logger
+	jobs              map[string]*MiningJob
+	orphans           *orphanStore
+	rejected          *rejectedTips
+	events            *eventHub
+	broadcasts        broadcastCounter
+	loops             loops
+	miner             *Miner
+	base              *Snapshot
+	role              Role
+	pruneDepth        int
 	mux               sync.Mutex
+	// muxMining lets a single block be mined at a time, mining does not hold mux during the proof of work
+	muxMining sync.Mutex
+	// muxChain guards chain and base for readers not holding mux, writers hold both.
+	// It also guards balances, the balance of every address after chain.
+	muxChain sync.RWMutex
+	balances *balanceIndex
 
 	neighbors    []string
+	peers        map[string]NodeInfo
+	manualPeers  map[string]bool
+	bans         map[string]time.Time
 	muxNeighbors sync.Mutex
+	syncProgress syncProgress
+	drift        driftMonitor
+
+	store BlockStore
+
+	alerts      *alertStore
+	alertKey    wallet.Signer
+	muxAlertKey sync.Mutex
 }
 
-// NewBlockChain returns a Blockchain struct
-func NewBlockChain(blockchainAddress string, port uint16) *Blockchain {
-	b := &Block{}
+// NewBlockChain returns a Blockchain struct talking to its neighbors over HTTP
+func NewBlockChain(blockchainAddress string, port uint16, opts ...Option) *Blockchain {
+	return NewBlockChainWithTransport(blockchainAddress, port, NewHTTPTransport(), opts...)
+}
+
+// NewBlockChainWithTransport returns a Blockchain struct talking to its neighbors through transport
+func NewBlockChainWithTransport(blockchainAddress string, port uint16, transport Transport, opts ...Option) *Blockchain {
 	bc := new(Blockchain)
 	bc.blockchainAddress = blockchainAddress
-	bc.CreateBlock(0, b.Hash())
+	bc.chainID = DefaultChainID
+	bc.transport = transport
+	bc.clock = SystemClock{}
+	bc.difficulty = MiningDifficulty
+	bc.miningDifficulty = MiningDifficulty
+	bc.miningInterval = MiningTimerSec * time.Second
+	bc.miningWorkers = runtime.NumCPU()
+	bc.syncInterval = BlockchainNeiborSyncTimeSec * time.Second
+	bc.gcInterval = GCInterval
+	bc.mempoolExpiry = MempoolExpiry
+	bc.drift.threshold = DefaultMaxClockDrift
+	bc.retarget = FixedRetarget{}
+	bc.emission = DefaultEmission
+	bc.genesisTime = GenesisTime
+	bc.logger = utils.StdLogger{}
+	bc.orphans = newOrphanStore()
+	bc.rejected = newRejectedTips()
+	bc.events = newEventHub()
+	bc.alerts = newAlertStore()
+	bc.miner = newMiner(bc)
+	bc.mempool = newMempool()
+	bc.role = RoleArchive
+	bc.pruneDepth = DefaultPruneDepth
+	for _, opt := range opts {
+		opt(bc)
+	}
+	genesis := bc.genesisBlock
+	if genesis == nil {
+		genesis = newGenesisBlock(bc.genesis, bc.genesisTime)
+	}
+	bc.appendBlock(genesis)
 	bc.port = port
 	return bc
 }
 
+// ChainID returns the ID of the network the Blockchain belongs to, which transactions are signed for
+func (bc *Blockchain) ChainID() string {
+	return bc.chainID
+}
+
+// SetClock replaces the Clock used for block timestamps, lock times and timers
+func (bc *Blockchain) SetClock(c Clock) {
+	bc.clock = c
+}
+
+// Clock returns the Clock of the Blockchain
+func (bc *Blockchain) Clock() Clock {
+	return bc.clock
+}
+
+// SetRegtest switches regtest mode: mining at RegtestDifficulty and a block for every created transaction
+func (bc *Blockchain) SetRegtest(regtest bool) {
+	bc.regtest = regtest
+	bc.difficulty = bc.miningDifficulty
+	if regtest {
+		bc.difficulty = RegtestDifficulty
+	}
+}
+
+// SetRetarget replaces the difficulty adjustment algorithm, FixedRetarget by default.
+// All nodes of a network must use the same one.
+func (bc *Blockchain) SetRetarget(r Retarget) {
+	bc.retarget = r
+}
+
+// SetMineEmpty sets whether Mining mines a block, paying only the mining reward, when the
+// transaction pool is empty. It is off by default; on quiet networks it keeps confirmations coming.
+func (bc *Blockchain) SetMineEmpty(mineEmpty bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.mineEmpty = mineEmpty
+}
+
+// MineEmpty reports whether Mining mines empty blocks
+func (bc *Blockchain) MineEmpty() bool {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.mineEmpty
+}
+
+// Regtest reports whether the Blockchain is in regtest mode
+func (bc *Blockchain) Regtest() bool {
+	return bc.regtest
+}
+
+// Difficulty returns the number of leading zeros required of block hashes
+func (bc *Blockchain) Difficulty() int {
+	return bc.difficulty
+}
+
+// Chain returns a copy of the chain, so it can be read while blocks are added
 func (bc *Blockchain) Chain() []*Block {
-	return bc.chain
+	view, _ := bc.view()
+	chain := make([]*Block, len(view))
+	copy(chain, view)
+	return chain
+}
+
+// view returns the chain and the Snapshot it starts from without waiting for mining. Blocks are only
+// appended or the chain replaced as a whole, so the returned slice stays valid while blocks are added.
+func (bc *Blockchain) view() ([]*Block, *Snapshot) {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return bc.chain, bc.base
 }
 
-func (bc *Blockchain) Run() {
-	bc.StartSyncNeighbors()
-	bc.ResolveConflicts()
-	bc.StartMining()
+// setChain replaces the chain, which differs from the current one from height fork, and the Snapshot it starts from.
+// The caller must hold mux.
+func (bc *Blockchain) setChain(chain []*Block, fork int, base *Snapshot) {
+	bc.muxChain.Lock()
+	if bc.balances != nil && base == bc.base && fork >= 0 && fork <= len(bc.chain) && fork <= len(chain) {
+		bc.balances.revert(fork, bc.chain[fork:])
+		for i := fork; i < len(chain); i++ {
+			bc.balances.apply(i, chain[i])
+		}
+	} else {
+		bc.balances = nil
+	}
+	bc.chain = chain
+	bc.base = base
+	bc.muxChain.Unlock()
+	bc.storeChain(chain, fork, base)
 }
 
-func (bc *Blockchain) SetNeighbors() {
-	bc.neighbors = utils.FindNeighbors(utils.GetHost(), bc.port, NeighborIpRangeStart, NeighborIpRangeEnd, BlockchainPortRangeStart, BlockchainPortRangeEnd)
+// appendBlock appends b to the chain and updates the balances, if they were built. The caller must hold mux.
+func (bc *Blockchain) appendBlock(b *Block) {
+	bc.muxChain.Lock()
+	defer bc.muxChain.Unlock()
+	bc.chain = append(bc.chain, b)
+	if bc.balances != nil {
+		bc.balances.apply(len(bc.chain)-1, b)
+	}
 }
 
-func (bc *Blockchain) SyncNeighbors() {
+// Run starts syncing neighbors, mining and garbage collection until ctx is done or Stop is called
+func (bc *Blockchain) Run(ctx context.Context) {
+	bc.StartSyncNeighbors(ctx)
+	bc.ResolveConflicts(ctx)
+	bc.StartMining(ctx)
+	bc.StartGC(ctx)
+}
+
+// SetNeighbors finds the neighbors, adds the peers added with AddPeer, leaves out the banned ones,
+// learns their roles in a handshake and checks the clock drift against them
+func (bc *Blockchain) SetNeighbors(ctx context.Context) {
+	found := bc.transport.FindNeighbors(ctx, bc.port)
+	bc.muxNeighbors.Lock()
+	neighbors := bc.peerSet(found)
+	bc.muxNeighbors.Unlock()
+	peers := bc.handshake(ctx, neighbors)
+	bc.muxNeighbors.Lock()
+	// Peers may have been banned during the handshake
+	bc.neighbors = bc.peerSet(neighbors)
+	bc.peers = peers
+	bc.muxNeighbors.Unlock()
+	bc.checkClockDrift()
+}
+
+// Neighbors returns a copy of the addresses of the neighbors
+func (bc *Blockchain) Neighbors() []string {
 	bc.muxNeighbors.Lock()
 	defer bc.muxNeighbors.Unlock()
-	bc.SetNeighbors()
+	neighbors := make([]string, len(bc.neighbors))
+	copy(neighbors, bc.neighbors)
+	return neighbors
+}
+
+func (bc *Blockchain) SyncNeighbors(ctx context.Context) {
+	bc.SetNeighbors(ctx)
 }
 
-func (bc *Blockchain) StartSyncNeighbors() {
-	bc.SyncNeighbors()
-	_ = time.AfterFunc(time.Second*BlockchainNeiborSyncTimeSec, bc.StartSyncNeighbors)
+// StartSyncNeighbors syncs the neighbors now and then every sync interval until ctx is done or the Loop is stopped.
+// A sync loop already running is stopped.
+func (bc *Blockchain) StartSyncNeighbors(ctx context.Context) *Loop {
+	if ctx.Err() == nil {
+		bc.SyncNeighbors(ctx)
+	}
+	return bc.loops.start("sync", startLoop(ctx, bc.clock, bc.syncInterval, bc.SyncNeighbors))
 }
 
+// TransactionPool returns a snapshot of the pending transactions
 func (bc *Blockchain) TransactionPool() []*Transaction {
-	return bc.transactionPool
+	return bc.mempool.Transactions()
 }
 
 func (bc *Blockchain) ClearTransactionPool() {
-	bc.transactionPool = bc.transactionPool[:0]
+	bc.mempool.clear()
 }
 
-// MarshalJSON is returns a Block struct slice
+// MarshalJSON is returns a Block struct slice and the ChainFormatVersion of the blocks
 func (bc *Blockchain) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Blocks []*Block `json:"chain"`
+		Version int      `json:"version"`
+		Blocks  []*Block `json:"chain"`
 	}{
-		Blocks: bc.chain,
+		Version: ChainFormatVersion,
+		Blocks:  bc.Chain(),
 	})
 }
 
@@ -192,78 +460,252 @@ func (bc *Blockchain) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// CreateBlock is create Block and append chain.
+// CreateBlock is create Block of the pending transactions and append chain.
 // returns a Block
-func (bc *Blockchain) CreateBlock(nonce int, previousHash [32]byte) *Block {
-	b := NewBlock(nonce, previousHash, bc.transactionPool)
-	bc.chain = append(bc.chain, b)
-	bc.transactionPool = []*Transaction{}
-	for _, n := range bc.neighbors {
-		endpoint := fmt.Sprintf("http://%s/transactions", n)
-		client := &http.Client{}
-		req, _ := http.NewRequest("DELETE", endpoint, nil)
-		resp, _ := client.Do(req)
-		log.Printf("%v", resp)
+func (bc *Blockchain) CreateBlock(ctx context.Context, nonce int, previousHash [32]byte) *Block {
+	b := bc.createBlock(nonce, previousHash, "", bc.mempool.Transactions())
+	bc.clearNeighborPools(ctx)
+	return b
+}
+
+// createBlock appends a Block of transactions tagged with extraData and removes them from the pool.
+// The caller must hold mux, and tell the neighbors about the block once it released mux.
+func (bc *Blockchain) createBlock(nonce int, previousHash [32]byte, extraData string, transactions []*Transaction) *Block {
+	b := NewBlock(nonce, previousHash, transactions)
+	b.extraData = extraData
+	b.timestamp = bc.clock.Now().UnixNano()
+	// A clock behind the chain still dates the block after the median time past
+	if len(bc.chain) > 0 {
+		if mtp := medianTimePast(bc.chain); b.timestamp <= mtp {
+			b.timestamp = mtp + 1
+		}
 	}
+	b.bits = bc.requiredBits(bc.chain)
+	bc.appendBlock(b)
+	bc.storeBlock(b)
+	bc.events.publish(BlockEvent{Height: len(bc.chain) - 1, Block: b})
+	bc.mempool.remove(transactions)
+	bc.tipChanged()
 	return b
 }
 
+// newGenesisBlock returns the genesis block of transactions. It is dated t rather than by a clock,
+// so that every node built with the same genesis balances starts from the same block.
+func newGenesisBlock(transactions []*Transaction, t time.Time) *Block {
+	b := NewBlock(0, (&Block{}).Hash(), transactions)
+	b.timestamp = t.UnixNano()
+	return b
+}
+
+// DefaultGenesis returns the genesis block of a network without genesis balances, dated GenesisTime
+func DefaultGenesis() *Block {
+	return newGenesisBlock(nil, GenesisTime)
+}
+
+// Genesis returns the genesis block of the Blockchain, which the chains it accepts start from
+func (bc *Blockchain) Genesis() *Block {
+	chain, _ := bc.view()
+	return chain[0]
+}
+
 // LastBlock returns last Block in Blockchain
 func (bc *Blockchain) LastBlock() *Block {
-	return bc.chain[len(bc.chain)-1]
+	chain, _ := bc.view()
+	return chain[len(chain)-1]
 }
 
 // Print is output chain.
 func (bc *Blockchain) Print() {
-	for i, b := range bc.chain {
+	chain, _ := bc.view()
+	for i, b := range chain {
 		fmt.Println("#############################")
 		fmt.Printf("chain:         %d\n", i)
 		b.Print()
 	}
 }
 
-func (bc *Blockchain) CreateTransaction(sender string, recipient string, value float32, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
-	isTransacted := bc.AddTransaction(sender, recipient, value, senderPublicKey, s)
-	if isTransacted {
-		for _, n := range bc.neighbors {
-			publicKeyStr := fmt.Sprintf("%064x%064x", senderPublicKey.X.Bytes(), senderPublicKey.Y.Bytes())
-			signatureStr := s.String()
-			bt := &TransactionRequest{&sender, &recipient, &publicKeyStr, &value, &signatureStr}
-			m, _ := json.Marshal(bt)
-			buf := bytes.NewBuffer(m)
-			endpoint := fmt.Sprintf("http://%s/transactions", n)
-			client := &http.Client{}
-			req, _ := http.NewRequest("PUT", endpoint, buf)
-			resp, _ := client.Do(req)
-			log.Printf("%v", resp)
+// CreateTransaction adds a transaction and sends it to the neighbors
+func (bc *Blockchain) CreateTransaction(ctx context.Context, sender string, recipient string, value float32, lockUntil int64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) (BroadcastResult, error) {
+	if err := bc.AddTransaction(sender, recipient, value, lockUntil, senderPublicKey, s); err != nil {
+		return BroadcastResult{}, err
+	}
+	publicKeyStr := fmt.Sprintf("%064x%064x", senderPublicKey.X.Bytes(), senderPublicKey.Y.Bytes())
+	signatureStr := s.String()
+	return bc.broadcastTransaction(ctx, &TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		SenderPublicKey:            &publicKeyStr,
+		Value:                      &value,
+		LockUntil:                  &lockUntil,
+		Signature:                  &signatureStr,
+	}), nil
+}
+
+// CreateEscrowTransaction adds a transaction spending escrowed funds and sends it to the neighbors
+func (bc *Blockchain) CreateEscrowTransaction(ctx context.Context, sender string, recipient string, value float32, lockUntil int64, e *Escrow, signatures []*utils.Signature) (BroadcastResult, error) {
+	if err := bc.AddEscrowTransaction(sender, recipient, value, lockUntil, e, signatures); err != nil {
+		return BroadcastResult{}, err
+	}
+	publicKeys := e.PublicKeyStrs()
+	signatureStrs := make([]string, 0, len(signatures))
+	for _, s := range signatures {
+		signatureStrs = append(signatureStrs, s.String())
+	}
+	return bc.broadcastTransaction(ctx, &TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		Value:                      &value,
+		LockUntil:                  &lockUntil,
+		EscrowPublicKeys:           &publicKeys,
+		EscrowSignatures:           &signatureStrs,
+	}), nil
+}
+
+// CreateTransactionRequest adds the transaction described by the request and sends it to the neighbors
+func (bc *Blockchain) CreateTransactionRequest(ctx context.Context, tr *TransactionRequest) (BroadcastResult, error) {
+	if err := bc.AddTransactionRequest(tr); err != nil {
+		return BroadcastResult{}, err
+	}
+	result := bc.broadcastTransaction(ctx, tr)
+	if bc.regtest {
+		bc.Mining(ctx)
+	}
+	return result, nil
+}
+
+// AddTransactionRequest adds the transaction described by the request.
+// Escrow, anchor and name registration requests are dispatched to their own Add functions.
+func (bc *Blockchain) AddTransactionRequest(tr *TransactionRequest) error {
+	if !tr.Validate() {
+		return fmt.Errorf("%w: missing field(s)", ErrInvalidTransaction)
+	}
+	if *tr.SenderBlockchainAddress == MiningSender {
+		return fmt.Errorf("%w: %s can not send transactions", ErrInvalidAddress, MiningSender)
+	}
+	var lockUntil int64
+	if tr.LockUntil != nil {
+		lockUntil = *tr.LockUntil
+	}
+
+	switch {
+	case tr.IsEscrow():
+		e, signatures, err := tr.Escrow()
+		if err != nil {
+			return err
+		}
+		return bc.AddEscrowTransaction(*tr.SenderBlockchainAddress, *tr.RecipientBlockchainAddress, *tr.Value, lockUntil, e, signatures)
+	case tr.IsAnchor():
+		return bc.AddAnchor(*tr.Data)
+	case tr.IsNameRegistration():
+		publicKey := utils.PublicKeyFromString(*tr.SenderPublicKey)
+		signature, err := utils.ParseSignature(*tr.Signature)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		return bc.AddNameRegistration(*tr.SenderBlockchainAddress, *tr.Name, *tr.Value, publicKey, signature)
+	default:
+		t := NewTransaction(*tr.SenderBlockchainAddress, *tr.RecipientBlockchainAddress, *tr.Value, lockUntil)
+		if tr.Fee != nil {
+			if *tr.Fee < 0 {
+				return fmt.Errorf("%w: negative fee", ErrInvalidTransaction)
+			}
+			t.fee = *tr.Fee
+		}
+		if tr.Nonce != nil {
+			t.nonce = *tr.Nonce
 		}
+		publicKey := utils.PublicKeyFromString(*tr.SenderPublicKey)
+		signature, err := utils.ParseSignature(*tr.Signature)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		return bc.addTransaction(t, publicKey, signature)
 	}
-	return isTransacted
 }
 
 // AddTransaction is create Transaction and add BlockChain struct
-func (bc *Blockchain) AddTransaction(sender string, recipient string, value float32, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
-	t := NewTransaction(sender, recipient, value)
-	if sender == MiningSender {
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
+func (bc *Blockchain) AddTransaction(sender string, recipient string, value float32, lockUntil int64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
+	return bc.addTransaction(NewTransaction(sender, recipient, value, lockUntil), senderPublicKey, s)
+}
+
+func (bc *Blockchain) addTransaction(t *Transaction, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
+	t.seal()
+	sender := t.senderBlockchainAddress
+	if sender == BurnAddress {
+		return fmt.Errorf("%w: burn address is unspendable", ErrInvalidAddress)
 	}
-	if bc.VerifyTransactionSignature(senderPublicKey, s, t) {
-		if bc.CaluculateTotalAmount(sender) < value {
-			log.Println("ERROR: Not enough balance in a wallet")
-			return false
+	// Mining pays its own coinbase and anchors go through AddAnchor
+	if sender == MiningSender || sender == AnchorSender {
+		return fmt.Errorf("%w: %s can not send transactions", ErrInvalidAddress, sender)
+	}
+	if err := address.Validate(t.recipientBlockchainAddress); err != nil {
+		return fmt.Errorf("%w: recipient %s: %v", ErrInvalidAddress, t.recipientBlockchainAddress, err)
+	}
+	if senderPublicKey == nil || address.FromPublicKey(senderPublicKey) != sender {
+		return fmt.Errorf("%w: sender address does not match the public key", ErrInvalidAddress)
+	}
+	if !bc.VerifyTransactionSignature(senderPublicKey, s, t) {
+		return ErrInvalidSignature
+	}
+	if err := bc.checkReplay(t); err != nil {
+		return err
+	}
+	balance := bc.CaluculateTotalAmount(sender)
+	if balance < t.value+t.fee {
+		return ErrInsufficientBalance
+	}
+	h := t.Hash()
+	var conflicting *Transaction
+	old, err := bc.mempool.addFunded(t, balance, func(p *Transaction) error {
+		if p.Hash() == h {
+			return ErrDuplicateTransaction
 		}
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
+		if conflicts(p, t) {
+			conflicting = p
+		}
+		return nil
+	})
+	if conflicting != nil {
+		bc.raiseAlert(conflicting, t, AlertSourceMempool, 0)
 	}
-	log.Println("ERROR: Verify Transaction")
-	return false
+	if old != nil {
+		bc.logger.Printf("action=replace, sender=%s, nonce=%d, fee=%g->%g", sender, t.nonce, old.fee, t.fee)
+	}
+	return bc.accepted(t, err)
+}
+
+// AddEscrowTransaction is create Transaction spending from an escrow address and add BlockChain struct
+func (bc *Blockchain) AddEscrowTransaction(sender string, recipient string, value float32, lockUntil int64, e *Escrow, signatures []*utils.Signature) error {
+	if e.Address() != sender {
+		return fmt.Errorf("%w: escrow address does not match participants", ErrInvalidAddress)
+	}
+	if err := address.Validate(recipient); err != nil {
+		return fmt.Errorf("%w: recipient %s: %v", ErrInvalidAddress, recipient, err)
+	}
+	t := NewTransaction(sender, recipient, value, lockUntil).seal()
+	if !e.VerifySignatures(t, bc.chainID, signatures) {
+		return ErrInvalidSignature
+	}
+	if err := bc.checkReplay(t); err != nil {
+		return err
+	}
+	balance := bc.CaluculateTotalAmount(sender)
+	if balance < value {
+		return ErrInsufficientBalance
+	}
+	h := t.Hash()
+	_, err := bc.mempool.addFunded(t, balance, func(p *Transaction) error {
+		if p.Hash() == h {
+			return ErrDuplicateTransaction
+		}
+		return nil
+	})
+	return bc.accepted(t, err)
 }
 
-// VerifyTransactionSignature is verify transaction
+// VerifyTransactionSignature is verify transaction signed for the chain of the Blockchain
 func (bc *Blockchain) VerifyTransactionSignature(senderPublicKey *ecdsa.PublicKey, s *utils.Signature, t *Transaction) bool {
-	m, _ := json.Marshal(t)
-	h := sha256.Sum256([]byte(m))
+	h := sha256.Sum256(t.SigningPayload(bc.chainID))
 	return ecdsa.Verify(senderPublicKey, h[:], s.R, s.S)
 }
 
@@ -271,133 +713,433 @@ func (bc *Blockchain) VerifyTransactionSignature(senderPublicKey *ecdsa.PublicKe
 func (bc *Blockchain) CopyTransactionPool() []*Transaction {
 	transactions := make([]*Transaction, 0)
 
-	for _, t := range bc.transactionPool {
-		transactions = append(transactions, NewTransaction(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value))
+	for _, t := range bc.mempool.Transactions() {
+		c := *t
+		transactions = append(transactions, &c)
 	}
 	return transactions
 }
 
 // ValidProof is checks that the first difficuluty(3) digits of the hash value are 0
 func (bc *Blockchain) ValidProof(nonce int, previousHash [32]byte, transactions []*Transaction, difficuluty int) bool {
-	zeros := strings.Repeat("0", difficuluty)
-	guessBlock := Block{0, nonce, previousHash, transactions}
-	guessHashStr := fmt.Sprintf("%x", guessBlock.Hash())
-	return guessHashStr[:difficuluty] == zeros
+	return validProof(nonce, previousHash, transactions, DifficultyToBits(difficuluty), "")
 }
 
-// ProofOfWork is find a nonce where ValidProof is true
-func (bc *Blockchain) ProofOfWork() int {
-	transactions := bc.CopyTransactionPool()
+func validProof(nonce int, previousHash [32]byte, transactions []*Transaction, bits uint32, extraData string) bool {
+	guessBlock := Block{nonce: nonce, previousHash: previousHash, bits: bits, extraData: extraData, transactions: transactions}
+	return meetsTarget(guessBlock.Hash(), bits)
+}
+
+// ProofOfWork is find a nonce where ValidProof is true, searched by the mining workers in parallel.
+// It gives up with the error of ctx when ctx is done.
+func (bc *Blockchain) ProofOfWork(ctx context.Context) (int, error) {
+	// The block is prepared under mux and searched without holding it, like in mineBlock
+	bc.mux.Lock()
 	previousHash := bc.LastBlock().Hash()
-	nonce := 0
-	for !bc.ValidProof(nonce, previousHash, transactions, MiningDifficulty) {
-		nonce++
+	transactions := bc.CopyTransactionPool()
+	bits := bc.requiredBits(bc.chain)
+	bc.mux.Unlock()
+	return bc.proofOfWork(ctx, previousHash, transactions, bits, "")
+}
+
+func (bc *Blockchain) proofOfWork(ctx context.Context, previousHash [32]byte, transactions []*Transaction, bits uint32, extraData string) (int, error) {
+	workers := bc.miningWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	// The workers stop once one of them finds a nonce
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := make(chan int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			header := newPoWHeader(previousHash, transactions, bits, extraData)
+			if nonce, ok := bc.searchNonce(ctx, header, start, workers); ok {
+				found <- nonce
+				cancel()
+			}
+		}(w)
+	}
+	wg.Wait()
+	select {
+	case nonce := <-found:
+		return nonce, nil
+	default:
+		return 0, ctx.Err()
 	}
-	return nonce
 }
 
-// Mining is add transactions and pay miner for mining.
-func (bc *Blockchain) Mining() bool {
-	bc.mux.Lock()
-	defer bc.mux.Unlock()
+// searchNonce tries the nonces start, start+step, start+2*step... of header until one is valid or ctx is done
+func (bc *Blockchain) searchNonce(ctx context.Context, header *powHeader, start int, step int) (int, bool) {
+	tried := 0
+	for nonce := start; ; nonce += step {
+		if header.valid(nonce) {
+			bc.miner.tried(tried + 1)
+			return nonce, true
+		}
+		tried++
+		if tried == ProofOfWorkCheckInterval {
+			bc.miner.tried(tried)
+			tried = 0
+			if ctx.Err() != nil {
+				return 0, false
+			}
+		}
+	}
+}
 
-	if len(bc.transactionPool) == 0 {
+// Mining is add transactions and pay miner for mining. With an empty pool it mines only when MineEmpty is set.
+func (bc *Blockchain) Mining(ctx context.Context) bool {
+	return bc.mine(ctx, false)
+}
+
+// Generate mines n blocks, including empty ones, and returns the number of blocks mined
+func (bc *Blockchain) Generate(ctx context.Context, n int) int {
+	mined := 0
+	for i := 0; i < n; i++ {
+		if bc.mine(ctx, true) {
+			mined++
+		}
+	}
+	return mined
+}
+
+func (bc *Blockchain) mine(ctx context.Context, allowEmpty bool) bool {
+	if !bc.mineBlock(ctx, allowEmpty) {
 		return false
 	}
+	bc.announceBlock(ctx)
+	return true
+}
+
+// mineBlock prepares the block under mux, searches its proof of work without holding mux, so that transactions,
+// blocks and the chains of the neighbors are taken meanwhile, and appends it when it still extends the last block.
+// An attempt is canceled when the chain changes under it.
+func (bc *Blockchain) mineBlock(ctx context.Context, allowEmpty bool) bool {
+	bc.muxMining.Lock()
+	defer bc.muxMining.Unlock()
 
-	bc.AddTransaction(MiningSender, bc.blockchainAddress, MiningReward, nil, nil)
-	nonce := bc.ProofOfWork()
+	bc.mux.Lock()
+	// Transactions added while mining, or left out of a full block, stay in the pool for the next block
+	pool := bc.selectTransactions()
+	if len(pool) == 0 && !allowEmpty && !bc.mineEmpty {
+		bc.mux.Unlock()
+		return false
+	}
+	transactions := pool
+	if reward := bc.emission.Reward(len(bc.chain)) + totalFees(pool); reward > 0 {
+		transactions = append(transactions, NewTransaction(MiningSender, bc.blockchainAddress, reward, 0).seal())
+	}
+	template := bc.template(pool)
 	previousHash := bc.LastBlock().Hash()
-	bc.CreateBlock(nonce, previousHash)
-	fmt.Println("action=mining, status=success")
+	bits := bc.requiredBits(bc.chain)
+	extraData := bc.extraData
+	bc.mux.Unlock()
 
-	for _, n := range bc.neighbors {
-		endpoint := fmt.Sprintf("http://%s/consensus", n)
-		client := &http.Client{}
-		req, _ := http.NewRequest("PUT", endpoint, nil)
-		resp, _ := client.Do(req)
-		log.Printf("%v", resp)
+	attempt, cancel := context.WithCancel(ctx)
+	defer cancel()
+	bc.miner.attempting(template, cancel)
+	defer bc.miner.attempting(nil, nil)
+	nonce, err := bc.proofOfWork(attempt, previousHash, transactions, bits, extraData)
+	if err != nil {
+		bc.logger.Printf("Mining canceled: %v", err)
+		return false
 	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if previousHash != bc.LastBlock().Hash() {
+		bc.logger.Println("Mining canceled: the last block changed")
+		return false
+	}
+	b := bc.createBlock(nonce, previousHash, extraData, transactions)
+	bc.miner.mined(b.timestamp)
+	bc.logger.Println("action=mining, status=success")
 	return true
 }
 
-func (bc *Blockchain) StartMining() {
-	bc.Mining()
-	_ = time.AfterFunc(time.Second*MiningTimerSec, bc.StartMining)
+// StartMining mines now and then starts the Miner, mining every mining interval until ctx is done or the Loop is stopped.
+// A mining loop already running is stopped.
+func (bc *Blockchain) StartMining(ctx context.Context) *Loop {
+	if ctx.Err() == nil {
+		bc.miner.Resume()
+		bc.miner.tick(ctx)
+	}
+	return bc.miner.Start(ctx)
 }
 
 // CaluculateTotalAmount is caluculate the wallet balance that matches the blockchain address
 func (bc *Blockchain) CaluculateTotalAmount(blockchainAddress string) float32 {
-	var totalAmount float32 = 0.0
-	for _, b := range bc.chain {
-		for _, t := range b.transactions {
-			if t.senderBlockchainAddress == blockchainAddress {
-				totalAmount -= t.value
-			}
-			if t.recipientBlockchainAddress == blockchainAddress {
-				totalAmount += t.value
-			}
-		}
-	}
+	totalAmount, _ := bc.balance(blockchainAddress)
 	return totalAmount
 }
 
+// SpendableAmount is caluculate the wallet balance counting only incoming coins with at least
+// confirmations blocks on top of them, including their own block. Outgoing coins always count.
+func (bc *Blockchain) SpendableAmount(blockchainAddress string, confirmations int) float32 {
+	idx, chain := bc.balanceIndex()
+	defer bc.muxChain.RUnlock()
+	return idx.spendable(blockchainAddress, len(chain)-confirmations+1)
+}
+
+// BurnedAmount is caluculate the total amount of coins sent to the BurnAddress
+func (bc *Blockchain) BurnedAmount() float32 {
+	return bc.CaluculateTotalAmount(BurnAddress)
+}
+
+// TotalSupply is caluculate the amount of coins minted by mining rewards, excluding burned coins.
+// Fees are paid to miners as part of the mining reward but are not minted.
+func (bc *Blockchain) TotalSupply() float32 {
+	chain, base := bc.view()
+	var fees float32
+	if base != nil {
+		fees = base.Fees
+	}
+	for _, b := range chain {
+		fees += totalFees(b.transactions)
+	}
+	return -bc.CaluculateTotalAmount(MiningSender) - fees - bc.BurnedAmount()
+}
+
+// totalFees returns the sum of the fees of transactions
+func totalFees(transactions []*Transaction) float32 {
+	var fees float32
+	for _, t := range transactions {
+		fees += t.fee
+	}
+	return fees
+}
+
+// ValidChain checks that chain starts from the genesis block of bc, then the hash links, timestamps, targets,
+// proofs of work, coinbases and lock times of chain. Every block only depends on the blocks before it,
+// which are given, so the blocks are checked in parallel, then replayed transactions and nonces in chain order.
 func (bc *Blockchain) ValidChain(chain []*Block) bool {
-	preBlock := chain[0]
-	currentIndex := 1
-	for currentIndex < len(chain) {
-		b := chain[currentIndex]
-		if b.previousHash != preBlock.Hash() {
+	return bc.consistentChain(chain) && !bc.aheadOfClock(chain)
+}
+
+// consistentChain checks chain like ValidChain, except for the timestamps against the local clock.
+// A chain it rejects is invalid whenever it is checked.
+func (bc *Blockchain) consistentChain(chain []*Block) bool {
+	if len(chain) == 0 {
+		return false
+	}
+	local, _ := bc.view()
+	if chain[0].Hash() != local[0].Hash() {
+		return false
+	}
+	return bc.validBlocks(chain)
+}
+
+// validBlocks checks the blocks of chain after its genesis block, whichever it is
+func (bc *Blockchain) validBlocks(chain []*Block) bool {
+	if !allParallel(len(chain)-1, func(i int) bool {
+		return bc.validBlock(chain, i+1)
+	}) {
+		return false
+	}
+	return validReplays(chain)
+}
+
+// validReplays reports whether no block of chain replays a transaction or a nonce of the blocks before it,
+// checked in chain order against a balanceIndex built along. Blocks known only by their headers are skipped.
+func validReplays(chain []*Block) bool {
+	idx := newBalanceIndex(nil, nil)
+	for i, b := range chain {
+		if i > 0 && idx.replayed(b.transactions) >= 0 {
 			return false
 		}
-		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transactions(), MiningDifficulty) {
-			return false
+		idx.apply(i, b)
+	}
+	return true
+}
+
+// invalidCoinbase returns the index of the first coinbase transaction beyond the one allowed, or paying more
+// than reward and the fees of transactions, -1 when there is none
+func invalidCoinbase(transactions []*Transaction, reward float32) int {
+	coinbase := 0
+	for i, t := range transactions {
+		if t.senderBlockchainAddress != MiningSender {
+			continue
+		}
+		coinbase++
+		if coinbase > 1 || t.value > reward+totalFees(transactions) {
+			return i
 		}
+	}
+	return -1
+}
 
-		preBlock = b
-		currentIndex++
+// validBlock checks the block at height of chain against the blocks before it and the local clock
+func (bc *Blockchain) validBlock(chain []*Block, height int) bool {
+	b := chain[height]
+	if b.previousHash != chain[height-1].Hash() {
+		return false
+	}
+	// The headers of a snapshot are trusted through its checkpoint, their transactions are not known
+	if b.pruned {
+		return true
+	}
+	if b.bits != bc.requiredBits(chain[:height]) || !validProof(b.nonce, b.previousHash, b.transactions, b.bits, b.extraData) {
+		return false
+	}
+	if !afterMedianTimePast(chain, height) || invalidCoinbase(b.transactions, bc.emission.Reward(height)) >= 0 {
+		return false
+	}
+	if invalidNameRegistration(b.transactions) >= 0 {
+		return false
+	}
+	for _, t := range b.transactions {
+		if t.IsLocked(height, b.timestamp/int64(time.Second)) {
+			return false
+		}
 	}
 	return true
 }
 
-func (bc *Blockchain) ResolveConflicts() bool {
-	var longestChain []*Block = nil
-	maxLength := len(bc.chain)
-
-	for _, n := range bc.neighbors {
-		endpoint := fmt.Sprintf("http://%s/chain", n)
-		resp, _ := http.Get(endpoint)
-		if resp.StatusCode == 200 {
-			var bcResp Blockchain
-			decoder := json.NewDecoder(resp.Body)
-			_ = decoder.Decode(&bcResp)
-			chain := bcResp.Chain()
-			if len(chain) > maxLength && bc.ValidChain(chain) {
-				maxLength = len(chain)
-				longestChain = chain
+// ResolveConflicts replaces the chain with the valid chain of the neighbors with the most work.
+// Neighbors not asked yet when ctx is done are skipped.
+func (bc *Blockchain) ResolveConflicts(ctx context.Context) bool {
+	var bestChain []*Block = nil
+	bc.mux.Lock()
+	maxWork := chainWork(bc.chain)
+	maxSize := maxChainSize(len(bc.chain))
+	bc.mux.Unlock()
+
+	candidates := make([][]*Block, 0)
+	for _, n := range bc.Neighbors() {
+		if ctx.Err() != nil {
+			break
+		}
+		chain, ok := bc.transport.GetChain(ctx, n, maxSize)
+		if !ok || len(chain) == 0 || chainWork(chain).Cmp(maxWork) <= 0 || bc.rejected.contains(chain[len(chain)-1].Hash()) {
+			continue
+		}
+		candidates = append(candidates, chain)
+	}
+
+	// The candidates are validated in parallel, then the valid one with the most work wins in neighbor order
+	valid := make([]bool, len(candidates))
+	consistent := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, chain := range candidates {
+		wg.Add(1)
+		go func(i int, chain []*Block) {
+			defer wg.Done()
+			consistent[i] = bc.consistentChain(chain)
+			valid[i] = consistent[i] && !bc.aheadOfClock(chain)
+		}(i, chain)
+	}
+	wg.Wait()
+	for i, chain := range candidates {
+		if !valid[i] {
+			// A chain only dated ahead of the local clock is not remembered, it may become valid
+			if !consistent[i] {
+				bc.recordInvalidChain(chain)
 			}
+			continue
+		}
+		if work := chainWork(chain); work.Cmp(maxWork) > 0 {
+			maxWork = work
+			bestChain = chain
 		}
 	}
 
-	if longestChain != nil {
-		bc.chain = longestChain
-		log.Println("Resolve conflicts replaced")
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	// Blocks may have been added while the neighbors were asked
+	if bestChain != nil && maxWork.Cmp(chainWork(bc.chain)) > 0 {
+		bc.recordReorg(bc.chain, bestChain)
+		fork := forkPoint(bc.chain, bestChain)
+		bc.checkConflicts(bestChain[fork:], fork)
+		bc.setChain(bestChain, fork, nil)
+		bc.tipChanged()
+		bc.publishFrom(bestChain, fork)
+		bc.logger.Println("Resolve conflicts replaced")
 		return true
 	}
-	log.Println("Resolve conflicts not replaced")
+	bc.logger.Println("Resolve conflicts not replaced")
 	return false
 }
 
-// Transaction is struct with senderBlockchainAddress, recipientBlockchainAddress, value
+// ReplaceChain replaces the chain with a valid chain, e.g. one imported from a file or built by a test fixture
+func (bc *Blockchain) ReplaceChain(chain []*Block) error {
+	if len(chain) == 0 || !bc.ValidChain(chain) {
+		return ErrChainInvalid
+	}
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.recordReorg(bc.chain, chain)
+	fork := forkPoint(bc.chain, chain)
+	bc.checkConflicts(chain[fork:], fork)
+	bc.setChain(chain, fork, nil)
+	bc.tipChanged()
+	bc.publishFrom(chain, fork)
+	return nil
+}
+
+// Transaction is struct with senderBlockchainAddress, recipientBlockchainAddress, value, lockUntil, name, data, fee, nonce
 type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	lockUntil                  int64
+	name                       string
+	data                       string
+	fee                        float32
+	nonce                      uint64
+	hash                       [32]byte
+	sealed                     bool
 }
 
 // NewTransaction is return a Transaction struct pointer
-func NewTransaction(sender string, recipient string, value float32) *Transaction {
-	return &Transaction{sender, recipient, value}
+func NewTransaction(sender string, recipient string, value float32, lockUntil int64) *Transaction {
+	return &Transaction{
+		senderBlockchainAddress:    sender,
+		recipientBlockchainAddress: recipient,
+		value:                      value,
+		lockUntil:                  lockUntil,
+	}
+}
+
+// Name returns the name registered by the transaction, if any
+func (t *Transaction) Name() string {
+	return t.name
+}
+
+// Data returns the data carried by the transaction, if any
+func (t *Transaction) Data() string {
+	return t.data
+}
+
+// Fee returns the amount paid to the miner on top of the value
+func (t *Transaction) Fee() float32 {
+	return t.fee
+}
+
+// Nonce returns the sender chosen sequence number. A pending transaction can be replaced
+// by one with the same sender and non-zero nonce paying a higher fee.
+func (t *Transaction) Nonce() uint64 {
+	return t.nonce
+}
+
+// LockUntil returns the block height or unix timestamp before which the transaction can not be mined
+func (t *Transaction) LockUntil() int64 {
+	return t.lockUntil
+}
+
+// IsLocked reports whether the transaction can not yet be included in a block at the given height and time.
+// lockUntil below LockTimeThreshold is a block height, otherwise a unix timestamp.
+func (t *Transaction) IsLocked(height int, now int64) bool {
+	if t.lockUntil == 0 {
+		return false
+	}
+	if t.lockUntil < LockTimeThreshold {
+		return int64(height) < t.lockUntil
+	}
+	return now < t.lockUntil
 }
 
 // Print is format Transaction struct and output
@@ -406,66 +1148,155 @@ func (t *Transaction) Print() {
 	fmt.Printf("senderBlockchainAddress:     %s\n", t.senderBlockchainAddress)
 	fmt.Printf("recipientBlockchainAddress:  %s\n", t.recipientBlockchainAddress)
 	fmt.Printf("value:                       %.1f\n", t.value)
+	if t.lockUntil != 0 {
+		fmt.Printf("lockUntil:                   %d\n", t.lockUntil)
+	}
+	if t.name != "" {
+		fmt.Printf("name:                        %s\n", t.name)
+	}
+	if t.data != "" {
+		fmt.Printf("data:                        %s\n", t.data)
+	}
+	if t.fee != 0 {
+		fmt.Printf("fee:                         %g\n", t.fee)
+	}
+	if t.nonce != 0 {
+		fmt.Printf("nonce:                       %d\n", t.nonce)
+	}
 }
 
 // MarshalJSON is marshal Transaction
 func (t *Transaction) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address,omitempty"`
-		Recipient string  `json:"recipient_blockchain_address,omitempty"`
-		Value     float32 `json:"value,omitempty"`
-	}{
+	return json.Marshal(t.fields())
+}
+
+// transactionFields are the JSON fields of a Transaction
+type transactionFields struct {
+	Sender    string  `json:"sender_blockchain_address,omitempty"`
+	Recipient string  `json:"recipient_blockchain_address,omitempty"`
+	Value     float32 `json:"value,omitempty"`
+	LockUntil int64   `json:"lock_until,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	Data      string  `json:"data,omitempty"`
+	Fee       float32 `json:"fee,omitempty"`
+	Nonce     uint64  `json:"nonce,omitempty"`
+}
+
+func (t *Transaction) fields() transactionFields {
+	return transactionFields{
 		t.senderBlockchainAddress,
 		t.recipientBlockchainAddress,
 		t.value,
-	})
+		t.lockUntil,
+		t.name,
+		t.data,
+		t.fee,
+		t.nonce,
+	}
 }
 
-func (t *Transaction) UnmarshalJSON(data []byte) error {
-	v := struct {
-		Sender    *string
-		Recipient *string
-		Value     *float32
-	}{
-		Sender:    &t.senderBlockchainAddress,
-		Recipient: &t.recipientBlockchainAddress,
-		Value:     &t.value,
-	}
+// SigningPayload returns the bytes whose SHA-256 hash the sender signs: the JSON encoding of the transaction
+// after the ID of the chain, so that the signature is not valid on another network
+func (t *Transaction) SigningPayload(chainID string) []byte {
+	m, _ := json.Marshal(struct {
+		ChainID string `json:"chain_id"`
+		transactionFields
+	}{chainID, t.fields()})
+	return m
+}
 
-	if err := json.Unmarshal(data, &v); err != nil {
+// UnmarshalJSON decodes a Transaction with DecodeTransaction
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	d, err := DecodeTransaction(data)
+	if err != nil {
 		return err
 	}
-
+	*t = *d
 	return nil
 }
 
 type TransactionRequest struct {
-	SenderBlockchainAddress    *string  `json:"sender_blockchain_address,omitempty"`
-	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address,omitempty"`
-	SenderPublicKey            *string  `json:"sender_public_key,omitempty"`
-	Value                      *float32 `json:"value,omitempty"`
-	Signature                  *string  `json:"signature,omitempty"`
+	SenderBlockchainAddress    *string   `json:"sender_blockchain_address,omitempty"`
+	RecipientBlockchainAddress *string   `json:"recipient_blockchain_address,omitempty"`
+	SenderPublicKey            *string   `json:"sender_public_key,omitempty"`
+	Value                      *float32  `json:"value,omitempty"`
+	LockUntil                  *int64    `json:"lock_until,omitempty"`
+	Fee                        *float32  `json:"fee,omitempty"`
+	Nonce                      *uint64   `json:"nonce,omitempty"`
+	Name                       *string   `json:"name,omitempty"`
+	Data                       *string   `json:"data,omitempty"`
+	Signature                  *string   `json:"signature,omitempty"`
+	EscrowPublicKeys           *[]string `json:"escrow_public_keys,omitempty"`
+	EscrowSignatures           *[]string `json:"escrow_signatures,omitempty"`
+}
+
+// IsEscrow reports whether the request spends funds from an escrow address
+func (tr *TransactionRequest) IsEscrow() bool {
+	return tr.EscrowPublicKeys != nil
+}
+
+// Escrow returns the Escrow and participant signatures carried by an escrow request
+func (tr *TransactionRequest) Escrow() (*Escrow, []*utils.Signature, error) {
+	e, ok := EscrowFromStrings(*tr.EscrowPublicKeys)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: invalid or repeated escrow public keys", ErrInvalidTransaction)
+	}
+	signatures := make([]*utils.Signature, 0, len(*tr.EscrowSignatures))
+	for _, s := range *tr.EscrowSignatures {
+		signature, err := utils.ParseSignature(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: escrow signature: %v", ErrInvalidSignature, err)
+		}
+		signatures = append(signatures, signature)
+	}
+	return e, signatures, nil
+}
+
+// IsAnchor reports whether the request anchors a document hash
+func (tr *TransactionRequest) IsAnchor() bool {
+	return tr.SenderBlockchainAddress != nil && *tr.SenderBlockchainAddress == AnchorSender
+}
+
+// IsNameRegistration reports whether the request registers a name
+func (tr *TransactionRequest) IsNameRegistration() bool {
+	return tr.Name != nil && *tr.Name != ""
 }
 
 func (tr *TransactionRequest) Validate() bool {
 	if tr.SenderBlockchainAddress == nil ||
 		tr.RecipientBlockchainAddress == nil ||
-		tr.SenderPublicKey == nil ||
-		tr.Value == nil ||
+		tr.Value == nil {
+		return false
+	}
+	if tr.IsEscrow() {
+		return tr.EscrowSignatures != nil
+	}
+	if tr.IsAnchor() {
+		return tr.Data != nil
+	}
+	if tr.SenderPublicKey == nil ||
 		tr.Signature == nil {
 		return false
 	}
 	return true
 }
 
+// AmountResponse is the balance of an address, with the spendable part when confirmations were asked for,
+// and what its pending transactions spend
 type AmountResponse struct {
-	Amount float32 `json:"amount"`
+	Amount    float32  `json:"amount"`
+	Spendable *float32 `json:"spendable,omitempty"`
+	Pending   float32  `json:"pending,omitempty"`
 }
 
 func (ar *AmountResponse) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Amount float32 `json:"amount"`
+		Amount    float32  `json:"amount"`
+		Spendable *float32 `json:"spendable,omitempty"`
+		Pending   float32  `json:"pending,omitempty"`
 	}{
-		Amount: ar.Amount,
+		Amount:    ar.Amount,
+		Spendable: ar.Spendable,
+		Pending:   ar.Pending,
 	})
 }
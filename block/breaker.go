@@ -0,0 +1,59 @@
+package block
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for calls to a neighbor skipped by the circuit breaker
+var ErrCircuitOpen = errors.New("circuit open")
+
+const (
+	// BreakerThreshold is the number of consecutive failed calls after which a peer is skipped
+	BreakerThreshold = 3
+	// BreakerCooldown is how long a peer is skipped before it is tried again
+	BreakerCooldown = 30 * time.Second
+)
+
+// breaker is a circuit breaker per peer. After BreakerThreshold consecutive failures the circuit of
+// the peer opens and calls are skipped for BreakerCooldown, then one call is let through: a success
+// closes the circuit, a failure opens it again.
+type breaker struct {
+	failures  map[string]int
+	openUntil map[string]time.Time
+	now       func() time.Time
+	mux       sync.Mutex
+}
+
+func newBreaker() *breaker {
+	return &breaker{failures: make(map[string]int), openUntil: make(map[string]time.Time), now: time.Now}
+}
+
+// allow reports whether a call to peer may be made
+func (b *breaker) allow(peer string) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	until, ok := b.openUntil[peer]
+	return !ok || !b.now().Before(until)
+}
+
+// success records a successful call to peer and closes its circuit
+func (b *breaker) success(peer string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	delete(b.failures, peer)
+	delete(b.openUntil, peer)
+}
+
+// failure records a failed call to peer and reports whether its circuit is open
+func (b *breaker) failure(peer string) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.failures[peer]++
+	if b.failures[peer] < BreakerThreshold {
+		return false
+	}
+	b.openUntil[peer] = b.now().Add(BreakerCooldown)
+	return true
+}
@@ -0,0 +1,99 @@
+package block
+
+import (
+	"context"
+	"sync"
+)
+
+// BroadcastConcurrency is the number of neighbors a broadcast sends to at once
+const BroadcastConcurrency = 8
+
+// BroadcastResult is the outcome of sending a message to every neighbor, with the error of each neighbor that failed
+type BroadcastResult struct {
+	Peers     int               `json:"peers"`
+	Delivered int               `json:"delivered"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// BroadcastStats counts the broadcasts of the node and the messages they delivered or failed to deliver
+type BroadcastStats struct {
+	Broadcasts uint64 `json:"broadcasts"`
+	Delivered  uint64 `json:"delivered"`
+	Failed     uint64 `json:"failed"`
+}
+
+type broadcastCounter struct {
+	stats BroadcastStats
+	mux   sync.Mutex
+}
+
+func (c *broadcastCounter) record(r BroadcastResult) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stats.Broadcasts++
+	c.stats.Delivered += uint64(r.Delivered)
+	c.stats.Failed += uint64(len(r.Errors))
+}
+
+// BroadcastStats returns the BroadcastStats since the node started
+func (bc *Blockchain) BroadcastStats() BroadcastStats {
+	bc.broadcasts.mux.Lock()
+	defer bc.broadcasts.mux.Unlock()
+	return bc.broadcasts.stats
+}
+
+// broadcast calls send for every neighbor, BroadcastConcurrency at a time, and collects the results.
+// what names the message in the log.
+func (bc *Blockchain) broadcast(ctx context.Context, what string, send func(ctx context.Context, neighbor string) error) BroadcastResult {
+	neighbors := bc.Neighbors()
+	result := BroadcastResult{Peers: len(neighbors)}
+	var mux sync.Mutex
+	g := newGroup(BroadcastConcurrency)
+	for _, n := range neighbors {
+		n := n
+		g.Go(func() {
+			err := send(ctx, n)
+			mux.Lock()
+			defer mux.Unlock()
+			if err != nil {
+				if result.Errors == nil {
+					result.Errors = make(map[string]string)
+				}
+				result.Errors[n] = err.Error()
+				return
+			}
+			result.Delivered++
+		})
+	}
+	g.Wait()
+	bc.broadcasts.record(result)
+	if len(result.Errors) > 0 {
+		bc.logger.Printf("ERROR: %s reached %d of %d neighbors", what, result.Delivered, result.Peers)
+	}
+	return result
+}
+
+func (bc *Blockchain) broadcastTransaction(ctx context.Context, tr *TransactionRequest) BroadcastResult {
+	return bc.broadcast(ctx, "transaction", func(ctx context.Context, n string) error {
+		return bc.transport.SendTransaction(ctx, n, tr)
+	})
+}
+
+// requestConsensus asks the neighbors to resolve conflicts with a new block. It must be called without
+// holding mux, since the neighbors ask for the chain in turn.
+func (bc *Blockchain) requestConsensus(ctx context.Context) BroadcastResult {
+	return bc.broadcast(ctx, "consensus request", bc.transport.RequestConsensus)
+}
+
+// clearNeighborPools tells the neighbors to clear their pools of the transactions of a new block.
+// It must be called without holding mux, so that a slow neighbor does not stall the node.
+func (bc *Blockchain) clearNeighborPools(ctx context.Context) BroadcastResult {
+	return bc.broadcast(ctx, "pool clear", bc.transport.ClearTransactions)
+}
+
+// announceBlock tells the neighbors about a new block: they clear their pools, then resolve conflicts.
+// It must be called without holding mux.
+func (bc *Blockchain) announceBlock(ctx context.Context) {
+	bc.clearNeighborPools(ctx)
+	bc.requestConsensus(ctx)
+}
@@ -0,0 +1,81 @@
+package block
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// chainHeader is the first line of a chain written by WriteChain
+type chainHeader struct {
+	Version int `json:"version"`
+}
+
+// WriteChain writes chain as JSON Lines: a {"version": ChainFormatVersion} header, then one block per line
+func WriteChain(w io.Writer, chain []*Block) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(chainHeader{ChainFormatVersion}); err != nil {
+		return err
+	}
+	for _, b := range chain {
+		if err := encoder.Encode(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadChain reads a chain exported as the JSON document of MarshalJSON or as JSON Lines, with or without
+// the header of WriteChain, and migrates it from the format version it was written in.
+func ReadChain(r io.Reader) ([]*Block, error) {
+	decoder := json.NewDecoder(r)
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		return nil, err
+	}
+	var head struct {
+		Version int              `json:"version"`
+		Chain   *json.RawMessage `json:"chain"`
+		// Every block has a timestamp, the header does not
+		Timestamp *json.RawMessage `json:"timestamp"`
+	}
+	if err := json.Unmarshal(first, &head); err != nil {
+		return nil, err
+	}
+
+	var blocks []json.RawMessage
+	switch {
+	case head.Chain != nil:
+		if err := json.Unmarshal(*head.Chain, &blocks); err != nil {
+			return nil, err
+		}
+	default:
+		if head.Timestamp != nil {
+			blocks = append(blocks, first)
+		}
+		for {
+			var m json.RawMessage
+			if err := decoder.Decode(&m); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("block %d: %w", len(blocks), err)
+			}
+			blocks = append(blocks, m)
+		}
+	}
+
+	blocks, err := MigrateChain(head.Version, blocks)
+	if err != nil {
+		return nil, err
+	}
+	chain := make([]*Block, 0, len(blocks))
+	for _, m := range blocks {
+		b, err := DecodeBlock(bytes.TrimSpace(m))
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", len(chain), err)
+		}
+		chain = append(chain, b)
+	}
+	return chain, nil
+}
@@ -0,0 +1,157 @@
+package block
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time and timers of a Blockchain
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a timer started by a Clock
+type Timer interface {
+	Stop() bool
+}
+
+// Ticker is a ticker started by a Clock. Like a time.Ticker, it drops ticks for slow receivers.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the Clock of the time package
+type SystemClock struct{}
+
+// Now is time.Now
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc is time.AfterFunc
+func (SystemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// NewTicker is time.NewTicker
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// ManualClock is a Clock that only moves when advanced, firing due timers synchronously
+type ManualClock struct {
+	now    time.Time
+	timers []*manualTimer
+	mux    sync.Mutex
+}
+
+type manualTimer struct {
+	clock   *ManualClock
+	at      time.Time
+	f       func()
+	period  time.Duration
+	stopped bool
+}
+
+// NewManualClock returns a ManualClock frozen at now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the frozen time
+func (c *ManualClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// AfterFunc registers f to be called when the clock is advanced by d
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := &manualTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// NewTicker returns a Ticker ticking every time the clock is advanced past a multiple of d
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	ch := make(chan time.Time, 1)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := &manualTimer{clock: c, at: c.now.Add(d), period: d}
+	t.f = func() {
+		select {
+		case ch <- t.at:
+		default:
+		}
+	}
+	c.timers = append(c.timers, t)
+	return &manualTicker{t, ch}
+}
+
+type manualTicker struct {
+	timer *manualTimer
+	c     chan time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Stop() {
+	t.timer.Stop()
+}
+
+// Advance moves the clock forward by d and calls the timers that became due, in order
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	target := c.now.Add(d)
+	c.mux.Unlock()
+	for {
+		c.mux.Lock()
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+		if len(c.timers) == 0 || c.timers[0].at.After(target) {
+			c.now = target
+			c.mux.Unlock()
+			return
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		stopped := t.stopped
+		c.mux.Unlock()
+		if stopped {
+			continue
+		}
+		t.f()
+		if t.period > 0 {
+			// Tickers fire again every period until stopped
+			c.mux.Lock()
+			t.at = t.at.Add(t.period)
+			c.timers = append(c.timers, t)
+			c.mux.Unlock()
+		}
+	}
+}
+
+// Stop prevents the timer from firing
+func (t *manualTimer) Stop() bool {
+	t.clock.mux.Lock()
+	defer t.clock.mux.Unlock()
+	wasActive := !t.stopped
+	t.stopped = true
+	return wasActive
+}
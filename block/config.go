@@ -0,0 +1,164 @@
+package block
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// ConfigEnvPrefix is the prefix of the environment variables read by LoadConfig, followed by the
+// upper-cased JSON name of the field, e.g. BLOCKCHAIN_MINING_INTERVAL
+const ConfigEnvPrefix = "BLOCKCHAIN_"
+
+// Duration is a time.Duration written in JSON as a string such as "20s"
+type Duration time.Duration
+
+// MarshalJSON is marshal Duration
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a Duration from a string accepted by time.ParseDuration
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config is what a node operator can tune without recompiling: the difficulty, reward, interval and worker
+// goroutines of mining, the interval of the neighbor sync and the address offsets and ports scanned for neighbors.
+type Config struct {
+	Difficulty        int      `json:"difficulty"`
+	Reward            float32  `json:"reward"`
+	MiningInterval    Duration `json:"mining_interval"`
+	MiningWorkers     int      `json:"mining_workers"`
+	SyncInterval      Duration `json:"sync_interval"`
+	NeighborIPStart   uint8    `json:"neighbor_ip_start"`
+	NeighborIPEnd     uint8    `json:"neighbor_ip_end"`
+	NeighborPortStart uint16   `json:"neighbor_port_start"`
+	NeighborPortEnd   uint16   `json:"neighbor_port_end"`
+}
+
+// DefaultConfig returns the Config of the package defaults, a mining worker per CPU
+func DefaultConfig() Config {
+	return Config{
+		Difficulty:        MiningDifficulty,
+		Reward:            MiningReward,
+		MiningInterval:    Duration(MiningTimerSec * time.Second),
+		MiningWorkers:     runtime.NumCPU(),
+		SyncInterval:      Duration(BlockchainNeiborSyncTimeSec * time.Second),
+		NeighborIPStart:   NeighborIpRangeStart,
+		NeighborIPEnd:     NeighborIpRangeEnd,
+		NeighborPortStart: BlockchainPortRangeStart,
+		NeighborPortEnd:   BlockchainPortRangeEnd,
+	}
+}
+
+// LoadConfig returns the DefaultConfig overridden by the fields of the JSON file at path, if not empty,
+// and then by the environment variables of ConfigEnvPrefix that are set
+func LoadConfig(path string) (Config, error) {
+	c := DefaultConfig()
+	if path != "" {
+		m, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		d := json.NewDecoder(bytes.NewReader(m))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&c); err != nil {
+			return c, fmt.Errorf("config %s: %v", path, err)
+		}
+	}
+	if err := c.loadEnv(); err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+// loadEnv sets the fields whose environment variable is set
+func (c *Config) loadEnv() error {
+	parsers := []struct {
+		name  string
+		parse func(s string) error
+	}{
+		{"DIFFICULTY", func(s string) (err error) { c.Difficulty, err = strconv.Atoi(s); return }},
+		{"REWARD", func(s string) error { return parseFloat32(s, &c.Reward) }},
+		{"MINING_INTERVAL", func(s string) error { return parseDuration(s, &c.MiningInterval) }},
+		{"MINING_WORKERS", func(s string) (err error) { c.MiningWorkers, err = strconv.Atoi(s); return }},
+		{"SYNC_INTERVAL", func(s string) error { return parseDuration(s, &c.SyncInterval) }},
+		{"NEIGHBOR_IP_START", func(s string) error { return parseUint8(s, &c.NeighborIPStart) }},
+		{"NEIGHBOR_IP_END", func(s string) error { return parseUint8(s, &c.NeighborIPEnd) }},
+		{"NEIGHBOR_PORT_START", func(s string) error { return parseUint16(s, &c.NeighborPortStart) }},
+		{"NEIGHBOR_PORT_END", func(s string) error { return parseUint16(s, &c.NeighborPortEnd) }},
+	}
+	for _, p := range parsers {
+		s, ok := os.LookupEnv(ConfigEnvPrefix + p.name)
+		if !ok {
+			continue
+		}
+		if err := p.parse(s); err != nil {
+			return fmt.Errorf("%s%s: %v", ConfigEnvPrefix, p.name, err)
+		}
+	}
+	return nil
+}
+
+func parseFloat32(s string, v *float32) error {
+	f, err := strconv.ParseFloat(s, 32)
+	*v = float32(f)
+	return err
+}
+
+func parseDuration(s string, v *Duration) error {
+	d, err := time.ParseDuration(s)
+	*v = Duration(d)
+	return err
+}
+
+func parseUint8(s string, v *uint8) error {
+	n, err := strconv.ParseUint(s, 10, 8)
+	*v = uint8(n)
+	return err
+}
+
+func parseUint16(s string, v *uint16) error {
+	n, err := strconv.ParseUint(s, 10, 16)
+	*v = uint16(n)
+	return err
+}
+
+// Validate returns an error for a Config a node can not run with
+func (c Config) Validate() error {
+	switch {
+	case c.Difficulty < 1 || c.Difficulty > 64:
+		return fmt.Errorf("config: difficulty %d is not between 1 and 64", c.Difficulty)
+	case c.Reward < 0:
+		return fmt.Errorf("config: negative reward %g", c.Reward)
+	case c.MiningInterval <= 0:
+		return fmt.Errorf("config: mining interval %v is not positive", time.Duration(c.MiningInterval))
+	case c.MiningWorkers < 1:
+		return fmt.Errorf("config: %d mining workers, at least one is needed", c.MiningWorkers)
+	case c.SyncInterval <= 0:
+		return fmt.Errorf("config: sync interval %v is not positive", time.Duration(c.SyncInterval))
+	case c.NeighborIPStart > c.NeighborIPEnd:
+		return fmt.Errorf("config: neighbor ip range %d-%d is empty", c.NeighborIPStart, c.NeighborIPEnd)
+	case c.NeighborPortStart > c.NeighborPortEnd:
+		return fmt.Errorf("config: neighbor port range %d-%d is empty", c.NeighborPortStart, c.NeighborPortEnd)
+	case c.NeighborPortEnd == math.MaxUint16:
+		return fmt.Errorf("config: neighbor port range must end below %d", math.MaxUint16)
+	}
+	return nil
+}
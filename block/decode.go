@@ -0,0 +1,165 @@
+package block
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxBlockSize is the largest encoded block accepted from peers
+	MaxBlockSize = 1 << 20
+	// ChainSizeMargin is how many blocks of MaxBlockSize a chain accepted from peers may have beyond the local chain
+	ChainSizeMargin = 100
+	// MaxBlockTransactions is the largest number of transactions in a block accepted from peers
+	MaxBlockTransactions = 10000
+	// MaxTransactionDataSize is the largest data payload of a transaction
+	MaxTransactionDataSize = 1024
+	// MaxExtraDataSize is the largest extra data a miner tags a block with
+	MaxExtraDataSize = 64
+)
+
+// maxChainSize returns the largest encoded chain accepted from peers by a node whose chain has height blocks
+func maxChainSize(height int) int64 {
+	return int64(height+ChainSizeMargin) * MaxBlockSize
+}
+
+// decodeStrict decodes exactly one JSON value without unknown fields into v
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("trailing data after JSON value")
+	}
+	return nil
+}
+
+// DecodeTransaction decodes and validates a JSON encoded Transaction
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	var v struct {
+		Sender    *string  `json:"sender_blockchain_address"`
+		Recipient *string  `json:"recipient_blockchain_address"`
+		Value     *float32 `json:"value"`
+		LockUntil *int64   `json:"lock_until"`
+		Name      *string  `json:"name"`
+		Data      *string  `json:"data"`
+		Fee       *float32 `json:"fee"`
+		Nonce     *uint64  `json:"nonce"`
+	}
+	if err := decodeStrict(data, &v); err != nil {
+		return nil, fmt.Errorf("decode transaction: %w", err)
+	}
+	if v.Sender == nil || *v.Sender == "" {
+		return nil, errors.New("decode transaction: missing sender_blockchain_address")
+	}
+	if v.Recipient == nil || *v.Recipient == "" {
+		return nil, errors.New("decode transaction: missing recipient_blockchain_address")
+	}
+	t := NewTransaction(*v.Sender, *v.Recipient, 0, 0)
+	if v.Value != nil {
+		if *v.Value < 0 {
+			return nil, errors.New("decode transaction: negative value")
+		}
+		t.value = *v.Value
+	}
+	if v.LockUntil != nil {
+		if *v.LockUntil < 0 {
+			return nil, errors.New("decode transaction: negative lock_until")
+		}
+		t.lockUntil = *v.LockUntil
+	}
+	if v.Name != nil && *v.Name != "" {
+		if !ValidName(*v.Name) {
+			return nil, errors.New("decode transaction: invalid name")
+		}
+		t.name = *v.Name
+	}
+	if v.Data != nil {
+		if len(*v.Data) > MaxTransactionDataSize {
+			return nil, errors.New("decode transaction: data too large")
+		}
+		t.data = *v.Data
+	}
+	if v.Fee != nil {
+		if *v.Fee < 0 {
+			return nil, errors.New("decode transaction: negative fee")
+		}
+		t.fee = *v.Fee
+	}
+	if v.Nonce != nil {
+		t.nonce = *v.Nonce
+	}
+	return t.seal(), nil
+}
+
+// DecodeBlock decodes and validates a JSON encoded Block
+func DecodeBlock(data []byte) (*Block, error) {
+	if len(data) > MaxBlockSize {
+		return nil, errors.New("decode block: block too large")
+	}
+	var v struct {
+		Timestamp    *int64            `json:"timestamp"`
+		Nonce        *int              `json:"nonce"`
+		PreviousHash *string           `json:"previous_hash"`
+		Bits         uint32            `json:"bits"`
+		ExtraData    string            `json:"extra_data"`
+		MerkleRoot   string            `json:"merkle_root"`
+		Transactions []json.RawMessage `json:"transactions"`
+		// Height and hash are added by explorers, see BlockView
+		Height *int   `json:"height"`
+		Hash   string `json:"hash"`
+	}
+	if err := decodeStrict(data, &v); err != nil {
+		return nil, fmt.Errorf("decode block: %w", err)
+	}
+	if v.Timestamp == nil || v.Nonce == nil || v.PreviousHash == nil {
+		return nil, errors.New("decode block: missing field(s)")
+	}
+	if *v.Timestamp < 0 || *v.Nonce < 0 {
+		return nil, errors.New("decode block: negative timestamp or nonce")
+	}
+	ph, err := hex.DecodeString(*v.PreviousHash)
+	if err != nil || len(ph) != 32 {
+		return nil, errors.New("decode block: previous_hash must be 32 hex encoded bytes")
+	}
+	if len(v.ExtraData) > MaxExtraDataSize {
+		return nil, errors.New("decode block: extra data too large")
+	}
+	if len(v.Transactions) > MaxBlockTransactions {
+		return nil, errors.New("decode block: too many transactions")
+	}
+
+	b := &Block{timestamp: *v.Timestamp, nonce: *v.Nonce, bits: v.Bits, extraData: v.ExtraData}
+	copy(b.previousHash[:], ph)
+	// A null transaction list (the genesis block) stays nil so that the block hash is unchanged
+	if v.Transactions != nil {
+		b.transactions = make([]*Transaction, 0, len(v.Transactions))
+	}
+	for i, m := range v.Transactions {
+		t, err := DecodeTransaction(m)
+		if err != nil {
+			return nil, fmt.Errorf("decode block: transaction %d: %w", i, err)
+		}
+		b.transactions = append(b.transactions, t)
+	}
+	// Blocks made before blocks carried the merkle root have none
+	if v.MerkleRoot != "" {
+		root, err := ParseTxID(v.MerkleRoot)
+		if err != nil {
+			return nil, fmt.Errorf("decode block: merkle root: %w", err)
+		}
+		if root != MerkleRoot(b.transactions) {
+			return nil, errors.New("decode block: merkle root does not match the transactions")
+		}
+		b.merkleRoot = root
+	}
+	if v.Hash != "" && v.Hash != hexHash(b.Hash()) {
+		return nil, errors.New("decode block: hash does not match the block")
+	}
+	return b, nil
+}
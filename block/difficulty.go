@@ -0,0 +1,290 @@
+package block
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// DifficultyToBits returns the compact target of a difficulty counted in leading hex zeros,
+// that is a target of 16^(64-difficulty).
+func DifficultyToBits(difficulty int) uint32 {
+	return TargetToBits(new(big.Int).Lsh(big.NewInt(1), uint(256-4*difficulty)))
+}
+
+// BitsToTarget decodes a compact target: the high byte is the size of the target in bytes
+// and the low three bytes are its most significant bytes.
+func BitsToTarget(bits uint32) *big.Int {
+	mantissa := bits & 0x007fffff
+	exponent := uint(bits >> 24)
+	if exponent <= 3 {
+		return big.NewInt(int64(mantissa >> (8 * (3 - exponent))))
+	}
+	t := big.NewInt(int64(mantissa))
+	return t.Lsh(t, 8*(exponent-3))
+}
+
+// TargetToBits encodes target in the compact form read by BitsToTarget.
+// Precision beyond the three most significant bytes is lost.
+func TargetToBits(target *big.Int) uint32 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+	exponent := uint(len(target.Bytes()))
+	var mantissa uint32
+	if exponent <= 3 {
+		mantissa = uint32(target.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(target, 8*(exponent-3)).Uint64())
+	}
+	// The sign bit of the mantissa must stay clear
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	return uint32(exponent<<24) | mantissa
+}
+
+// BitsToDifficulty returns the difficulty of bits counted in leading hex zeros, the inverse of
+// DifficultyToBits, with a fraction for targets between two whole difficulties.
+func BitsToDifficulty(bits uint32) float64 {
+	t, _ := new(big.Float).SetInt(BitsToTarget(bits)).Float64()
+	if t <= 0 {
+		return 64
+	}
+	return (256 - math.Log2(t)) / 4
+}
+
+// meetsTarget reports whether hash, read as a big-endian number, is below the target of bits
+func meetsTarget(hash [32]byte, bits uint32) bool {
+	return new(big.Int).SetBytes(hash[:]).Cmp(BitsToTarget(bits)) < 0
+}
+
+// targetString returns the highest hash meeting the target of bits as 64 hex digits
+func targetString(bits uint32) string {
+	t := BitsToTarget(bits)
+	if t.Sign() == 0 {
+		return fmt.Sprintf("%064x", 0)
+	}
+	return fmt.Sprintf("%064x", t.Sub(t, big.NewInt(1)))
+}
+
+// blockWork returns the expected number of hashes to meet the target of bits, 2^256 / (target + 1)
+func blockWork(bits uint32) *big.Int {
+	target := BitsToTarget(bits)
+	if bits == 0 || target.Sign() < 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), target.Add(target, big.NewInt(1)))
+}
+
+// chainWork returns the sum of the blockWork of the blocks of chain, what the chain with the most of it
+// wins by rather than by its length
+func chainWork(chain []*Block) *big.Int {
+	work := new(big.Int)
+	for _, b := range chain {
+		work.Add(work, blockWork(b.bits))
+	}
+	return work
+}
+
+// requiredBits returns the compact target the block following chain must carry
+func (bc *Blockchain) requiredBits(chain []*Block) uint32 {
+	if len(chain) == 0 {
+		return 0
+	}
+	return bc.retarget.NextBits(chain, DifficultyToBits(bc.difficulty))
+}
+
+// NextBits returns the compact target the next block must carry, as adjusted by the retarget algorithm
+func (bc *Blockchain) NextBits() uint32 {
+	chain, _ := bc.view()
+	return bc.requiredBits(chain)
+}
+
+const (
+	// TargetBlockSpacing is the block interval the retarget algorithms aim for by default
+	TargetBlockSpacing = MiningTimerSec * time.Second
+	// SMAWindow is the number of blocks averaged by SMARetarget
+	SMAWindow = 10
+	// ASERTHalfLife is the time ahead of or behind schedule that doubles or halves the ASERTRetarget target,
+	// in block intervals
+	ASERTHalfLife = 10
+)
+
+// DifficultyPoint is the difficulty of a mined block and the time since the block before it
+type DifficultyPoint struct {
+	Height     int     `json:"height"`
+	Bits       uint32  `json:"bits"`
+	Difficulty float64 `json:"difficulty"`
+	Interval   float64 `json:"interval"`
+}
+
+// DifficultyHistory returns the DifficultyPoint of the last blocks mined, oldest first.
+// Intervals are in seconds.
+func (bc *Blockchain) DifficultyHistory(last int) []DifficultyPoint {
+	chain, _ := bc.view()
+	// The genesis block carries no target
+	from := len(chain) - last
+	if from < 1 {
+		from = 1
+	}
+	points := make([]DifficultyPoint, 0, len(chain)-from)
+	for i := from; i < len(chain); i++ {
+		b := chain[i]
+		points = append(points, DifficultyPoint{
+			Height:     i,
+			Bits:       b.bits,
+			Difficulty: BitsToDifficulty(b.bits),
+			Interval:   time.Duration(b.timestamp - chain[i-1].timestamp).Seconds(),
+		})
+	}
+	return points
+}
+
+// Retarget computes the compact target of the block following chain. initialBits is the
+// target of the first mined block, derived from the configured difficulty.
+type Retarget interface {
+	NextBits(chain []*Block, initialBits uint32) uint32
+}
+
+// ParseRetarget returns the Retarget named fixed, sma or asert aiming for TargetBlockSpacing
+func ParseRetarget(name string) (Retarget, error) {
+	return NewRetarget(name, TargetBlockSpacing)
+}
+
+// NewRetarget returns the Retarget named fixed, sma or asert aiming for a block every spacing
+func NewRetarget(name string, spacing time.Duration) (Retarget, error) {
+	if spacing <= 0 {
+		return nil, fmt.Errorf("invalid block interval %s", spacing)
+	}
+	switch name {
+	case "fixed", "":
+		return FixedRetarget{}, nil
+	case "sma":
+		return SMARetarget{Window: SMAWindow, Spacing: spacing}, nil
+	case "asert":
+		return ASERTRetarget{HalfLife: ASERTHalfLife * spacing, Spacing: spacing}, nil
+	}
+	return nil, fmt.Errorf("unknown retarget algorithm %q", name)
+}
+
+// RetargetSpacing returns the block interval r aims for, TargetBlockSpacing for algorithms without one
+func RetargetSpacing(r Retarget) time.Duration {
+	switch r := r.(type) {
+	case SMARetarget:
+		return r.Spacing
+	case ASERTRetarget:
+		return r.Spacing
+	}
+	return TargetBlockSpacing
+}
+
+// RetargetName returns the name ParseRetarget accepts for r, or "custom"
+func RetargetName(r Retarget) string {
+	switch r.(type) {
+	case FixedRetarget, nil:
+		return "fixed"
+	case SMARetarget:
+		return "sma"
+	case ASERTRetarget:
+		return "asert"
+	}
+	return "custom"
+}
+
+// FixedRetarget keeps every block at the initial target
+type FixedRetarget struct{}
+
+func (FixedRetarget) NextBits(chain []*Block, initialBits uint32) uint32 {
+	return initialBits
+}
+
+// SMARetarget scales the mean target of the last Window blocks by how far their
+// block interval was from Spacing, at most by a factor of 4 per block.
+// Like ASERTRetarget it relies on ValidChain to bound the timestamps it averages.
+type SMARetarget struct {
+	Window  int
+	Spacing time.Duration
+}
+
+func (r SMARetarget) NextBits(chain []*Block, initialBits uint32) uint32 {
+	// The genesis block carries no target
+	mined := chain[1:]
+	if len(mined) > r.Window {
+		mined = mined[len(mined)-r.Window:]
+	}
+	if len(mined) < 2 {
+		return initialBits
+	}
+	sum := new(big.Int)
+	for _, b := range mined {
+		sum.Add(sum, BitsToTarget(b.bits))
+	}
+	intervals := int64(len(mined) - 1)
+	actual := mined[len(mined)-1].timestamp - mined[0].timestamp
+	expected := intervals * int64(r.Spacing)
+	if actual < expected/4 {
+		actual = expected / 4
+	}
+	if actual > expected*4 {
+		actual = expected * 4
+	}
+	target := sum.Div(sum, big.NewInt(int64(len(mined))))
+	target.Mul(target, big.NewInt(actual))
+	target.Div(target, big.NewInt(expected))
+	return clampTarget(target)
+}
+
+// ASERTRetarget sets the target from the first mined block, the anchor: it doubles for every
+// HalfLife the chain tip is behind the schedule of one block per Spacing and halves for every
+// HalfLife ahead. Unlike SMARetarget it does not oscillate on uneven hashrate.
+// It trusts the timestamp of the tip, which ValidChain keeps after the median time past and at most
+// MaxFutureBlocks of its Spacing ahead of the local clock, so a tip eases the target by at most
+// 2^(MaxFutureBlocks/ASERTHalfLife).
+type ASERTRetarget struct {
+	HalfLife time.Duration
+	Spacing  time.Duration
+}
+
+func (r ASERTRetarget) NextBits(chain []*Block, initialBits uint32) uint32 {
+	if len(chain) < 2 {
+		return initialBits
+	}
+	anchor := chain[1]
+	tip := chain[len(chain)-1]
+	drift := tip.timestamp - anchor.timestamp - int64(len(chain)-2)*int64(r.Spacing)
+	exponent := float64(drift) / float64(r.HalfLife)
+
+	// 2^exponent as an integer shift and a 16 bit fixed point factor for the fraction
+	shift := math.Floor(exponent)
+	factor := int64(math.Round(math.Exp2(exponent-shift) * (1 << 16)))
+	target := BitsToTarget(initialBits)
+	target.Mul(target, big.NewInt(factor))
+	if n := int(shift) - 16; n >= 0 {
+		target.Lsh(target, uint(minInt(n, 256)))
+	} else {
+		target.Rsh(target, uint(minInt(-n, 256)))
+	}
+	return clampTarget(target)
+}
+
+// clampTarget encodes target, keeping it between 1 and the target of RegtestDifficulty
+func clampTarget(target *big.Int) uint32 {
+	limit := BitsToTarget(DifficultyToBits(RegtestDifficulty))
+	if target.Cmp(limit) > 0 {
+		target = limit
+	}
+	if target.Sign() <= 0 {
+		target = big.NewInt(1)
+	}
+	return TargetToBits(target)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,90 @@
+package block
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxClockDrift is the clock drift reported as too large unless configured otherwise. Beyond a few
+// block intervals the timestamps of the blocks mined here are out of order with those of the network,
+// which skews retargeting and the time based lock of transactions.
+const DefaultMaxClockDrift = 3 * TargetBlockSpacing
+
+// ClockDrift compares the local clock with the network, in seconds. PeerOffset is the median of how far
+// the clocks of Peers neighbors are ahead, TipOffset how far the timestamp of the chain tip is ahead,
+// 0 when it is in the past as it should. Drift is the larger of both, Exceeded whether it is over Threshold.
+type ClockDrift struct {
+	PeerOffset float64 `json:"peer_offset"`
+	Peers      int     `json:"peers"`
+	TipOffset  float64 `json:"tip_offset"`
+	Drift      float64 `json:"drift"`
+	Threshold  float64 `json:"threshold"`
+	Exceeded   bool    `json:"exceeded"`
+}
+
+// driftMonitor calls alert when the clock drift goes over threshold, once until it is back under it
+type driftMonitor struct {
+	threshold time.Duration
+	alert     func(ClockDrift)
+	exceeded  bool
+	mux       sync.Mutex
+}
+
+// ClockDrift returns the ClockDrift measured in the last handshakes with the neighbors and from the chain tip
+func (bc *Blockchain) ClockDrift() ClockDrift {
+	now := bc.clock.Now()
+	chain, _ := bc.view()
+	bc.muxNeighbors.Lock()
+	offsets := make([]time.Duration, 0, len(bc.peers))
+	for _, n := range bc.neighbors {
+		if ni, ok := bc.peers[n]; ok && ni.Time != 0 {
+			offsets = append(offsets, ni.offset)
+		}
+	}
+	bc.muxNeighbors.Unlock()
+
+	d := ClockDrift{Peers: len(offsets), Threshold: bc.drift.threshold.Seconds()}
+	if len(offsets) > 0 {
+		sort.Slice(offsets, func(i, j int) bool {
+			return offsets[i] < offsets[j]
+		})
+		median := offsets[len(offsets)/2]
+		if len(offsets)%2 == 0 {
+			median = (offsets[len(offsets)/2-1] + median) / 2
+		}
+		d.PeerOffset = median.Seconds()
+	}
+	// The genesis block is not mined on the network clock
+	if len(chain) > 1 {
+		if ahead := time.Unix(0, chain[len(chain)-1].timestamp).Sub(now); ahead > 0 {
+			d.TipOffset = ahead.Seconds()
+		}
+	}
+	d.Drift = math.Max(math.Abs(d.PeerOffset), d.TipOffset)
+	d.Exceeded = d.Drift > d.Threshold
+	return d
+}
+
+// checkClockDrift logs and alerts when the clock drift goes over the threshold, and logs when it is back
+func (bc *Blockchain) checkClockDrift() {
+	d := bc.ClockDrift()
+	m := &bc.drift
+	m.mux.Lock()
+	changed := d.Exceeded != m.exceeded
+	m.exceeded = d.Exceeded
+	alert := m.alert
+	m.mux.Unlock()
+	if !changed {
+		return
+	}
+	if !d.Exceeded {
+		bc.logger.Printf("Clock drift %.1fs is back under %.1fs", d.Drift, d.Threshold)
+		return
+	}
+	bc.logger.Printf("WARNING: clock drift %.1fs exceeds %.1fs (peers %+.1fs, chain tip %+.1fs)", d.Drift, d.Threshold, d.PeerOffset, d.TipOffset)
+	if alert != nil {
+		alert(d)
+	}
+}
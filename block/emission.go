@@ -0,0 +1,95 @@
+package block
+
+import "math"
+
+// MinReward is the smallest mining reward paid; once halvings push the reward below it, mining pays nothing
+const MinReward = 1e-8
+
+// Emission is the schedule of mining rewards: InitialReward for the first HalvingInterval
+// blocks after the genesis block, then halved every HalvingInterval blocks.
+// A HalvingInterval of 0 pays InitialReward forever.
+type Emission struct {
+	InitialReward   float32 `json:"initial_reward"`
+	HalvingInterval int     `json:"halving_interval"`
+}
+
+// DefaultEmission pays MiningReward for every block without halvings
+var DefaultEmission = Emission{InitialReward: MiningReward}
+
+// Reward returns the mining reward of the block at height
+func (e Emission) Reward(height int) float32 {
+	if e.HalvingInterval <= 0 || height < 1 {
+		return e.InitialReward
+	}
+	r := float64(e.InitialReward) / math.Exp2(float64((height-1)/e.HalvingInterval))
+	if r < MinReward {
+		return 0
+	}
+	return float32(r)
+}
+
+// NextHalving returns the height of the first block after height with a lower reward, or 0 without halvings
+func (e Emission) NextHalving(height int) int {
+	if e.HalvingInterval <= 0 || e.Reward(height) == 0 {
+		return 0
+	}
+	if height < 1 {
+		height = 1
+	}
+	return ((height-1)/e.HalvingInterval+1)*e.HalvingInterval + 1
+}
+
+// Minted returns the total amount mining has paid to a chain of height blocks, the genesis block paying nothing.
+// It sums the rewards era by era instead of scanning the blocks.
+func (e Emission) Minted(height int) float64 {
+	blocks := height - 1
+	if blocks <= 0 {
+		return 0
+	}
+	if e.HalvingInterval <= 0 {
+		return float64(e.InitialReward) * float64(blocks)
+	}
+	var minted float64
+	for first := 1; first <= blocks; first += e.HalvingInterval {
+		r := e.Reward(first)
+		if r == 0 {
+			break
+		}
+		n := e.HalvingInterval
+		if rest := blocks - first + 1; rest < n {
+			n = rest
+		}
+		minted += float64(r) * float64(n)
+	}
+	return minted
+}
+
+// ProjectedSupply returns the total amount mining will ever pay, false when it is unbounded
+func (e Emission) ProjectedSupply() (float64, bool) {
+	if e.HalvingInterval <= 0 {
+		return 0, e.InitialReward == 0
+	}
+	var supply float64
+	for r := float64(e.InitialReward); r >= MinReward; r /= 2 {
+		supply += r * float64(e.HalvingInterval)
+	}
+	return supply, true
+}
+
+// SetEmission replaces the mining reward schedule, DefaultEmission by default.
+// All nodes of a network must use the same one.
+func (bc *Blockchain) SetEmission(e Emission) {
+	bc.emission = e
+}
+
+// Emission returns the mining reward schedule
+func (bc *Blockchain) Emission() Emission {
+	return bc.emission
+}
+
+// Reward returns the mining reward of the next block
+func (bc *Blockchain) Reward() float32 {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.emission.Reward(len(bc.chain))
+}
@@ -0,0 +1,29 @@
+package block
+
+import "errors"
+
+// Validation failures returned by the Blockchain APIs, possibly wrapped with details.
+// Compare them with errors.Is.
+var (
+	// ErrInvalidTransaction is returned for a malformed transaction or request
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	// ErrInvalidAddress is returned for a malformed address, or a sender address that does not match its key
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrInvalidSignature is returned for a signature that does not verify
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrInsufficientBalance is returned when the sender can not pay the value and the fee
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrDuplicateTransaction is returned for a transaction already in the pool or the chain, a nonce
+	// already used in the chain, or a replacement that does not pay a higher fee
+	ErrDuplicateTransaction = errors.New("duplicate transaction")
+	// ErrRateLimited is returned for a transaction paying no fee, e.g. an anchor, while too many are pending
+	ErrRateLimited = errors.New("rate limited")
+	// ErrNameTaken is returned for a name registered or pending registration
+	ErrNameTaken = errors.New("name already taken")
+	// ErrChainInvalid is returned for a chain that fails validation
+	ErrChainInvalid = errors.New("invalid chain")
+	// ErrInvalidPeer is returned for a peer address that is not host:port
+	ErrInvalidPeer = errors.New("invalid peer address")
+	// ErrUnknownPeer is returned for a peer that is not a neighbor
+	ErrUnknownPeer = errors.New("unknown peer")
+)
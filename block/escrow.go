@@ -0,0 +1,88 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hirasawayuki/block_chain/address"
+	"github.com/hirasawayuki/block_chain/utils"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// EscrowAddressVersion is the version byte of escrow addresses
+	EscrowAddressVersion = 0x05
+	// EscrowRequiredSignatures is the number of participant signatures needed to spend escrowed funds
+	EscrowRequiredSignatures = 2
+)
+
+// Escrow is a 2-of-3 multi-signature construct with buyer, seller and arbiter public keys.
+// Funds sent to the escrow address can only be spent with signatures of two of the participants.
+type Escrow struct {
+	publicKeys [3]*ecdsa.PublicKey
+}
+
+// NewEscrow returns an Escrow for the buyer, seller and arbiter public keys
+func NewEscrow(buyer *ecdsa.PublicKey, seller *ecdsa.PublicKey, arbiter *ecdsa.PublicKey) *Escrow {
+	return &Escrow{[3]*ecdsa.PublicKey{buyer, seller, arbiter}}
+}
+
+// EscrowFromStrings returns an Escrow from buyer, seller and arbiter public key strings.
+// The keys must be distinct: a participant holding two of them could spend the funds alone.
+func EscrowFromStrings(publicKeys []string) (*Escrow, bool) {
+	if len(publicKeys) != 3 {
+		return nil, false
+	}
+	e := new(Escrow)
+	for i, k := range publicKeys {
+		if len(k) != 128 {
+			return nil, false
+		}
+		e.publicKeys[i] = utils.PublicKeyFromString(k)
+		for _, o := range e.publicKeys[:i] {
+			if o.X.Cmp(e.publicKeys[i].X) == 0 && o.Y.Cmp(e.publicKeys[i].Y) == 0 {
+				return nil, false
+			}
+		}
+	}
+	return e, true
+}
+
+// PublicKeyStrs returns the participant public keys as strings in buyer, seller, arbiter order
+func (e *Escrow) PublicKeyStrs() []string {
+	keys := make([]string, 0, len(e.publicKeys))
+	for _, k := range e.publicKeys {
+		keys = append(keys, fmt.Sprintf("%064x%064x", k.X.Bytes(), k.Y.Bytes()))
+	}
+	return keys
+}
+
+// Address returns the base58check escrow address derived from the participant public keys
+func (e *Escrow) Address() string {
+	h := sha256.New()
+	for _, k := range e.PublicKeyStrs() {
+		h.Write([]byte(k))
+	}
+	r := ripemd160.New()
+	r.Write(h.Sum(nil))
+	return address.FromHash(r.Sum(nil), EscrowAddressVersion)
+}
+
+// VerifySignatures reports whether at least EscrowRequiredSignatures distinct participants signed the transaction
+// for the chain chainID. Each signature counts for one participant only.
+func (e *Escrow) VerifySignatures(t *Transaction, chainID string, signatures []*utils.Signature) bool {
+	h := sha256.Sum256(t.SigningPayload(chainID))
+	used := make([]bool, len(signatures))
+	signed := 0
+	for _, k := range e.publicKeys {
+		for i, s := range signatures {
+			if !used[i] && ecdsa.Verify(k, h[:], s.R, s.S) {
+				used[i] = true
+				signed++
+				break
+			}
+		}
+	}
+	return signed >= EscrowRequiredSignatures
+}
@@ -0,0 +1,165 @@
+package block
+
+import "sync"
+
+// EventBufferSize is the number of events buffered per subscriber. Events for
+// subscribers that fall further behind are dropped.
+const EventBufferSize = 64
+
+// Event is a BlockEvent, a ReorgEvent or a TransactionEvent
+type Event interface {
+	// Kind returns "block", "reorg" or "transaction"
+	Kind() string
+}
+
+// BlockEvent announces a block that became part of the chain at Height,
+// either mined, received or adopted in a reorg.
+type BlockEvent struct {
+	Height int
+	Block  *Block
+}
+
+// Kind returns "block"
+func (e BlockEvent) Kind() string {
+	return "block"
+}
+
+// ReorgEvent announces that the chain was replaced by one forking from it at height Fork, so that
+// Depth blocks left the chain. Dropped are the txids of their transactions the new chain does not
+// confirm and Readded those it confirms again in its own blocks. A BlockEvent follows for every
+// block of the new chain from Fork on.
+type ReorgEvent struct {
+	OldTip    string   `json:"old_tip"`
+	OldHeight int      `json:"old_height"`
+	NewTip    string   `json:"new_tip"`
+	NewHeight int      `json:"new_height"`
+	Fork      int      `json:"fork"`
+	Depth     int      `json:"depth"`
+	Dropped   []string `json:"dropped"`
+	Readded   []string `json:"readded"`
+}
+
+// Kind returns "reorg"
+func (e ReorgEvent) Kind() string {
+	return "reorg"
+}
+
+// TransactionEvent announces a transaction accepted into the pool, including a replacement
+type TransactionEvent struct {
+	TxID        string       `json:"txid"`
+	Transaction *Transaction `json:"transaction"`
+}
+
+// Kind returns "transaction"
+func (e TransactionEvent) Kind() string {
+	return "transaction"
+}
+
+// newReorgEvent returns the ReorgEvent of replacing old with chain, forking at fork
+func newReorgEvent(old []*Block, chain []*Block, fork int) ReorgEvent {
+	confirmed := make(map[[32]byte]bool)
+	for _, b := range chain[fork:] {
+		for _, t := range b.transactions {
+			confirmed[t.Hash()] = true
+		}
+	}
+	e := ReorgEvent{
+		OldTip:    hexHash(old[len(old)-1].Hash()),
+		OldHeight: len(old) - 1,
+		NewTip:    hexHash(chain[len(chain)-1].Hash()),
+		NewHeight: len(chain) - 1,
+		Fork:      fork,
+		Depth:     len(old) - fork,
+		Dropped:   make([]string, 0),
+		Readded:   make([]string, 0),
+	}
+	for _, b := range old[fork:] {
+		for _, t := range b.transactions {
+			if h := t.Hash(); confirmed[h] {
+				e.Readded = append(e.Readded, hexHash(h))
+			} else {
+				e.Dropped = append(e.Dropped, hexHash(h))
+			}
+		}
+	}
+	return e
+}
+
+// Addresses returns the blockchain addresses whose balance the block changes
+func (e BlockEvent) Addresses() []string {
+	seen := make(map[string]bool)
+	addresses := make([]string, 0)
+	for _, t := range e.Block.transactions {
+		for _, a := range []string{t.senderBlockchainAddress, t.recipientBlockchainAddress} {
+			if !seen[a] {
+				seen[a] = true
+				addresses = append(addresses, a)
+			}
+		}
+	}
+	return addresses
+}
+
+type eventHub struct {
+	subscribers map[chan Event]bool
+	mux         sync.Mutex
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan Event]bool)}
+}
+
+func (h *eventHub) publish(e Event) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for c := range h.subscribers {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving a BlockEvent for every block added to the chain, a ReorgEvent
+// for every reorg and a TransactionEvent for every transaction added to the pool, and a function that
+// ends the subscription and closes the channel.
+func (bc *Blockchain) Subscribe() (<-chan Event, func()) {
+	h := bc.events
+	c := make(chan Event, EventBufferSize)
+	h.mux.Lock()
+	h.subscribers[c] = true
+	h.mux.Unlock()
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			h.mux.Lock()
+			delete(h.subscribers, c)
+			h.mux.Unlock()
+			close(c)
+		})
+	}
+}
+
+// publishFrom announces the blocks of chain from height on
+func (bc *Blockchain) publishFrom(chain []*Block, height int) {
+	for i := height; i < len(chain); i++ {
+		bc.events.publish(BlockEvent{Height: i, Block: chain[i]})
+	}
+}
+
+// accepted announces t, added to the pool unless err is not nil, and returns err
+func (bc *Blockchain) accepted(t *Transaction, err error) error {
+	if err == nil {
+		bc.events.publish(TransactionEvent{TxID: hexHash(t.Hash()), Transaction: t})
+	}
+	return err
+}
+
+// forkPoint returns the height of the first block in which a and b differ
+func forkPoint(a []*Block, b []*Block) int {
+	fork := 0
+	for fork < len(a) && fork < len(b) && a[fork].Hash() == b[fork].Hash() {
+		fork++
+	}
+	return fork
+}
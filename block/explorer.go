@@ -0,0 +1,76 @@
+package block
+
+import "encoding/json"
+
+// MaxBlocks is the most blocks returned at once by Blocks
+const MaxBlocks = 100
+
+// BlockView is a block of the chain with its height and hash, which the JSON of a Block can not hold
+// since the hash is computed from it
+type BlockView struct {
+	Height int
+	Hash   [32]byte
+	Block  *Block
+}
+
+// MarshalJSON is marshal BlockView: the height and hash followed by the fields of the Block
+func (v BlockView) MarshalJSON() ([]byte, error) {
+	m, err := v.Block.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	head, _ := json.Marshal(struct {
+		Height int    `json:"height"`
+		Hash   string `json:"hash"`
+	}{v.Height, hexHash(v.Hash)})
+	head[len(head)-1] = ','
+	return append(head, m[1:]...), nil
+}
+
+// blockView returns the BlockView of the block of chain at height. A block is linked by the next one,
+// so only the hash of the last block is computed.
+func blockView(chain []*Block, height int) BlockView {
+	v := BlockView{Height: height, Block: chain[height]}
+	if height+1 < len(chain) {
+		v.Hash = chain[height+1].previousHash
+	} else {
+		v.Hash = v.Block.Hash()
+	}
+	return v
+}
+
+// Blocks returns the BlockView of at most count blocks from height from, MaxBlocks at most
+func (bc *Blockchain) Blocks(from int, count int) []BlockView {
+	chain, _ := bc.view()
+	if count > MaxBlocks {
+		count = MaxBlocks
+	}
+	blocks := make([]BlockView, 0)
+	for i := from; i >= 0 && i < len(chain) && len(blocks) < count; i++ {
+		blocks = append(blocks, blockView(chain, i))
+	}
+	return blocks
+}
+
+// BlockAt returns the BlockView of the block at height
+func (bc *Blockchain) BlockAt(height int) (BlockView, bool) {
+	chain, _ := bc.view()
+	if height < 0 || height >= len(chain) {
+		return BlockView{}, false
+	}
+	return blockView(chain, height), true
+}
+
+// FindBlock returns the BlockView of the block of the chain with hash
+func (bc *Blockchain) FindBlock(hash [32]byte) (BlockView, bool) {
+	chain, _ := bc.view()
+	for i := 1; i < len(chain); i++ {
+		if chain[i].previousHash == hash {
+			return BlockView{Height: i - 1, Hash: hash, Block: chain[i-1]}, true
+		}
+	}
+	if tip := len(chain) - 1; chain[tip].Hash() == hash {
+		return BlockView{Height: tip, Hash: hash, Block: chain[tip]}, true
+	}
+	return BlockView{}, false
+}
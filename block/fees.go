@@ -0,0 +1,96 @@
+package block
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultFeeStatsBlocks is the number of recent blocks FeeStats looks at when no number is given
+const DefaultFeeStatsBlocks = 100
+
+// FeePercentiles is the distribution of the fees of a set of transactions, by nearest rank
+type FeePercentiles struct {
+	Min float32 `json:"min"`
+	P10 float32 `json:"p10"`
+	P25 float32 `json:"p25"`
+	P50 float32 `json:"p50"`
+	P75 float32 `json:"p75"`
+	P90 float32 `json:"p90"`
+	Max float32 `json:"max"`
+}
+
+// FeeDistribution is the number of transactions of a set and their FeePercentiles, nil for no transactions
+type FeeDistribution struct {
+	Transactions int             `json:"transactions"`
+	Percentiles  *FeePercentiles `json:"percentiles,omitempty"`
+}
+
+// FeeStats is the fee market: the fees paid in the last Blocks blocks, the fees each of them
+// collected on average and the fees offered by the pending transactions
+type FeeStats struct {
+	Blocks           int             `json:"blocks"`
+	Recent           FeeDistribution `json:"recent"`
+	AverageBlockFees float32         `json:"average_block_fees"`
+	Mempool          FeeDistribution `json:"mempool"`
+}
+
+// FeeStats returns the FeeStats of the last blocks mined. Coinbase transactions pay no fee and are left out.
+func (bc *Blockchain) FeeStats(blocks int) FeeStats {
+	chain, _ := bc.view()
+	// The genesis block carries no transactions
+	from := len(chain) - blocks
+	if from < 1 {
+		from = 1
+	}
+	s := FeeStats{Blocks: len(chain) - from}
+	var fees []float32
+	var total float32
+	for _, b := range chain[from:] {
+		for _, t := range b.transactions {
+			if t.senderBlockchainAddress != MiningSender {
+				fees = append(fees, t.fee)
+				total += t.fee
+			}
+		}
+	}
+	s.Recent = feeDistribution(fees)
+	if s.Blocks > 0 {
+		s.AverageBlockFees = total / float32(s.Blocks)
+	}
+
+	pending := bc.mempool.Transactions()
+	fees = make([]float32, 0, len(pending))
+	for _, t := range pending {
+		fees = append(fees, t.fee)
+	}
+	s.Mempool = feeDistribution(fees)
+	return s
+}
+
+// feeDistribution returns the FeeDistribution of fees, sorting them
+func feeDistribution(fees []float32) FeeDistribution {
+	d := FeeDistribution{Transactions: len(fees)}
+	if len(fees) == 0 {
+		return d
+	}
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i] < fees[j]
+	})
+	rank := func(p float64) float32 {
+		i := int(math.Ceil(p/100*float64(len(fees)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return fees[i]
+	}
+	d.Percentiles = &FeePercentiles{
+		Min: fees[0],
+		P10: rank(10),
+		P25: rank(25),
+		P50: rank(50),
+		P75: rank(75),
+		P90: rank(90),
+		Max: fees[len(fees)-1],
+	}
+	return d
+}
@@ -0,0 +1,92 @@
+package block
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// GCInterval is the interval of StartGC
+	GCInterval = 10 * time.Minute
+	// MempoolExpiry is how long a transaction stays in the pool without being mined
+	MempoolExpiry = 24 * time.Hour
+	// RejectionExpiry is how long a rejected chain tip is remembered
+	RejectionExpiry = time.Hour
+	// OrphanGCDepth is how far below the last block orphaned blocks are kept
+	OrphanGCDepth = 100
+)
+
+// GCStats is the number of entries a GC pass removed
+type GCStats struct {
+	Transactions int `json:"transactions"`
+	Rejections   int `json:"rejections"`
+	Orphans      int `json:"orphans"`
+	Peers        int `json:"peers"`
+}
+
+// GC removes the transactions pending for longer than the mempool expiry, rejected chain tips older than
+// RejectionExpiry, orphaned blocks more than OrphanGCDepth below the last block and the handshake
+// info of addresses that are no longer neighbors.
+func (bc *Blockchain) GC() GCStats {
+	var stats GCStats
+	now := bc.clock.Now()
+
+	stats.Transactions = bc.mempool.expire(now, bc.mempoolExpiry)
+	bc.mux.Lock()
+	height := len(bc.chain) - 1
+	bc.mux.Unlock()
+
+	rejectedBefore := now.Add(-RejectionExpiry).Unix()
+	stats.Rejections = bc.rejected.gc(rejectedBefore)
+	_, stats.Orphans = bc.orphans.gc(height-OrphanGCDepth, rejectedBefore)
+
+	bc.muxNeighbors.Lock()
+	neighbors := make(map[string]bool, len(bc.neighbors))
+	for _, n := range bc.neighbors {
+		neighbors[n] = true
+	}
+	for n := range bc.peers {
+		if !neighbors[n] {
+			delete(bc.peers, n)
+			stats.Peers++
+		}
+	}
+	bc.muxNeighbors.Unlock()
+	return stats
+}
+
+// gc removes the rejected chain tips recorded before rejectedBefore and the orphaned blocks below height
+func (s *orphanStore) gc(height int, rejectedBefore int64) (int, int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	rejections, orphans := 0, 0
+	kept := make([]*OrphanBlock, 0, len(s.orphans))
+	for _, o := range s.orphans {
+		switch {
+		case o.Reason == OrphanInvalid && o.Time < rejectedBefore:
+			rejections++
+		case o.Reason == OrphanReorg && o.Height < height:
+			orphans++
+		default:
+			kept = append(kept, o)
+			continue
+		}
+		delete(s.hashes, o.Block.Hash())
+	}
+	s.orphans = kept
+	return rejections, orphans
+}
+
+// StartGC runs GC now and then every GC interval until ctx is done or the Loop is stopped.
+// A GC loop already running is stopped.
+func (bc *Blockchain) StartGC(ctx context.Context) *Loop {
+	gc := func(ctx context.Context) {
+		if stats := bc.GC(); stats != (GCStats{}) {
+			bc.logger.Printf("GC removed %d transaction(s), %d rejection(s), %d orphan(s), %d peer(s)", stats.Transactions, stats.Rejections, stats.Orphans, stats.Peers)
+		}
+	}
+	if ctx.Err() == nil {
+		gc(ctx)
+	}
+	return bc.loops.start("gc", startLoop(ctx, bc.clock, bc.gcInterval, gc))
+}
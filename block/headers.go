@@ -0,0 +1,35 @@
+package block
+
+import "fmt"
+
+// MaxHeaders is the most block headers returned at once
+const MaxHeaders = 2000
+
+// Headers returns the headers of at most count blocks from height from, MaxHeaders at most
+func (bc *Blockchain) Headers(from int, count int) []BlockHeader {
+	chain, _ := bc.view()
+	if count > MaxHeaders {
+		count = MaxHeaders
+	}
+	headers := make([]BlockHeader, 0)
+	for i := from; i >= 0 && i < len(chain) && len(headers) < count; i++ {
+		headers = append(headers, chain[i].header())
+	}
+	return headers
+}
+
+// VerifyHeaders checks that headers continue the header chain ending with the hash tip, "" when they
+// start with the genesis block: each header links to the one before it. The proof of work covers the
+// transactions, which headers do not carry, so it is checked by the full nodes serving the headers.
+func VerifyHeaders(tip string, headers []BlockHeader) error {
+	for i, h := range headers {
+		if tip != "" && h.PreviousHash != tip {
+			return fmt.Errorf("%w: header %d does not link to %s", ErrChainInvalid, i, tip)
+		}
+		if _, err := ParseTxID(h.Hash); err != nil {
+			return fmt.Errorf("%w: header %d: invalid hash", ErrChainInvalid, i)
+		}
+		tip = h.Hash
+	}
+	return nil
+}
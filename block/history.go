@@ -0,0 +1,76 @@
+package block
+
+// AddressTransaction is a transaction sending from or to an address, with Amount the change of the balance
+// of the address it makes. Height, Timestamp and Confirmations are those of the block including a confirmed
+// transaction, none for a pending one.
+type AddressTransaction struct {
+	TxID          string       `json:"txid"`
+	Status        string       `json:"status"`
+	Height        int          `json:"height,omitempty"`
+	Timestamp     int64        `json:"timestamp,omitempty"`
+	Confirmations int          `json:"confirmations"`
+	Amount        float32      `json:"amount"`
+	Transaction   *Transaction `json:"transaction"`
+}
+
+// amountFor returns the change of the balance of address by t
+func (t *Transaction) amountFor(address string) float32 {
+	var amount float32
+	if t.senderBlockchainAddress == address {
+		amount -= t.value + t.fee
+	}
+	if t.recipientBlockchainAddress == address {
+		amount += t.value
+	}
+	return amount
+}
+
+// TransactionsFor returns the confirmed transactions sending from or to address, oldest first. Those of
+// the blocks a Snapshot replaced, or known only by their headers, are not included.
+func (bc *Blockchain) TransactionsFor(address string) []AddressTransaction {
+	idx, chain := bc.balanceIndex()
+	defer bc.muxChain.RUnlock()
+
+	transactions := make([]AddressTransaction, 0)
+	var last posting
+	for _, p := range idx.postings[address] {
+		// A transaction to its own sender is posted twice. The rewards of different blocks may share a txid.
+		if p.txid == ([32]byte{}) || (p.txid == last.txid && p.height == last.height) {
+			continue
+		}
+		last = p
+		j, ok := idx.findAt(p.txid, p.height)
+		if !ok {
+			continue
+		}
+		b := chain[p.height]
+		t := b.transactions[j]
+		transactions = append(transactions, AddressTransaction{
+			TxID:          hexHash(p.txid),
+			Status:        TransactionConfirmed,
+			Height:        p.height,
+			Timestamp:     b.timestamp,
+			Confirmations: len(chain) - p.height,
+			Amount:        t.amountFor(address),
+			Transaction:   t,
+		})
+	}
+	return transactions
+}
+
+// PendingTransactionsFor returns the transactions of the pool sending from or to address in the order they were added
+func (bc *Blockchain) PendingTransactionsFor(address string) []AddressTransaction {
+	transactions := make([]AddressTransaction, 0)
+	for _, t := range bc.mempool.Transactions() {
+		if t.senderBlockchainAddress != address && t.recipientBlockchainAddress != address {
+			continue
+		}
+		transactions = append(transactions, AddressTransaction{
+			TxID:        hexHash(t.Hash()),
+			Status:      TransactionPending,
+			Amount:      t.amountFor(address),
+			Transaction: t,
+		})
+	}
+	return transactions
+}
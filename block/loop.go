@@ -0,0 +1,92 @@
+package block
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Loop is a goroutine calling a function on the ticks of a Ticker until it is stopped or its context is done
+type Loop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startLoop calls f every interval of clock in a new goroutine
+func startLoop(ctx context.Context, clock Clock, interval time.Duration, f func(ctx context.Context)) *Loop {
+	ctx, cancel := context.WithCancel(ctx)
+	l := &Loop{cancel: cancel, done: make(chan struct{})}
+	ticker := clock.NewTicker(interval)
+	go func() {
+		defer close(l.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				f(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return l
+}
+
+// Stop stops the loop and waits for a running call to return
+func (l *Loop) Stop() {
+	l.cancel()
+	<-l.done
+}
+
+// Done returns a channel closed once the loop has stopped
+func (l *Loop) Done() <-chan struct{} {
+	return l.done
+}
+
+// loops are the Loops a Blockchain runs, by name
+type loops struct {
+	running map[string]*Loop
+	mux     sync.Mutex
+}
+
+// start starts a Loop under name, stopping the one already running under that name
+func (ls *loops) start(name string, l *Loop) *Loop {
+	ls.mux.Lock()
+	old := ls.running[name]
+	if ls.running == nil {
+		ls.running = make(map[string]*Loop)
+	}
+	ls.running[name] = l
+	ls.mux.Unlock()
+	if old != nil {
+		old.Stop()
+	}
+	return l
+}
+
+// stop stops the Loop running under name, if any
+func (ls *loops) stop(name string) {
+	ls.mux.Lock()
+	l := ls.running[name]
+	delete(ls.running, name)
+	ls.mux.Unlock()
+	if l != nil {
+		l.Stop()
+	}
+}
+
+// stopAll stops every Loop
+func (ls *loops) stopAll() {
+	ls.mux.Lock()
+	running := ls.running
+	ls.running = nil
+	ls.mux.Unlock()
+	for _, l := range running {
+		l.Stop()
+	}
+}
+
+// Stop stops syncing neighbors, mining and garbage collection, waiting for running passes to finish
+func (bc *Blockchain) Stop() {
+	bc.loops.stopAll()
+}
@@ -0,0 +1,340 @@
+package block
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Mempool is the pool of transactions waiting to be mined. It has its own lock instead of the mux
+// of the Blockchain, so transactions are added while a block is mined or the chain is read,
+// and its reads return snapshots that stay valid while the pool changes.
+type Mempool struct {
+	transactions []*Transaction
+	seen         map[[32]byte]time.Time
+	// spend is the value and fee of the pending transactions of every sender
+	spend map[string]float32
+	mux   sync.RWMutex
+}
+
+func newMempool() *Mempool {
+	return &Mempool{}
+}
+
+// Mempool returns the transaction pool of the Blockchain
+func (bc *Blockchain) Mempool() *Mempool {
+	return bc.mempool
+}
+
+// Transactions returns a snapshot of the pending transactions in the order they were added
+func (p *Mempool) Transactions() []*Transaction {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	transactions := make([]*Transaction, len(p.transactions))
+	copy(transactions, p.transactions)
+	return transactions
+}
+
+// Len returns the number of pending transactions
+func (p *Mempool) Len() int {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return len(p.transactions)
+}
+
+// Find returns the pending transaction with hash h
+func (p *Mempool) Find(h [32]byte) (*Transaction, bool) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	for _, t := range p.transactions {
+		if t.Hash() == h {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// PendingSpend returns the value and fee of the pending transactions sent from address
+func (p *Mempool) PendingSpend(address string) float32 {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	return p.spend[address]
+}
+
+// Unlocked returns the pending transactions that are not locked at the given height and time
+func (p *Mempool) Unlocked(height int, now int64) []*Transaction {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	transactions := make([]*Transaction, 0, len(p.transactions))
+	for _, t := range p.transactions {
+		if !t.IsLocked(height, now) {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions
+}
+
+// MempoolFilter selects pending transactions. A non-empty Address matches the sender or the recipient,
+// MinFee is the lowest fee paid.
+type MempoolFilter struct {
+	Address string
+	MinFee  float32
+}
+
+// match reports whether t passes the filter
+func (f MempoolFilter) match(t *Transaction) bool {
+	if f.Address != "" && t.senderBlockchainAddress != f.Address && t.recipientBlockchainAddress != f.Address {
+		return false
+	}
+	return t.fee >= f.MinFee
+}
+
+// MempoolEntry is a pending transaction with its place in the order the next block picks transactions,
+// highest fee first. Position is 1 for the first picked and 0 for a locked transaction, which is not picked.
+// NextBlock reports whether it fits in the next block.
+type MempoolEntry struct {
+	TxID        string       `json:"txid"`
+	Position    int          `json:"position,omitempty"`
+	NextBlock   bool         `json:"next_block"`
+	Transaction *Transaction `json:"transaction"`
+}
+
+// MempoolEntries returns the pending transactions passing f, in the order they were added
+func (bc *Blockchain) MempoolEntries(f MempoolFilter) []MempoolEntry {
+	chain, _ := bc.view()
+	pending := bc.mempool.Transactions()
+	now := bc.clock.Now().Unix()
+
+	// Rank like selectTransactions does
+	unlocked := make([]*Transaction, 0, len(pending))
+	for _, t := range pending {
+		if !t.IsLocked(len(chain), now) {
+			unlocked = append(unlocked, t)
+		}
+	}
+	sort.SliceStable(unlocked, func(i, j int) bool {
+		return unlocked[i].fee > unlocked[j].fee
+	})
+	positions := make(map[*Transaction]int, len(unlocked))
+	for i, t := range unlocked {
+		positions[t] = i + 1
+	}
+
+	entries := make([]MempoolEntry, 0)
+	for _, t := range pending {
+		if !f.match(t) {
+			continue
+		}
+		position := positions[t]
+		entries = append(entries, MempoolEntry{
+			TxID:        hexHash(t.Hash()),
+			Position:    position,
+			NextBlock:   position > 0 && position <= MaxBlockTransactions-1,
+			Transaction: t,
+		})
+	}
+	return entries
+}
+
+// add seals and adds t unless conflict, when not nil, returns an error for one of the pending transactions.
+// A transaction with a nonce instead replaces the pending one of the same sender and nonce when it
+// pays a higher fee, and add returns the replaced transaction.
+func (p *Mempool) add(t *Transaction, conflict func(pending *Transaction) error) (*Transaction, error) {
+	return p.insert(t, nil, conflict)
+}
+
+// addFunded adds t like add when the pending transactions of its sender, t taking the place of the one
+// it replaces, spend at most balance, so that pending transactions never spend the same coins twice
+func (p *Mempool) addFunded(t *Transaction, balance float32, conflict func(pending *Transaction) error) (*Transaction, error) {
+	return p.insert(t, &balance, conflict)
+}
+
+// insert adds t, checking the pending spend of its sender against balance when not nil
+func (p *Mempool) insert(t *Transaction, balance *float32, conflict func(pending *Transaction) error) (*Transaction, error) {
+	t.seal()
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if conflict != nil {
+		for _, pending := range p.transactions {
+			if err := conflict(pending); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sender := t.senderBlockchainAddress
+	i := p.pendingIndex(sender, t.nonce)
+	if i >= 0 && t.fee <= p.transactions[i].fee {
+		return nil, fmt.Errorf("%w: replacement must pay a higher fee than %g", ErrDuplicateTransaction, p.transactions[i].fee)
+	}
+	if balance != nil {
+		spend := p.spend[sender] + t.value + t.fee
+		if i >= 0 {
+			spend -= p.transactions[i].value + p.transactions[i].fee
+		}
+		if spend > *balance {
+			return nil, fmt.Errorf("%w: %g already pending", ErrInsufficientBalance, p.spend[sender])
+		}
+	}
+	var old *Transaction
+	if i >= 0 {
+		old = p.transactions[i]
+		p.transactions[i] = t
+	} else {
+		p.transactions = append(p.transactions, t)
+	}
+	p.respend(sender)
+	return old, nil
+}
+
+// respend recomputes the pending spend of senders. The caller must hold mux.
+func (p *Mempool) respend(senders ...string) {
+	if p.spend == nil {
+		p.spend = make(map[string]float32)
+	}
+	for _, s := range senders {
+		delete(p.spend, s)
+	}
+	for _, t := range p.transactions {
+		for _, s := range senders {
+			if t.senderBlockchainAddress == s {
+				p.spend[s] += t.value + t.fee
+			}
+		}
+	}
+}
+
+// senders returns the senders of transactions
+func senders(transactions []*Transaction) []string {
+	seen := make(map[string]bool, len(transactions))
+	s := make([]string, 0, len(transactions))
+	for _, t := range transactions {
+		if !seen[t.senderBlockchainAddress] {
+			seen[t.senderBlockchainAddress] = true
+			s = append(s, t.senderBlockchainAddress)
+		}
+	}
+	return s
+}
+
+// findNonce returns the pending transaction of sender with nonce
+func (p *Mempool) findNonce(sender string, nonce uint64) (*Transaction, bool) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	if i := p.pendingIndex(sender, nonce); i >= 0 {
+		return p.transactions[i], true
+	}
+	return nil, false
+}
+
+// pendingIndex returns the index of the pending transaction of sender with nonce, or -1.
+// Transactions without a nonce are never replaced. The caller must hold mux.
+func (p *Mempool) pendingIndex(sender string, nonce uint64) int {
+	if nonce == 0 {
+		return -1
+	}
+	for i, t := range p.transactions {
+		if t.senderBlockchainAddress == sender && t.nonce == nonce {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove removes the pending transactions included in transactions
+func (p *Mempool) remove(transactions []*Transaction) {
+	included := make(map[[32]byte]bool, len(transactions))
+	for _, t := range transactions {
+		included[t.Hash()] = true
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	pool := make([]*Transaction, 0, len(p.transactions))
+	removed := make([]*Transaction, 0)
+	for _, t := range p.transactions {
+		if !included[t.Hash()] {
+			pool = append(pool, t)
+		} else {
+			removed = append(removed, t)
+		}
+	}
+	p.transactions = pool
+	p.respend(senders(removed)...)
+}
+
+// clear removes all the pending transactions
+func (p *Mempool) clear() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.transactions = nil
+	p.spend = nil
+}
+
+// expire removes the transactions first seen by an expire pass more than expiry before now
+// and returns how many it removed, so a transaction expires up to one pass late.
+func (p *Mempool) expire(now time.Time, expiry time.Duration) int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	seen := make(map[[32]byte]time.Time, len(p.transactions))
+	pool := make([]*Transaction, 0, len(p.transactions))
+	removed := make([]*Transaction, 0)
+	for _, t := range p.transactions {
+		h := t.Hash()
+		first, ok := p.seen[h]
+		if !ok {
+			first = now
+		}
+		if now.Sub(first) >= expiry {
+			removed = append(removed, t)
+			continue
+		}
+		seen[h] = first
+		pool = append(pool, t)
+	}
+	expired := len(p.transactions) - len(pool)
+	p.transactions = pool
+	p.seen = seen
+	p.respend(senders(removed)...)
+	return expired
+}
+
+// tipChanged cancels the block being mined, which no longer extends the last block, and drops the pending
+// transactions the new chain does not allow. The caller must hold mux.
+func (bc *Blockchain) tipChanged() {
+	bc.miner.interrupt()
+	if n := bc.revalidatePool(); n > 0 {
+		bc.logger.Printf("Dropped %d pending transaction(s) invalid after the last block", n)
+	}
+}
+
+// revalidatePool removes the pending transactions that are already in the chain, carry a nonce their sender
+// used there, or that their sender can no longer pay, checked in the order they were added. It returns how
+// many it removed.
+func (bc *Blockchain) revalidatePool() int {
+	pending := bc.mempool.Transactions()
+	if len(pending) == 0 {
+		return 0
+	}
+	invalid := bc.invalidPending(pending)
+	bc.mempool.remove(invalid)
+	return len(invalid)
+}
+
+// invalidPending returns the transactions of pending revalidatePool removes
+func (bc *Blockchain) invalidPending(pending []*Transaction) []*Transaction {
+	idx, _ := bc.balanceIndex()
+	defer bc.muxChain.RUnlock()
+
+	spend := make(map[string]float32)
+	invalid := make([]*Transaction, 0)
+	for _, t := range pending {
+		sender := t.senderBlockchainAddress
+		confirmed, mined := idx.sent(sender, t.Hash())
+		if mined || (t.nonce != 0 && t.nonce <= confirmed) || spend[sender]+t.value+t.fee > idx.balance(sender) {
+			invalid = append(invalid, t)
+			continue
+		}
+		spend[sender] += t.value + t.fee
+	}
+	return invalid
+}
@@ -0,0 +1,132 @@
+package block
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// MerkleNode is a sibling hash on the path from a transaction to the merkle root.
+// Left reports whether the sibling is the left operand of the parent hash.
+type MerkleNode struct {
+	Hash [32]byte
+	Left bool
+}
+
+// MarshalJSON is returns a struct with hash, left
+func (n *MerkleNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Hash string `json:"hash"`
+		Left bool   `json:"left"`
+	}{
+		Hash: hexHash(n.Hash),
+		Left: n.Left,
+	})
+}
+
+// UnmarshalJSON decodes a MerkleNode encoded by MarshalJSON
+func (n *MerkleNode) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Hash string `json:"hash"`
+		Left bool   `json:"left"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	h, err := ParseTxID(v.Hash)
+	if err != nil {
+		return fmt.Errorf("merkle node: %w", err)
+	}
+	n.Hash, n.Left = h, v.Left
+	return nil
+}
+
+// Hash convert Transaction to SHA256 []byte and returns []byte.
+// It is also the digest the sender signs. The hash is cached once the transaction is sealed.
+func (t *Transaction) Hash() [32]byte {
+	if t.sealed {
+		return t.hash
+	}
+	m, _ := json.Marshal(t)
+	return sha256.Sum256(m)
+}
+
+// seal caches the hash of t. It must be called once the fields of t are final and before t is shared,
+// e.g. when it is decoded or enters the pool.
+func (t *Transaction) seal() *Transaction {
+	if !t.sealed {
+		t.hash = t.Hash()
+		t.sealed = true
+	}
+	return t
+}
+
+func merkleParent(left [32]byte, right [32]byte) [32]byte {
+	return sha256.Sum256(append(left[:], right[:]...))
+}
+
+func merkleLeaves(transactions []*Transaction) [][32]byte {
+	leaves := make([][32]byte, 0, len(transactions))
+	for _, t := range transactions {
+		leaves = append(leaves, t.Hash())
+	}
+	return leaves
+}
+
+// MerkleRoot returns the merkle root of the transaction hashes.
+// The last hash of a level with an odd count is paired with itself.
+func MerkleRoot(transactions []*Transaction) [32]byte {
+	level := merkleLeaves(transactions)
+	if len(level) == 0 {
+		return [32]byte{}
+	}
+	for len(level) > 1 {
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			j := i + 1
+			if j == len(level) {
+				j = i
+			}
+			next = append(next, merkleParent(level[i], level[j]))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// MerklePath returns the sibling hashes from the transaction at index up to the merkle root
+func MerklePath(transactions []*Transaction, index int) []*MerkleNode {
+	level := merkleLeaves(transactions)
+	path := make([]*MerkleNode, 0)
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling == len(level) {
+			sibling = index
+		}
+		path = append(path, &MerkleNode{Hash: level[sibling], Left: sibling < index})
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			j := i + 1
+			if j == len(level) {
+				j = i
+			}
+			next = append(next, merkleParent(level[i], level[j]))
+		}
+		level = next
+		index /= 2
+	}
+	return path
+}
+
+// VerifyMerklePath reports whether path, as returned by MerklePath, leads from the leaf hash to root
+func VerifyMerklePath(leaf [32]byte, path []*MerkleNode, root [32]byte) bool {
+	h := leaf
+	for _, n := range path {
+		if n.Left {
+			h = merkleParent(n.Hash, h)
+		} else {
+			h = merkleParent(h, n.Hash)
+		}
+	}
+	return h == root
+}
@@ -0,0 +1,49 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ChainFormatVersion is the version of the block format written by this node.
+// Chains without a version are version 1.
+const ChainFormatVersion = 1
+
+// Migration converts the JSON encoded blocks of a chain from format version From to From+1
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(blocks []json.RawMessage) ([]json.RawMessage, error)
+}
+
+// migrations are the conversions between the chain formats, in order. A change of the block
+// format bumps ChainFormatVersion and adds the Migration from the previous version here.
+var migrations = []Migration{}
+
+// MigrateChain converts the JSON encoded blocks of a chain written in format version to ChainFormatVersion
+func MigrateChain(version int, blocks []json.RawMessage) ([]json.RawMessage, error) {
+	if version < 1 {
+		version = 1
+	}
+	if version > ChainFormatVersion {
+		return nil, fmt.Errorf("chain format version %d is newer than %d", version, ChainFormatVersion)
+	}
+	for _, m := range migrations {
+		if m.From < version {
+			continue
+		}
+		if m.From != version {
+			return nil, fmt.Errorf("no migration from chain format version %d", version)
+		}
+		migrated, err := m.Migrate(blocks)
+		if err != nil {
+			return nil, fmt.Errorf("migrate chain format version %d (%s): %w", m.From, m.Description, err)
+		}
+		blocks = migrated
+		version++
+	}
+	if version != ChainFormatVersion {
+		return nil, fmt.Errorf("no migration from chain format version %d", version)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,169 @@
+package block
+
+import (
+	"context"
+	"sync"
+)
+
+// MinerState is the lifecycle state of a Miner
+type MinerState string
+
+const (
+	MinerStopped MinerState = "stopped"
+	MinerRunning MinerState = "running"
+	MinerPaused  MinerState = "paused"
+)
+
+// MinerStatus reports what a Miner is doing. Template is the block being mined, nil between attempts.
+// Attempts counts the nonces tried by the node since it started, by Workers goroutines at once.
+type MinerStatus struct {
+	State     MinerState     `json:"state"`
+	Workers   int            `json:"workers"`
+	Attempts  uint64         `json:"attempts"`
+	Blocks    int            `json:"blocks"`
+	LastBlock int64          `json:"last_block,omitempty"`
+	Template  *BlockTemplate `json:"template,omitempty"`
+}
+
+// Miner mines the blocks of a Blockchain every mining interval. A paused Miner keeps its loop
+// but skips the ticks, a running attempt is canceled when it is paused or stopped, or when the last block changes.
+type Miner struct {
+	bc        *Blockchain
+	loop      *Loop
+	paused    bool
+	cancel    context.CancelFunc
+	template  *BlockTemplate
+	abort     context.CancelFunc
+	attempts  uint64
+	blocks    int
+	lastBlock int64
+	mux       sync.Mutex
+}
+
+func newMiner(bc *Blockchain) *Miner {
+	return &Miner{bc: bc}
+}
+
+// Miner returns the Miner of the Blockchain
+func (bc *Blockchain) Miner() *Miner {
+	return bc.miner
+}
+
+// Start mines every mining interval until ctx is done or the Miner is stopped. It also resumes a paused Miner.
+func (m *Miner) Start(ctx context.Context) *Loop {
+	m.mux.Lock()
+	m.paused = false
+	m.mux.Unlock()
+	l := m.bc.loops.start("mining", startLoop(ctx, m.bc.clock, m.bc.miningInterval, m.tick))
+	m.mux.Lock()
+	m.loop = l
+	m.mux.Unlock()
+	return l
+}
+
+// Stop stops the mining loop, canceling a running attempt
+func (m *Miner) Stop() {
+	m.mux.Lock()
+	m.paused = false
+	m.cancelAttempt()
+	m.mux.Unlock()
+	m.bc.loops.stop("mining")
+}
+
+// Pause makes the Miner skip mining until Resume or Start, canceling a running attempt
+func (m *Miner) Pause() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.paused = true
+	m.cancelAttempt()
+}
+
+// Resume lets a paused Miner mine again from the next tick
+func (m *Miner) Resume() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.paused = false
+}
+
+// Status returns the MinerStatus of the Miner
+func (m *Miner) Status() MinerStatus {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	state := MinerStopped
+	if m.loop != nil {
+		select {
+		case <-m.loop.Done():
+		default:
+			state = MinerRunning
+			if m.paused {
+				state = MinerPaused
+			}
+		}
+	}
+	return MinerStatus{
+		State:     state,
+		Workers:   m.bc.miningWorkers,
+		Attempts:  m.attempts,
+		Blocks:    m.blocks,
+		LastBlock: m.lastBlock,
+		Template:  m.template,
+	}
+}
+
+// tick is one pass of the mining loop, skipped while the Miner is paused
+func (m *Miner) tick(ctx context.Context) {
+	m.mux.Lock()
+	if m.paused {
+		m.mux.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+	m.mux.Unlock()
+
+	m.bc.Mining(ctx)
+
+	m.mux.Lock()
+	m.cancelAttempt()
+	m.mux.Unlock()
+}
+
+// cancelAttempt cancels the running attempt, if any. The caller must hold mux.
+func (m *Miner) cancelAttempt() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+// attempting records the block template being mined and the cancel function of its attempt, nil once the attempt is over
+func (m *Miner) attempting(t *BlockTemplate, abort context.CancelFunc) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.template = t
+	m.abort = abort
+}
+
+// interrupt cancels the block being mined, if any, whoever started the attempt
+func (m *Miner) interrupt() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.abort != nil {
+		m.abort()
+	}
+}
+
+// tried adds n nonces to the attempts
+func (m *Miner) tried(n int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.attempts += uint64(n)
+}
+
+// mined records a block mined at timestamp
+func (m *Miner) mined(timestamp int64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.blocks++
+	m.lastBlock = timestamp
+}
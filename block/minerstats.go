@@ -0,0 +1,44 @@
+package block
+
+import "sort"
+
+// MinerStats is the number of blocks of the chain paying their coinbase transaction to a blockchain address
+type MinerStats struct {
+	BlockchainAddress string `json:"blockchain_address"`
+	Blocks            int    `json:"blocks"`
+	// ExtraData is the tag of the latest of the blocks
+	ExtraData string `json:"extra_data,omitempty"`
+}
+
+// MinerStats returns the block counts of the miners of the chain, most blocks first.
+// Blocks without a coinbase transaction and pruned blocks, whose transactions are not kept, are not counted.
+func (bc *Blockchain) MinerStats() []MinerStats {
+	chain, _ := bc.view()
+	miners := make(map[string]*MinerStats)
+	for _, b := range chain {
+		for _, t := range b.transactions {
+			if t.senderBlockchainAddress != MiningSender {
+				continue
+			}
+			m, ok := miners[t.recipientBlockchainAddress]
+			if !ok {
+				m = &MinerStats{BlockchainAddress: t.recipientBlockchainAddress}
+				miners[t.recipientBlockchainAddress] = m
+			}
+			m.Blocks++
+			m.ExtraData = b.extraData
+			break
+		}
+	}
+	stats := make([]MinerStats, 0, len(miners))
+	for _, m := range miners {
+		stats = append(stats, *m)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Blocks != stats[j].Blocks {
+			return stats[i].Blocks > stats[j].Blocks
+		}
+		return stats[i].BlockchainAddress < stats[j].BlockchainAddress
+	})
+	return stats
+}
@@ -0,0 +1,118 @@
+package block
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"regexp"
+
+	"github.com/hirasawayuki/block_chain/address"
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// NameRegistrationFee is the amount burned to register a name
+const NameRegistrationFee = 1.0
+
+var namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ValidName reports whether name can be registered
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// NewNameTransaction returns a Transaction that registers name to the sender, burning value as the fee
+func NewNameTransaction(sender string, name string, value float32) *Transaction {
+	t := NewTransaction(sender, BurnAddress, value, 0)
+	t.name = name
+	return t
+}
+
+// CreateNameRegistration adds a name registration transaction and sends it to the neighbors
+func (bc *Blockchain) CreateNameRegistration(ctx context.Context, sender string, name string, value float32, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) (BroadcastResult, error) {
+	if err := bc.AddNameRegistration(sender, name, value, senderPublicKey, s); err != nil {
+		return BroadcastResult{}, err
+	}
+	recipient := BurnAddress
+	publicKeyStr := fmt.Sprintf("%064x%064x", senderPublicKey.X.Bytes(), senderPublicKey.Y.Bytes())
+	signatureStr := s.String()
+	return bc.broadcastTransaction(ctx, &TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		SenderPublicKey:            &publicKeyStr,
+		Value:                      &value,
+		Name:                       &name,
+		Signature:                  &signatureStr,
+	}), nil
+}
+
+// AddNameRegistration is create a name registration Transaction and add BlockChain struct.
+// Names are first-come: a name already registered on the chain or pending in the pool is rejected.
+func (bc *Blockchain) AddNameRegistration(sender string, name string, value float32, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
+	if !namingSender(sender) {
+		return fmt.Errorf("%w: %s can not register names", ErrInvalidAddress, sender)
+	}
+	if !ValidName(name) {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidTransaction, name)
+	}
+	if value < NameRegistrationFee {
+		return fmt.Errorf("%w: name registration fee is %g", ErrInvalidTransaction, NameRegistrationFee)
+	}
+	if _, ok := bc.LookupName(name); ok {
+		return ErrNameTaken
+	}
+	if senderPublicKey == nil || address.FromPublicKey(senderPublicKey) != sender {
+		return fmt.Errorf("%w: sender address does not match the public key", ErrInvalidAddress)
+	}
+	t := NewNameTransaction(sender, name, value).seal()
+	if !bc.VerifyTransactionSignature(senderPublicKey, s, t) {
+		return ErrInvalidSignature
+	}
+	balance := bc.CaluculateTotalAmount(sender)
+	if balance < value {
+		return ErrInsufficientBalance
+	}
+	_, err := bc.mempool.addFunded(t, balance, func(p *Transaction) error {
+		if p.name == name {
+			return fmt.Errorf("%w: registration pending", ErrNameTaken)
+		}
+		return nil
+	})
+	return bc.accepted(t, err)
+}
+
+// namingSender reports whether sender can register names: the mining, anchor and burn senders can not
+func namingSender(sender string) bool {
+	return sender != MiningSender && sender != AnchorSender && sender != BurnAddress
+}
+
+// invalidNameRegistration returns the index of the first name registration of transactions with an invalid
+// name, a sender that can not register names or burning less than NameRegistrationFee, -1 when there is none
+func invalidNameRegistration(transactions []*Transaction) int {
+	for i, t := range transactions {
+		if t.name == "" || t.recipientBlockchainAddress != BurnAddress {
+			continue
+		}
+		if !namingSender(t.senderBlockchainAddress) || !ValidName(t.name) || t.value < NameRegistrationFee {
+			return i
+		}
+	}
+	return -1
+}
+
+// LookupName returns the blockchain address that first registered name
+func (bc *Blockchain) LookupName(name string) (string, bool) {
+	chain, base := bc.view()
+	if base != nil {
+		if address, ok := base.Names[name]; ok {
+			return address, true
+		}
+	}
+	for _, b := range chain {
+		for _, t := range b.transactions {
+			if t.name == name && t.recipientBlockchainAddress == BurnAddress {
+				return t.senderBlockchainAddress, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,87 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/noise"
+)
+
+// ErrPeerKeyChanged is returned when a neighbor presents another node key than at the first connection
+var ErrPeerKeyChanged = errors.New("peer node key changed")
+
+// peerKeys are the node keys of the neighbors, pinned at the first handshake
+type peerKeys struct {
+	keys map[string]string
+	mux  sync.Mutex
+}
+
+// pin records key as the key of neighbor, or checks it against the key recorded.
+// It reports whether the key is new.
+func (p *peerKeys) pin(neighbor string, key string) (bool, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	pinned, ok := p.keys[neighbor]
+	if !ok {
+		p.keys[neighbor] = key
+		return true, nil
+	}
+	if pinned != key {
+		return false, fmt.Errorf("%s: %w", neighbor, ErrPeerKeyChanged)
+	}
+	return false, nil
+}
+
+// SetNoise makes the transport connect to the neighbors with a Noise handshake and the node key,
+// encrypting and authenticating the connections. The key of a neighbor is pinned at the first handshake
+// and a neighbor presenting another key afterwards is refused. It must be called before the first request.
+func (t *HTTPTransport) SetNoise(key *noise.Key) {
+	t.peerKeys = &peerKeys{keys: make(map[string]string)}
+	dialer := newPeerDialer()
+	t.client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		nc, err := noise.Client(conn, key)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: %w", addr, err)
+		}
+		pinned, err := t.peerKeys.pin(addr, nc.RemoteKey())
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if pinned {
+			t.logger.Printf("Pinned node key of %s: %s", addr, nc.RemoteKey())
+		}
+		return nc, nil
+	}
+}
+
+// PeerKeys returns the node keys of the neighbors by address, nil without Noise
+func (t *HTTPTransport) PeerKeys() map[string]string {
+	if t.peerKeys == nil {
+		return nil
+	}
+	t.peerKeys.mux.Lock()
+	defer t.peerKeys.mux.Unlock()
+	keys := make(map[string]string, len(t.peerKeys.keys))
+	for n, k := range t.peerKeys.keys {
+		keys[n] = k
+	}
+	return keys
+}
+
+// PeerKeys returns the node keys pinned for the neighbors of a Blockchain talking over Noise, nil otherwise
+func (bc *Blockchain) PeerKeys() map[string]string {
+	if t, ok := bc.transport.(*HTTPTransport); ok {
+		return t.PeerKeys()
+	}
+	return nil
+}
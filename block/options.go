@@ -0,0 +1,185 @@
+package block
+
+import (
+	"time"
+
+	"github.com/hirasawayuki/block_chain/noise"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// Option configures a Blockchain at construction, overriding the package defaults
+type Option func(*Blockchain)
+
+// WithDifficulty sets the number of leading zeros required of block hashes outside regtest mode
+func WithDifficulty(difficulty int) Option {
+	return func(bc *Blockchain) {
+		bc.miningDifficulty = difficulty
+		bc.difficulty = difficulty
+	}
+}
+
+// WithReward sets the mining reward before any halving
+func WithReward(reward float32) Option {
+	return func(bc *Blockchain) {
+		bc.emission.InitialReward = reward
+	}
+}
+
+// WithMiningInterval sets the interval of StartMining
+func WithMiningInterval(d time.Duration) Option {
+	return func(bc *Blockchain) {
+		bc.miningInterval = d
+	}
+}
+
+// WithMiningWorkers sets the number of goroutines searching nonces in parallel, runtime.NumCPU() by default
+func WithMiningWorkers(n int) Option {
+	return func(bc *Blockchain) {
+		bc.miningWorkers = n
+	}
+}
+
+// WithSyncInterval sets the interval of StartSyncNeighbors
+func WithSyncInterval(d time.Duration) Option {
+	return func(bc *Blockchain) {
+		bc.syncInterval = d
+	}
+}
+
+// WithGCInterval sets the interval of StartGC
+func WithGCInterval(d time.Duration) Option {
+	return func(bc *Blockchain) {
+		bc.gcInterval = d
+	}
+}
+
+// WithMempoolExpiry sets how long a transaction stays in the pool without being mined
+func WithMempoolExpiry(d time.Duration) Option {
+	return func(bc *Blockchain) {
+		bc.mempoolExpiry = d
+	}
+}
+
+// WithConfig sets the difficulty, reward, mining interval and workers, sync interval and neighbor range of c
+func WithConfig(c Config) Option {
+	return func(bc *Blockchain) {
+		for _, opt := range []Option{
+			WithDifficulty(c.Difficulty),
+			WithReward(c.Reward),
+			WithMiningInterval(time.Duration(c.MiningInterval)),
+			WithMiningWorkers(c.MiningWorkers),
+			WithSyncInterval(time.Duration(c.SyncInterval)),
+			WithNeighborRange(c.NeighborIPStart, c.NeighborIPEnd, c.NeighborPortStart, c.NeighborPortEnd),
+		} {
+			opt(bc)
+		}
+	}
+}
+
+// WithClock sets the Clock of the Blockchain, SystemClock by default
+func WithClock(c Clock) Option {
+	return func(bc *Blockchain) {
+		bc.clock = c
+	}
+}
+
+// WithGenesisBalance credits amount to address in the genesis block, e.g. to fund the accounts of a test network.
+// Nodes only agree on a chain built from the same genesis block.
+func WithGenesisBalance(address string, amount float32) Option {
+	return func(bc *Blockchain) {
+		bc.genesis = append(bc.genesis, NewTransaction(MiningSender, address, amount, 0).seal())
+	}
+}
+
+// WithGenesisTime dates the genesis block at t, GenesisTime by default.
+// Nodes only agree on a chain built from the same genesis block.
+func WithGenesisTime(t time.Time) Option {
+	return func(bc *Blockchain) {
+		bc.genesisTime = t
+	}
+}
+
+// WithGenesis starts the Blockchain from the genesis block b, e.g. the one of the network it joins,
+// instead of the one built from the genesis balances and time
+func WithGenesis(b *Block) Option {
+	return func(bc *Blockchain) {
+		bc.genesisBlock = b
+	}
+}
+
+// WithLogger sets where the Blockchain, and its HTTPTransport, write their logs
+func WithLogger(l utils.Logger) Option {
+	return func(bc *Blockchain) {
+		bc.logger = l
+		if t, ok := bc.transport.(*HTTPTransport); ok {
+			t.SetLogger(l)
+		}
+	}
+}
+
+// WithNeighborRange sets the address offset and port ranges scanned for neighbors.
+// It only applies to the HTTPTransport; other transports find neighbors their own way.
+func WithNeighborRange(startIP uint8, endIP uint8, startPort uint16, endPort uint16) Option {
+	return func(bc *Blockchain) {
+		if t, ok := bc.transport.(*HTTPTransport); ok {
+			t.SetNeighborRange(startIP, endIP, startPort, endPort)
+		}
+	}
+}
+
+// WithRole sets the role of the node, RoleArchive by default
+func WithRole(role Role) Option {
+	return func(bc *Blockchain) {
+		bc.role = role
+	}
+}
+
+// WithPruneDepth sets the number of recent blocks a pruned node serves
+func WithPruneDepth(depth int) Option {
+	return func(bc *Blockchain) {
+		bc.pruneDepth = depth
+	}
+}
+
+// WithClockDriftAlert sets the clock drift over which the node warns, DefaultMaxClockDrift by default,
+// and alert, called with the ClockDrift each time it goes over it
+func WithClockDriftAlert(threshold time.Duration, alert func(ClockDrift)) Option {
+	return func(bc *Blockchain) {
+		if threshold > 0 {
+			bc.drift.threshold = threshold
+		}
+		bc.drift.alert = alert
+	}
+}
+
+// WithAlertKey sets the key signing the double-spend alerts of the node, a key generated at the first alert by default
+func WithAlertKey(key wallet.Signer) Option {
+	return func(bc *Blockchain) {
+		bc.alertKey = key
+	}
+}
+
+// WithChainID sets the ID of the network transactions are signed for, DefaultChainID by default
+func WithChainID(chainID string) Option {
+	return func(bc *Blockchain) {
+		bc.chainID = chainID
+	}
+}
+
+// WithNoise makes a Blockchain talking over HTTP connect to its neighbors with a Noise handshake and the node key,
+// see HTTPTransport.SetNoise
+func WithNoise(key *noise.Key) Option {
+	return func(bc *Blockchain) {
+		if t, ok := bc.transport.(*HTTPTransport); ok {
+			t.SetNoise(key)
+		}
+	}
+}
+
+// WithExtraData sets the tag of the blocks mined by the node, at most MaxExtraDataSize bytes
+func WithExtraData(extraData string) Option {
+	return func(bc *Blockchain) {
+		bc.extraData = extraData
+	}
+}
@@ -0,0 +1,158 @@
+package block
+
+import (
+	"sync"
+)
+
+// MaxOrphans is the number of orphaned blocks and rejected chain tips reported by Orphans
+const MaxOrphans = 100
+
+// MaxRejectedTips is the number of rejected chain tips ResolveConflicts skips, the oldest forgotten first
+const MaxRejectedTips = 1000
+
+const (
+	// OrphanReorg marks a block of our chain that lost a reorg
+	OrphanReorg = "reorg"
+	// OrphanInvalid marks the tip of a neighbor chain that failed validation
+	OrphanInvalid = "invalid"
+)
+
+// OrphanBlock is a block that left the chain in a reorg, or the tip of a rejected chain
+type OrphanBlock struct {
+	Hash   string `json:"hash"`
+	Height int    `json:"height"`
+	// Depth is the number of blocks the losing branch had after the fork point
+	Depth  int    `json:"depth"`
+	Reason string `json:"reason"`
+	Time   int64  `json:"time"`
+	Block  *Block `json:"block"`
+}
+
+// OrphanStats is the content of the orphan store with its counters
+type OrphanStats struct {
+	Count    int            `json:"count"`
+	Reorgs   int            `json:"reorgs"`
+	MaxDepth int            `json:"max_depth"`
+	Orphans  []*OrphanBlock `json:"orphans"`
+}
+
+type orphanStore struct {
+	orphans  []*OrphanBlock
+	hashes   map[[32]byte]bool
+	count    int
+	reorgs   int
+	maxDepth int
+	mux      sync.Mutex
+}
+
+func newOrphanStore() *orphanStore {
+	return &orphanStore{hashes: make(map[[32]byte]bool)}
+}
+
+func (s *orphanStore) add(b *Block, height int, depth int, reason string, now int64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	h := b.Hash()
+	if s.hashes[h] {
+		return
+	}
+	if len(s.orphans) == MaxOrphans {
+		delete(s.hashes, s.orphans[0].Block.Hash())
+		s.orphans = s.orphans[1:]
+	}
+	s.orphans = append(s.orphans, &OrphanBlock{Hash: hexHash(h), Height: height, Depth: depth, Reason: reason, Time: now, Block: b})
+	s.hashes[h] = true
+	s.count++
+	if depth > s.maxDepth {
+		s.maxDepth = depth
+	}
+}
+
+// rejectedTips is the set of the hashes of the tips of chains that failed validation with the time they were
+// rejected. It is kept apart from the orphanStore, so that reorged-out blocks do not push them out.
+type rejectedTips struct {
+	order [][32]byte
+	times map[[32]byte]int64
+	mux   sync.Mutex
+}
+
+func newRejectedTips() *rejectedTips {
+	return &rejectedTips{times: make(map[[32]byte]int64)}
+}
+
+func (r *rejectedTips) add(h [32]byte, now int64) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.times[h]; ok {
+		return
+	}
+	if len(r.order) == MaxRejectedTips {
+		delete(r.times, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.order = append(r.order, h)
+	r.times[h] = now
+}
+
+func (r *rejectedTips) contains(h [32]byte) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	_, ok := r.times[h]
+	return ok
+}
+
+// gc removes the tips rejected before rejectedBefore and returns how many it removed
+func (r *rejectedTips) gc(rejectedBefore int64) int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	kept := make([][32]byte, 0, len(r.order))
+	for _, h := range r.order {
+		if r.times[h] < rejectedBefore {
+			delete(r.times, h)
+			continue
+		}
+		kept = append(kept, h)
+	}
+	removed := len(r.order) - len(kept)
+	r.order = kept
+	return removed
+}
+
+// recordReorg adds the blocks of old after its fork point with chain to the orphan store
+// and publishes the ReorgEvent
+func (bc *Blockchain) recordReorg(old []*Block, chain []*Block) {
+	fork := forkPoint(old, chain)
+	depth := len(old) - fork
+	// A chain of the genesis block alone has no block to orphan
+	if depth == 0 || len(old) == 1 {
+		return
+	}
+	now := bc.clock.Now().Unix()
+	for i := fork; i < len(old); i++ {
+		bc.orphans.add(old[i], i, depth, OrphanReorg, now)
+	}
+	bc.orphans.mux.Lock()
+	bc.orphans.reorgs++
+	bc.orphans.mux.Unlock()
+	bc.logger.Printf("Reorg orphaned %d block(s) from height %d", depth, fork)
+	bc.events.publish(newReorgEvent(old, chain, fork))
+}
+
+// recordInvalidChain remembers the tip of a chain that failed validation whatever the local clock says,
+// so that the same chain is not validated again when a neighbor keeps offering it.
+func (bc *Blockchain) recordInvalidChain(chain []*Block) {
+	now := bc.clock.Now().Unix()
+	bc.rejected.add(chain[len(chain)-1].Hash(), now)
+	bc.orphans.add(chain[len(chain)-1], len(chain)-1, 0, OrphanInvalid, now)
+}
+
+// Orphans returns the blocks that left the chain in reorgs and the rejected chain tips
+func (bc *Blockchain) Orphans() *OrphanStats {
+	s := bc.orphans
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	orphans := make([]*OrphanBlock, len(s.orphans))
+	copy(orphans, s.orphans)
+	return &OrphanStats{Count: s.count, Reorgs: s.reorgs, MaxDepth: s.maxDepth, Orphans: orphans}
+}
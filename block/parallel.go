@@ -0,0 +1,65 @@
+package block
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// allParallel reports whether ok returns true for every index in [0, n), calling it from up to
+// runtime.NumCPU() goroutines. No more indexes are handed out once ok returned false.
+func allParallel(n int, ok func(i int) bool) bool {
+	workers := runtime.NumCPU()
+	if workers > n {
+		workers = n
+	}
+	var next int64 = -1
+	var failed int32
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				if !ok(i) {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return atomic.LoadInt32(&failed) == 0
+}
+
+// group runs functions in goroutines, at most limit at a time, like errgroup.Group with SetLimit.
+// The functions collect their own results.
+type group struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+func newGroup(limit int) *group {
+	return &group{sem: make(chan struct{}, limit)}
+}
+
+// Go waits until less than limit functions run and calls f in a new goroutine
+func (g *group) Go(f func()) {
+	g.sem <- struct{}{}
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			<-g.sem
+			g.wg.Done()
+		}()
+		f()
+	}()
+}
+
+// Wait waits for all the functions to return
+func (g *group) Wait() {
+	g.wg.Wait()
+}
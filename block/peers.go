@@ -0,0 +1,155 @@
+package block
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// DefaultBanDuration is how long a banned peer is kept out of the neighbors when no duration is given
+const DefaultBanDuration = 24 * time.Hour
+
+// PeerBan is a peer kept out of the neighbors until Until, a Unix time in seconds
+type PeerBan struct {
+	Address string `json:"address"`
+	Until   int64  `json:"until"`
+}
+
+// ValidPeerAddress reports whether addr is a host:port address a peer can be reached at
+func ValidPeerAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" || port == "" {
+		return fmt.Errorf("%w: %q is not host:port", ErrInvalidPeer, addr)
+	}
+	return nil
+}
+
+// AddPeer adds addr to the neighbors and keeps it there through the scans of SyncNeighbors until it is
+// removed. A ban of addr is lifted. The peer is asked for its NodeInfo right away.
+func (bc *Blockchain) AddPeer(ctx context.Context, addr string) error {
+	if err := ValidPeerAddress(addr); err != nil {
+		return err
+	}
+	bc.muxNeighbors.Lock()
+	if bc.manualPeers == nil {
+		bc.manualPeers = make(map[string]bool)
+	}
+	bc.manualPeers[addr] = true
+	delete(bc.bans, addr)
+	if !containsString(bc.neighbors, addr) {
+		bc.neighbors = append(bc.neighbors, addr)
+	}
+	bc.muxNeighbors.Unlock()
+
+	ni, ok := bc.handshakePeer(ctx, addr)
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	if ok && containsString(bc.neighbors, addr) {
+		if bc.peers == nil {
+			bc.peers = make(map[string]NodeInfo)
+		}
+		bc.peers[addr] = ni
+	}
+	return nil
+}
+
+// RemovePeer removes addr from the neighbors. A peer found by the scan comes back on the next
+// SyncNeighbors unless it is banned. It returns ErrUnknownPeer when addr is not a neighbor.
+func (bc *Blockchain) RemovePeer(addr string) error {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	if !containsString(bc.neighbors, addr) && !bc.manualPeers[addr] {
+		return fmt.Errorf("%w: %s", ErrUnknownPeer, addr)
+	}
+	bc.dropPeer(addr)
+	return nil
+}
+
+// BanPeer removes addr from the neighbors and keeps it out of them for d, DefaultBanDuration when d is not positive
+func (bc *Blockchain) BanPeer(addr string, d time.Duration) (PeerBan, error) {
+	if err := ValidPeerAddress(addr); err != nil {
+		return PeerBan{}, err
+	}
+	if d <= 0 {
+		d = DefaultBanDuration
+	}
+	until := bc.clock.Now().Add(d)
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	if bc.bans == nil {
+		bc.bans = make(map[string]time.Time)
+	}
+	bc.bans[addr] = until
+	bc.dropPeer(addr)
+	return PeerBan{Address: addr, Until: until.Unix()}, nil
+}
+
+// Bans returns the bans in effect, sorted by address
+func (bc *Blockchain) Bans() []PeerBan {
+	now := bc.clock.Now()
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	bans := make([]PeerBan, 0, len(bc.bans))
+	for addr, until := range bc.bans {
+		if now.Before(until) {
+			bans = append(bans, PeerBan{Address: addr, Until: until.Unix()})
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Address < bans[j].Address
+	})
+	return bans
+}
+
+// dropPeer removes addr from the neighbors and the added peers. The caller must hold muxNeighbors.
+func (bc *Blockchain) dropPeer(addr string) {
+	delete(bc.manualPeers, addr)
+	delete(bc.peers, addr)
+	neighbors := make([]string, 0, len(bc.neighbors))
+	for _, n := range bc.neighbors {
+		if n != addr {
+			neighbors = append(neighbors, n)
+		}
+	}
+	bc.neighbors = neighbors
+}
+
+// peerSet returns the scanned neighbors and the added peers without the banned ones, forgetting expired bans.
+// The caller must hold muxNeighbors.
+func (bc *Blockchain) peerSet(scanned []string) []string {
+	now := bc.clock.Now()
+	for addr, until := range bc.bans {
+		if !now.Before(until) {
+			delete(bc.bans, addr)
+		}
+	}
+	neighbors := make([]string, 0, len(scanned)+len(bc.manualPeers))
+	add := func(addr string) {
+		if _, banned := bc.bans[addr]; !banned && !containsString(neighbors, addr) {
+			neighbors = append(neighbors, addr)
+		}
+	}
+	for _, n := range scanned {
+		add(n)
+	}
+	manual := make([]string, 0, len(bc.manualPeers))
+	for addr := range bc.manualPeers {
+		manual = append(manual, addr)
+	}
+	sort.Strings(manual)
+	for _, addr := range manual {
+		add(addr)
+	}
+	return neighbors
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
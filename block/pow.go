@@ -0,0 +1,56 @@
+package block
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"strconv"
+)
+
+// powHeader is the guess block of a proof of work search serialized once with the nonce left out.
+// Every nonce is hashed by writing its digits between the prefix and the suffix in a reused buffer,
+// and the hash is compared with the target as a big-endian number, so the search does not allocate.
+type powHeader struct {
+	buf    []byte
+	prefix int
+	suffix []byte
+	target [32]byte
+	// easy is set for targets above every hash
+	easy bool
+}
+
+// newPoWHeader returns the powHeader of the block of transactions following previousHash at bits and
+// tagged with extraData. It hashes like validProof.
+func newPoWHeader(previousHash [32]byte, transactions []*Transaction, bits uint32, extraData string) *powHeader {
+	m, _ := json.Marshal(&Block{previousHash: previousHash, bits: bits, extraData: extraData, transactions: transactions})
+	i := bytes.Index(m, []byte(`"nonce":0`)) + len(`"nonce":`)
+	h := &powHeader{
+		buf:    make([]byte, i, len(m)+20),
+		prefix: i,
+		suffix: m[i+1:],
+	}
+	copy(h.buf, m[:i])
+	target := BitsToTarget(bits)
+	if target.BitLen() > 8*len(h.target) {
+		h.easy = true
+	} else {
+		target.FillBytes(h.target[:])
+	}
+	return h
+}
+
+// hash returns the hash of the guess block with nonce
+func (h *powHeader) hash(nonce int) [32]byte {
+	h.buf = strconv.AppendInt(h.buf[:h.prefix], int64(nonce), 10)
+	h.buf = append(h.buf, h.suffix...)
+	return sha256.Sum256(h.buf)
+}
+
+// valid reports whether nonce is a valid proof of work
+func (h *powHeader) valid(nonce int) bool {
+	if h.easy {
+		return true
+	}
+	hash := h.hash(nonce)
+	return bytes.Compare(hash[:], h.target[:]) < 0
+}
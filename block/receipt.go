@@ -0,0 +1,82 @@
+package block
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// TransactionReceipt is a portable proof of payment: the TransactionProof of a confirmed transaction with its
+// Position in the block and the Confirmations of the block when the receipt was issued, signed by the issuing node
+type TransactionReceipt struct {
+	TransactionProof
+	Position      int    `json:"position"`
+	Confirmations int    `json:"confirmations"`
+	ChainID       string `json:"chain_id"`
+	IssuedAt      int64  `json:"issued_at"`
+	PublicKey     string `json:"public_key"`
+	Signature     string `json:"signature"`
+}
+
+// Hash returns the SHA-256 of the receipt without its signature
+func (r *TransactionReceipt) Hash() [32]byte {
+	m, _ := json.Marshal(struct {
+		TransactionProof
+		Position      int    `json:"position"`
+		Confirmations int    `json:"confirmations"`
+		ChainID       string `json:"chain_id"`
+		IssuedAt      int64  `json:"issued_at"`
+	}{r.TransactionProof, r.Position, r.Confirmations, r.ChainID, r.IssuedAt})
+	return sha256.Sum256(m)
+}
+
+// Sign signs the receipt with key
+func (r *TransactionReceipt) Sign(key wallet.Signer) error {
+	publicKey, signature, err := signHash(key, r.Hash())
+	if err != nil {
+		return err
+	}
+	r.PublicKey, r.Signature = publicKey, signature
+	return nil
+}
+
+// VerifySignature reports whether the receipt is signed by the key in PublicKey
+func (r *TransactionReceipt) VerifySignature() bool {
+	return verifyHash(r.PublicKey, r.Signature, r.Hash())
+}
+
+// Verify reports whether the receipt is signed and its proof is about the block with hash blockHash.
+// Whether the signing node is trusted is up to the caller.
+func (r *TransactionReceipt) Verify(blockHash string) bool {
+	return r.VerifySignature() && r.TransactionProof.Verify(blockHash)
+}
+
+// TransactionReceipt returns the receipt of the confirmed transaction with txid signed by key
+func (bc *Blockchain) TransactionReceipt(txid [32]byte, key wallet.Signer) (*TransactionReceipt, bool, error) {
+	bc.mux.Lock()
+	height, j, ok := bc.findConfirmed(txid)
+	if !ok {
+		bc.mux.Unlock()
+		return nil, false, nil
+	}
+	b := bc.chain[height]
+	r := &TransactionReceipt{
+		TransactionProof: TransactionProof{
+			TxID:       hexHash(txid),
+			Height:     height,
+			BlockHash:  hexHash(b.Hash()),
+			MerkleRoot: hexHash(b.MerkleRoot()),
+			MerklePath: MerklePath(b.transactions, j),
+		},
+		Position:      j,
+		Confirmations: len(bc.chain) - height,
+		ChainID:       bc.chainID,
+		IssuedAt:      bc.clock.Now().Unix(),
+	}
+	bc.mux.Unlock()
+	if err := r.Sign(key); err != nil {
+		return nil, true, err
+	}
+	return r, true, nil
+}
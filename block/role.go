@@ -0,0 +1,132 @@
+package block
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Role is what part of the chain a node serves to others
+type Role string
+
+const (
+	// RoleArchive nodes serve every block of the chain
+	RoleArchive Role = "archive"
+	// RolePruned nodes serve only the last PruneDepth blocks and delegate older ones to archive peers
+	RolePruned Role = "pruned"
+	// DefaultPruneDepth is the number of recent blocks a pruned node serves
+	DefaultPruneDepth = 100
+)
+
+// ProtocolVersion is the version of the peer protocol spoken by this node: the HTTP endpoints
+// neighbors call and the JSON they exchange. It is bumped on incompatible changes.
+const ProtocolVersion = 1
+
+// ParseRole returns the Role named name, RoleArchive when name is empty
+func ParseRole(name string) (Role, error) {
+	switch Role(name) {
+	case RoleArchive, "":
+		return RoleArchive, nil
+	case RolePruned:
+		return RolePruned, nil
+	}
+	return "", fmt.Errorf("unknown node role %q", name)
+}
+
+// NodeInfo is what a node advertises about itself in the peer handshake
+type NodeInfo struct {
+	Role       Role  `json:"role"`
+	Height     int   `json:"height"`
+	PruneDepth int   `json:"prune_depth,omitempty"`
+	Time       int64 `json:"time,omitempty"`
+	// offset is how far the clock of the node was ahead of ours in the handshake, 0 when it sent no Time
+	offset time.Duration
+}
+
+// Serves reports whether a node with this info serves the block at height
+func (ni NodeInfo) Serves(height int) bool {
+	if height < 0 || height >= ni.Height {
+		return false
+	}
+	return ni.Role != RolePruned || height >= ni.Height-ni.PruneDepth
+}
+
+// Role returns the role of the node
+func (bc *Blockchain) Role() Role {
+	return bc.role
+}
+
+// NodeInfo returns the info the node advertises in the peer handshake, with the time of its clock in unix nanoseconds.
+// A node started from a Snapshot is pruned below the snapshot height whatever its role.
+func (bc *Blockchain) NodeInfo() NodeInfo {
+	chain, base := bc.view()
+	ni := NodeInfo{Role: bc.role, Height: len(chain), Time: bc.clock.Now().UnixNano()}
+	if bc.role == RolePruned {
+		ni.PruneDepth = bc.pruneDepth
+	}
+	if base != nil {
+		if full := len(chain) - base.Height; ni.Role != RolePruned || full < ni.PruneDepth {
+			ni.Role = RolePruned
+			ni.PruneDepth = full
+		}
+	}
+	return ni
+}
+
+// Serves reports whether the node serves the block at height to others.
+// Pruned nodes still validate and keep the whole chain for consensus.
+func (bc *Blockchain) Serves(height int) bool {
+	return bc.NodeInfo().Serves(height)
+}
+
+// handshake asks the neighbors for their NodeInfo. Neighbors that do not answer are
+// kept as neighbors, but are never asked for history.
+func (bc *Blockchain) handshake(ctx context.Context, neighbors []string) map[string]NodeInfo {
+	peers := make(map[string]NodeInfo, len(neighbors))
+	for _, n := range neighbors {
+		if ni, ok := bc.handshakePeer(ctx, n); ok {
+			peers[n] = ni
+		}
+	}
+	return peers
+}
+
+// handshakePeer asks the neighbor for its NodeInfo and measures the offset of its clock
+// against the middle of the round trip
+func (bc *Blockchain) handshakePeer(ctx context.Context, neighbor string) (NodeInfo, bool) {
+	sent := bc.clock.Now()
+	ni, ok := bc.transport.Handshake(ctx, neighbor)
+	if ok && ni.Time != 0 {
+		received := bc.clock.Now()
+		ni.offset = time.Unix(0, ni.Time).Sub(sent.Add(received.Sub(sent) / 2))
+	}
+	return ni, ok
+}
+
+// PeerInfo returns the NodeInfo the neighbor advertised in the last handshake
+func (bc *Blockchain) PeerInfo(neighbor string) (NodeInfo, bool) {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	ni, ok := bc.peers[neighbor]
+	return ni, ok
+}
+
+// HistoryPeer returns a neighbor serving the block at height, preferring archive nodes
+func (bc *Blockchain) HistoryPeer(height int) (string, bool) {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	found := ""
+	for _, n := range bc.neighbors {
+		ni, ok := bc.peers[n]
+		if !ok || !ni.Serves(height) {
+			continue
+		}
+		if ni.Role == RoleArchive {
+			return n, true
+		}
+		if found == "" {
+			found = n
+		}
+	}
+	return found, found != ""
+}
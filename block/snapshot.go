@@ -0,0 +1,258 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// DefaultSnapshotBlocks is the number of full blocks sent along with a snapshot when no height is asked for
+const DefaultSnapshotBlocks = 100
+
+var (
+	// ErrSnapshotUnavailable is returned for a snapshot of blocks this node does not have in full
+	ErrSnapshotUnavailable = errors.New("snapshot height is pruned on this node")
+	// ErrSnapshotMismatch is returned for a snapshot that does not match the checkpoint
+	ErrSnapshotMismatch = errors.New("snapshot does not match the checkpoint")
+)
+
+// BlockHeader is a block without its transactions, identified by its hash
+type BlockHeader struct {
+	Timestamp    int64  `json:"timestamp"`
+	Nonce        int    `json:"nonce"`
+	PreviousHash string `json:"previous_hash"`
+	Bits         uint32 `json:"bits,omitempty"`
+	ExtraData    string `json:"extra_data,omitempty"`
+	MerkleRoot   string `json:"merkle_root,omitempty"`
+	Hash         string `json:"hash"`
+}
+
+// Snapshot is the balance state after the first Height blocks of a chain, with the headers of those
+// blocks, signed by the node that made it. A node started from a snapshot keeps only headers of
+// these blocks and replays the blocks after them.
+type Snapshot struct {
+	Height    int                `json:"height"`
+	Headers   []BlockHeader      `json:"headers"`
+	Balances  map[string]float32 `json:"balances"`
+	Fees      float32            `json:"fees"`
+	Names     map[string]string  `json:"names,omitempty"`
+	PublicKey string             `json:"public_key"`
+	Signature string             `json:"signature"`
+}
+
+// Hash returns the SHA-256 of the snapshot without its signature, the hash checkpoints pin
+func (s *Snapshot) Hash() [32]byte {
+	m, _ := json.Marshal(struct {
+		Height   int                `json:"height"`
+		Headers  []BlockHeader      `json:"headers"`
+		Balances map[string]float32 `json:"balances"`
+		Fees     float32            `json:"fees"`
+		Names    map[string]string  `json:"names,omitempty"`
+	}{s.Height, s.Headers, s.Balances, s.Fees, s.Names})
+	return sha256.Sum256(m)
+}
+
+// Sign signs the snapshot with key
+func (s *Snapshot) Sign(key wallet.Signer) error {
+	publicKey, signature, err := signHash(key, s.Hash())
+	if err != nil {
+		return err
+	}
+	s.PublicKey, s.Signature = publicKey, signature
+	return nil
+}
+
+// VerifySignature reports whether the snapshot is signed by the key in PublicKey
+func (s *Snapshot) VerifySignature() bool {
+	return verifyHash(s.PublicKey, s.Signature, s.Hash())
+}
+
+// signHash signs h with key and returns the hex encoded public key and the signature
+func signHash(key wallet.Signer, h [32]byte) (string, string, error) {
+	sig, err := key.SignHash(h[:])
+	if err != nil {
+		return "", "", err
+	}
+	p := key.PublicKey()
+	return fmt.Sprintf("%064x%064x", p.X.Bytes(), p.Y.Bytes()), sig.String(), nil
+}
+
+// verifyHash reports whether signature is a signature of h by the hex encoded publicKey
+func verifyHash(publicKey string, signature string, h [32]byte) bool {
+	if len(publicKey) != 128 {
+		return false
+	}
+	sig, err := utils.ParseSignature(signature)
+	if err != nil {
+		return false
+	}
+	return ecdsa.Verify(utils.PublicKeyFromString(publicKey), h[:], sig.R, sig.S)
+}
+
+// Checkpoint pins the hash of the snapshot at Height, obtained out of band
+type Checkpoint struct {
+	Height int
+	Hash   [32]byte
+}
+
+// ParseCheckpoint parses a checkpoint in the form height:hash
+func ParseCheckpoint(s string) (Checkpoint, error) {
+	var c Checkpoint
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return c, fmt.Errorf("checkpoint %q must be height:hash", s)
+	}
+	height, err := strconv.Atoi(s[:i])
+	if err != nil || height < 1 {
+		return c, fmt.Errorf("checkpoint %q: invalid height", s)
+	}
+	h, err := hex.DecodeString(s[i+1:])
+	if err != nil || len(h) != len(c.Hash) {
+		return c, fmt.Errorf("checkpoint %q: hash must be 32 hex encoded bytes", s)
+	}
+	c.Height = height
+	copy(c.Hash[:], h)
+	return c, nil
+}
+
+func (c Checkpoint) String() string {
+	return fmt.Sprintf("%d:%x", c.Height, c.Hash)
+}
+
+// header returns the BlockHeader of the block
+func (b *Block) header() BlockHeader {
+	return BlockHeader{
+		Timestamp:    b.timestamp,
+		Nonce:        b.nonce,
+		PreviousHash: hexHash(b.previousHash),
+		Bits:         b.bits,
+		ExtraData:    b.extraData,
+		MerkleRoot:   b.committedRoot(),
+		Hash:         hexHash(b.Hash()),
+	}
+}
+
+// newPrunedBlock returns a Block with the header h and no transactions. Its hash is taken from h.
+func newPrunedBlock(h BlockHeader) (*Block, error) {
+	if len(h.ExtraData) > MaxExtraDataSize {
+		return nil, errors.New("extra data too large")
+	}
+	b := &Block{timestamp: h.Timestamp, nonce: h.Nonce, bits: h.Bits, extraData: h.ExtraData, pruned: true}
+	previousHash, err := ParseTxID(h.PreviousHash)
+	if err != nil {
+		return nil, fmt.Errorf("previous hash: %w", err)
+	}
+	hash, err := ParseTxID(h.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("hash: %w", err)
+	}
+	if h.MerkleRoot != "" {
+		if b.merkleRoot, err = ParseTxID(h.MerkleRoot); err != nil {
+			return nil, fmt.Errorf("merkle root: %w", err)
+		}
+	}
+	b.previousHash = previousHash
+	b.hash = hash
+	return b, nil
+}
+
+// Snapshot returns a snapshot signed by key of the state after the first height blocks, and the blocks after them
+func (bc *Blockchain) Snapshot(height int, key wallet.Signer) (*Snapshot, []*Block, error) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	blocks := len(bc.chain) - height
+	base := bc.snapshotHeight()
+	if blocks < 1 || height < 1 || height < base {
+		return nil, nil, ErrSnapshotUnavailable
+	}
+	s := &Snapshot{
+		Height:   height,
+		Headers:  make([]BlockHeader, 0, height),
+		Balances: make(map[string]float32),
+		Names:    make(map[string]string),
+	}
+	if bc.base != nil {
+		for a, v := range bc.base.Balances {
+			s.Balances[a] = v
+		}
+		for n, a := range bc.base.Names {
+			s.Names[n] = a
+		}
+		s.Fees = bc.base.Fees
+	}
+	for i, b := range bc.chain[:height] {
+		s.Headers = append(s.Headers, b.header())
+		if i < base {
+			continue
+		}
+		applyBalances(s.Balances, b.transactions)
+		for _, t := range b.transactions {
+			if t.name != "" && t.recipientBlockchainAddress == BurnAddress {
+				if _, ok := s.Names[t.name]; !ok {
+					s.Names[t.name] = t.senderBlockchainAddress
+				}
+			}
+		}
+		s.Fees += totalFees(b.transactions)
+	}
+	if err := s.Sign(key); err != nil {
+		return nil, nil, err
+	}
+	chain := make([]*Block, blocks)
+	copy(chain, bc.chain[height:])
+	return s, chain, nil
+}
+
+// LoadSnapshot replaces the chain with the headers of snapshot s followed by blocks, after checking
+// that s matches checkpoint, is signed by trusted, if not nil, and that blocks are a valid continuation.
+func (bc *Blockchain) LoadSnapshot(s *Snapshot, blocks []*Block, checkpoint Checkpoint, trusted *ecdsa.PublicKey) error {
+	if s.Height != checkpoint.Height || s.Hash() != checkpoint.Hash {
+		return ErrSnapshotMismatch
+	}
+	if !s.VerifySignature() {
+		return fmt.Errorf("%w: snapshot signature", ErrInvalidSignature)
+	}
+	if trusted != nil && s.PublicKey != fmt.Sprintf("%064x%064x", trusted.X.Bytes(), trusted.Y.Bytes()) {
+		return fmt.Errorf("%w: snapshot is not signed by the trusted key", ErrInvalidSignature)
+	}
+	if len(s.Headers) != s.Height || len(blocks) == 0 {
+		return fmt.Errorf("%w: snapshot has %d headers for height %d and %d blocks", ErrChainInvalid, len(s.Headers), s.Height, len(blocks))
+	}
+	chain := make([]*Block, 0, s.Height+len(blocks))
+	for _, h := range s.Headers {
+		b, err := newPrunedBlock(h)
+		if err != nil {
+			return fmt.Errorf("%w: snapshot header %d: %v", ErrChainInvalid, len(chain), err)
+		}
+		chain = append(chain, b)
+	}
+	chain = append(chain, blocks...)
+	if !bc.ValidChain(chain) {
+		return ErrChainInvalid
+	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.recordReorg(bc.chain, chain)
+	bc.setChain(chain, 0, s)
+	bc.tipChanged()
+	bc.publishFrom(chain, s.Height)
+	return nil
+}
+
+// snapshotHeight returns the number of blocks of the chain known only by their headers
+func (bc *Blockchain) snapshotHeight() int {
+	if bc.base == nil {
+		return 0
+	}
+	return bc.base.Height
+}
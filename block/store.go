@@ -0,0 +1,470 @@
+package block
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// BlockStore keeps the chain of a Blockchain on disk so that it survives restarts
+type BlockStore interface {
+	// Load returns the stored chain and the Snapshot it starts from, nil when it starts with the genesis
+	// block, or no blocks when nothing is stored
+	Load() ([]*Block, *Snapshot, error)
+	// Append stores b after the stored blocks
+	Append(b *Block) error
+	// Replace stores chain starting from base, keeping the stored blocks below height fork
+	Replace(chain []*Block, fork int, base *Snapshot) error
+	Close() error
+}
+
+// UseStore makes the chain persistent in s. A chain stored in s replaces the chain once validated, otherwise the
+// chain is stored. It must be called before Run and after the difficulty and retargeting are configured.
+func (bc *Blockchain) UseStore(s BlockStore) error {
+	chain, base, err := s.Load()
+	if err != nil {
+		return err
+	}
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if len(chain) == 0 {
+		if err := s.Replace(bc.chain, 0, bc.base); err != nil {
+			return err
+		}
+		bc.store = s
+		return nil
+	}
+	// The store holds the node's own chain, which may start from a genesis block of an earlier configuration
+	if !bc.validBlocks(chain) {
+		return fmt.Errorf("%w: stored chain", ErrChainInvalid)
+	}
+	bc.setChain(chain, 0, base)
+	bc.store = s
+	bc.logger.Printf("Loaded %d blocks from the store", len(chain))
+	return nil
+}
+
+// storeBlock appends b to the store, if any. The caller must hold mux.
+func (bc *Blockchain) storeBlock(b *Block) {
+	if bc.store == nil {
+		return
+	}
+	if err := bc.store.Append(b); err != nil {
+		bc.logger.Printf("ERROR: store block: %v", err)
+	}
+}
+
+// storeChain replaces the blocks of the store, if any, from height fork. The caller must hold mux.
+func (bc *Blockchain) storeChain(chain []*Block, fork int, base *Snapshot) {
+	if bc.store == nil {
+		return
+	}
+	if err := bc.store.Replace(chain, fork, base); err != nil {
+		bc.logger.Printf("ERROR: store chain: %v", err)
+	}
+}
+
+// Close closes the store, if any. The chain is kept in memory only from then on.
+func (bc *Blockchain) Close() error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if bc.store == nil {
+		return nil
+	}
+	err := bc.store.Close()
+	bc.store = nil
+	return err
+}
+
+const (
+	chainFileName    = "chain.jsonl"
+	snapshotFileName = "snapshot.json"
+	// compressedSuffix is appended to the file names of a compressed FileStore
+	compressedSuffix = ".gz"
+)
+
+// FileStore is a BlockStore in a directory: chain.jsonl holds the blocks in the format of WriteChain, a line
+// appended for every block, and snapshot.json the Snapshot the chain starts from. The blocks known only by
+// the headers of the snapshot are not written. A line torn by a crash is dropped when loading.
+//
+// A compressed FileStore keeps chain.jsonl.gz and snapshot.json.gz instead, every line of the chain in a gzip
+// member of its own so that blocks are still appended and truncated one by one. Files stored the other way are
+// read and rewritten when loading, so a node can switch between the two.
+type FileStore struct {
+	dir      string
+	compress bool
+	file     *os.File
+	// fileCompressed is whether file is compressed, unlike compress until a store kept the other way is rewritten
+	fileCompressed bool
+	// offsets are the offsets of the lines of the stored blocks followed by the end of the last one
+	offsets []int64
+	// base is the height of the first stored block
+	base int
+	mux  sync.Mutex
+}
+
+// OpenFileStore opens the FileStore in dir, creating dir when it does not exist. The blocks are compressed with gzip
+// when compress is true.
+func OpenFileStore(dir string, compress bool) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, compress: compress, fileCompressed: compress}
+	// A chain kept the other way is loaded from its file and rewritten
+	if _, err := os.Stat(s.path(chainFileName, compress)); os.IsNotExist(err) {
+		if _, err := os.Stat(s.path(chainFileName, !compress)); err == nil {
+			s.fileCompressed = !compress
+		}
+	}
+	f, err := os.OpenFile(s.path(chainFileName, s.fileCompressed), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s.file = f
+	return s, nil
+}
+
+// path returns the path of the file name in the directory of the store, compressed or not
+func (s *FileStore) path(name string, compressed bool) string {
+	if compressed {
+		name += compressedSuffix
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// Load returns the stored chain
+func (s *FileStore) Load() ([]*Block, *Snapshot, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	base, err := s.loadSnapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	var lines []json.RawMessage
+	var offsets []int64
+	var offset int64
+	if s.fileCompressed {
+		lines, offsets, offset, err = readCompressedLines(s.file)
+	} else {
+		lines, offsets, offset, err = readLines(s.file)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(lines) == 0 {
+		// Nothing is stored, the first Replace writes the files
+		s.offsets = nil
+		return nil, nil, s.file.Truncate(0)
+	}
+	if err := s.file.Truncate(offset); err != nil {
+		return nil, nil, err
+	}
+	var header chainHeader
+	if err := json.Unmarshal(lines[0], &header); err != nil {
+		return nil, nil, fmt.Errorf("%s: header: %w", chainFileName, err)
+	}
+	raw, err := MigrateChain(header.Version, lines[1:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", chainFileName, err)
+	}
+
+	chain := make([]*Block, 0)
+	if base != nil {
+		for _, h := range base.Headers {
+			b, err := newPrunedBlock(h)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: header %d: %w", snapshotFileName, len(chain), err)
+			}
+			chain = append(chain, b)
+		}
+	}
+	for _, m := range raw {
+		b, err := DecodeBlock(m)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: block %d: %w", chainFileName, len(chain), err)
+		}
+		chain = append(chain, b)
+	}
+	s.base = len(chain) - len(raw)
+	s.offsets = append(offsets[1:], offset)
+	if header.Version != ChainFormatVersion || s.fileCompressed != s.compress {
+		if err := s.rewrite(chain, base); err != nil {
+			return nil, nil, err
+		}
+	}
+	return chain, base, nil
+}
+
+// loadSnapshot returns the stored Snapshot, nil when there is none. The caller must hold mux.
+func (s *FileStore) loadSnapshot() (*Snapshot, error) {
+	// A snapshot kept the other way is rewritten with the chain
+	for _, compressed := range []bool{s.compress, !s.compress} {
+		m, err := ioutil.ReadFile(s.path(snapshotFileName, compressed))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if compressed {
+			if m, err = gunzip(m); err != nil {
+				return nil, fmt.Errorf("%s%s: %w", snapshotFileName, compressedSuffix, err)
+			}
+		}
+		base := &Snapshot{}
+		if err := json.Unmarshal(m, base); err != nil {
+			return nil, fmt.Errorf("%s: %w", snapshotFileName, err)
+		}
+		return base, nil
+	}
+	return nil, nil
+}
+
+// readLines returns the lines of the chain file r, the offsets they start at and the end of the last one
+func readLines(r io.Reader) ([]json.RawMessage, []int64, int64, error) {
+	br := bufio.NewReader(r)
+	var lines []json.RawMessage
+	var offsets []int64
+	var offset int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without its newline was torn while written
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		offsets = append(offsets, offset)
+		offset += int64(len(line))
+		lines = append(lines, bytes.TrimSpace(line))
+	}
+	return lines, offsets, offset, nil
+}
+
+// readCompressedLines is readLines for a compressed chain file, a gzip member for every line
+func readCompressedLines(r io.Reader) ([]json.RawMessage, []int64, int64, error) {
+	// gzip reads no further than the end of a member from an io.ByteReader, the offsets are exact
+	cr := &countingReader{r: bufio.NewReader(r)}
+	var lines []json.RawMessage
+	var offsets []int64
+	var offset int64
+	for {
+		zr, err := gzip.NewReader(cr)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			// A member without its header was torn while written
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		zr.Multistream(false)
+		line, err := ioutil.ReadAll(zr)
+		if err == io.ErrUnexpectedEOF {
+			// A member without its trailer was torn while written
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		offsets = append(offsets, offset)
+		offset = cr.n
+		lines = append(lines, bytes.TrimSpace(line))
+	}
+	return lines, offsets, offset, nil
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// gzipBytes returns m compressed in a gzip member
+func gzipBytes(m []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	// Writing to a bytes.Buffer does not fail
+	zw.Write(m)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// gunzip returns the gzip compressed m decompressed
+func gunzip(m []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(m))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(zr)
+}
+
+// Append stores b after the stored blocks
+func (s *FileStore) Append(b *Block) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.append([]*Block{b})
+}
+
+// Replace stores chain starting from base, keeping the stored blocks below height fork.
+// A chain starting from another snapshot is written anew.
+func (s *FileStore) Replace(chain []*Block, fork int, base *Snapshot) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	height := 0
+	if base != nil {
+		height = base.Height
+	}
+	i := fork - s.base
+	if height != s.base || i < 0 || i >= len(s.offsets) || len(s.offsets) == 0 {
+		return s.rewrite(chain, base)
+	}
+	if err := s.file.Truncate(s.offsets[i]); err != nil {
+		return err
+	}
+	s.offsets = s.offsets[:i+1]
+	return s.append(chain[fork:])
+}
+
+// Close closes the chain file
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
+
+// append writes blocks at the end of the chain file. The caller must hold mux.
+func (s *FileStore) append(blocks []*Block) error {
+	if len(s.offsets) == 0 {
+		return errors.New("store is not loaded")
+	}
+	var buf bytes.Buffer
+	end := s.offsets[len(s.offsets)-1]
+	for _, b := range blocks {
+		m, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		s.writeLine(&buf, append(m, '\n'))
+		s.offsets = append(s.offsets, end+int64(buf.Len()))
+	}
+	if _, err := s.file.WriteAt(buf.Bytes(), end); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// writeLine writes line to buf as the chain file keeps it, in a gzip member of its own when compressed
+func (s *FileStore) writeLine(buf *bytes.Buffer, line []byte) {
+	if s.compress {
+		buf.Write(gzipBytes(line))
+		return
+	}
+	buf.Write(line)
+}
+
+// rewrite writes the files of chain starting from base anew, replacing the old ones only once complete.
+// The caller must hold mux.
+func (s *FileStore) rewrite(chain []*Block, base *Snapshot) error {
+	height := 0
+	snapshotPath := s.path(snapshotFileName, s.compress)
+	if base != nil {
+		height = base.Height
+		m, _ := json.Marshal(base)
+		if s.compress {
+			m = gzipBytes(m)
+		}
+		if err := writeFileSync(snapshotPath+".tmp", m); err != nil {
+			return err
+		}
+		if err := os.Rename(snapshotPath+".tmp", snapshotPath); err != nil {
+			return err
+		}
+	} else if err := os.Remove(snapshotPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var chainBuf bytes.Buffer
+	if err := WriteChain(&chainBuf, chain[height:]); err != nil {
+		return err
+	}
+	// Every line of WriteChain ends with a newline, the first one is the header
+	var buf bytes.Buffer
+	offsets := s.offsets[:0]
+	m := chainBuf.Bytes()
+	for offset := 0; offset < len(m); {
+		end := offset + bytes.IndexByte(m[offset:], '\n') + 1
+		s.writeLine(&buf, m[offset:end])
+		offsets = append(offsets, int64(buf.Len()))
+		offset = end
+	}
+	chainPath := s.path(chainFileName, s.compress)
+	if err := writeFileSync(chainPath+".tmp", buf.Bytes()); err != nil {
+		return err
+	}
+	if err := os.Rename(chainPath+".tmp", chainPath); err != nil {
+		return err
+	}
+	// Files kept the other way are only removed once replaced
+	for _, name := range []string{chainFileName, snapshotFileName} {
+		if err := os.Remove(s.path(name, !s.compress)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	f, err := os.OpenFile(chainPath, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = f
+	s.fileCompressed = s.compress
+	s.base = height
+	s.offsets = offsets
+	return nil
+}
+
+// writeFileSync writes m to the file at path and syncs it to disk
+func writeFileSync(path string, m []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(m); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,71 @@
+package block
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SyncTolerance is how many blocks a node may be behind its best peer without syncing,
+// the blocks mined while they travel
+const SyncTolerance = 1
+
+// SyncStatus is how far the chain is behind the highest chain advertised by the neighbors. Peers are the
+// neighbors ahead of the node, ETA the seconds left at the rate blocks were added since syncing started.
+type SyncStatus struct {
+	Syncing      bool     `json:"syncing"`
+	Height       int      `json:"height"`
+	TargetHeight int      `json:"target_height"`
+	Peers        []string `json:"peers,omitempty"`
+	ETA          float64  `json:"eta,omitempty"`
+}
+
+// syncProgress is where and when the current sync started, to estimate its ETA
+type syncProgress struct {
+	syncing bool
+	height  int
+	start   time.Time
+	mux     sync.Mutex
+}
+
+// eta records height at now and returns the seconds left to reach target, 0 when unknown or not syncing
+func (p *syncProgress) eta(syncing bool, height int, target int, now time.Time) float64 {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if !syncing {
+		p.syncing = false
+		return 0
+	}
+	if !p.syncing {
+		p.syncing, p.height, p.start = true, height, now
+		return 0
+	}
+	elapsed := now.Sub(p.start).Seconds()
+	if height <= p.height || elapsed <= 0 {
+		return 0
+	}
+	rate := float64(height-p.height) / elapsed
+	return float64(target-height) / rate
+}
+
+// SyncStatus returns the SyncStatus of the node from the heights the neighbors advertised in their last handshake
+func (bc *Blockchain) SyncStatus() SyncStatus {
+	chain, _ := bc.view()
+	s := SyncStatus{Height: len(chain), TargetHeight: len(chain)}
+	bc.muxNeighbors.Lock()
+	for _, n := range bc.neighbors {
+		ni, ok := bc.peers[n]
+		if !ok || ni.Height <= s.Height {
+			continue
+		}
+		s.Peers = append(s.Peers, n)
+		if ni.Height > s.TargetHeight {
+			s.TargetHeight = ni.Height
+		}
+	}
+	bc.muxNeighbors.Unlock()
+	sort.Strings(s.Peers)
+	s.Syncing = s.TargetHeight-s.Height > SyncTolerance
+	s.ETA = bc.syncProgress.eta(s.Syncing, s.Height, s.TargetHeight, bc.clock.Now())
+	return s
+}
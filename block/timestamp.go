@@ -0,0 +1,51 @@
+package block
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// MedianTimeSpan is the number of blocks whose median timestamp a new block must be later than
+	MedianTimeSpan = 11
+	// MaxFutureBlocks is how many block intervals of the retarget ahead of the local clock the timestamp of
+	// a block may be. Without these bounds a block dated far away would swing the retargets of the blocks after it.
+	MaxFutureBlocks = 6
+)
+
+// medianTimePast returns the median timestamp of the last MedianTimeSpan blocks of chain
+func medianTimePast(chain []*Block) int64 {
+	if len(chain) > MedianTimeSpan {
+		chain = chain[len(chain)-MedianTimeSpan:]
+	}
+	timestamps := make([]int64, 0, len(chain))
+	for _, b := range chain {
+		timestamps = append(timestamps, b.timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+// maxFutureBlockTime returns how far ahead of the local clock the timestamp of a block may be,
+// MaxFutureBlocks intervals of the retarget
+func (bc *Blockchain) maxFutureBlockTime() time.Duration {
+	return MaxFutureBlocks * RetargetSpacing(bc.retarget)
+}
+
+// afterMedianTimePast reports whether the block at height of chain is dated after the median time past
+// of the blocks before it. Unlike the bound on the local clock, it holds or not whenever it is checked.
+func afterMedianTimePast(chain []*Block, height int) bool {
+	return chain[height].timestamp > medianTimePast(chain[:height])
+}
+
+// aheadOfClock reports whether a block of chain after the genesis block is dated more than maxFutureBlockTime
+// ahead of the local clock. Such a chain may become valid as the clock advances.
+func (bc *Blockchain) aheadOfClock(chain []*Block) bool {
+	limit := bc.clock.Now().Add(bc.maxFutureBlockTime()).UnixNano()
+	for _, b := range chain[1:] {
+		if !b.pruned && b.timestamp > limit {
+			return true
+		}
+	}
+	return false
+}
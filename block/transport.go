@@ -0,0 +1,227 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// Transport is how a Blockchain finds and talks to its neighbors
+type Transport interface {
+	// FindNeighbors returns the addresses of the neighbors of the node listening on port
+	FindNeighbors(ctx context.Context, port uint16) []string
+	// SendTransaction sends a transaction to the neighbor
+	SendTransaction(ctx context.Context, neighbor string, tr *TransactionRequest) error
+	// ClearTransactions asks the neighbor to clear its transaction pool
+	ClearTransactions(ctx context.Context, neighbor string) error
+	// RequestConsensus asks the neighbor to resolve conflicts
+	RequestConsensus(ctx context.Context, neighbor string) error
+	// GetChain returns the chain of the neighbor, if it is encoded in at most maxSize bytes
+	GetChain(ctx context.Context, neighbor string, maxSize int64) ([]*Block, bool)
+	// Handshake returns the NodeInfo the neighbor advertises
+	Handshake(ctx context.Context, neighbor string) (NodeInfo, bool)
+	// SendAlert sends a double-spend alert to the neighbor
+	SendAlert(ctx context.Context, neighbor string, a *DoubleSpendAlert) error
+}
+
+const (
+	// PeerDialTimeout is how long connecting to a neighbor may take
+	PeerDialTimeout = 5 * time.Second
+	// PeerIdleConns is the number of idle keep-alive connections kept per neighbor
+	PeerIdleConns = 4
+	// PeerIdleTimeout is how long an idle keep-alive connection to a neighbor is kept
+	PeerIdleTimeout = 90 * time.Second
+)
+
+// HTTPTransport is the Transport of blockchain_server nodes. It keeps connections to the neighbors
+// alive for reuse and skips neighbors whose calls keep failing for a while, see BreakerThreshold.
+type HTTPTransport struct {
+	client    *http.Client
+	breaker   *breaker
+	logger    utils.Logger
+	startIP   uint8
+	endIP     uint8
+	startPort uint16
+	endPort   uint16
+	peerKeys  *peerKeys
+}
+
+// NewHTTPTransport returns a HTTPTransport scanning the default neighbor range
+func NewHTTPTransport() *HTTPTransport {
+	return &HTTPTransport{
+		client: &http.Client{Transport: &http.Transport{
+			DialContext:         newPeerDialer().DialContext,
+			MaxIdleConnsPerHost: PeerIdleConns,
+			IdleConnTimeout:     PeerIdleTimeout,
+		}},
+		breaker:   newBreaker(),
+		logger:    utils.StdLogger{},
+		startIP:   NeighborIpRangeStart,
+		endIP:     NeighborIpRangeEnd,
+		startPort: BlockchainPortRangeStart,
+		endPort:   BlockchainPortRangeEnd,
+	}
+}
+
+func newPeerDialer() *net.Dialer {
+	return &net.Dialer{Timeout: PeerDialTimeout, KeepAlive: 30 * time.Second}
+}
+
+// SetLogger sets where the transport writes its logs
+func (t *HTTPTransport) SetLogger(l utils.Logger) {
+	t.logger = l
+}
+
+// SetNeighborRange sets the address offset and port ranges FindNeighbors scans
+func (t *HTTPTransport) SetNeighborRange(startIP uint8, endIP uint8, startPort uint16, endPort uint16) {
+	t.startIP, t.endIP, t.startPort, t.endPort = startIP, endIP, startPort, endPort
+}
+
+// FindNeighbors scans the local network for blockchain nodes
+func (t *HTTPTransport) FindNeighbors(ctx context.Context, port uint16) []string {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return utils.FindNeighbors(utils.GetHost(), port, t.startIP, t.endIP, t.startPort, t.endPort)
+}
+
+// do sends a request to the neighbor unless its circuit is open. Transport errors and server errors
+// count as failures of the neighbor, not the cancellation of ctx.
+func (t *HTTPTransport) do(ctx context.Context, method string, neighbor string, path string, body []byte) (*http.Response, error) {
+	if !t.breaker.allow(neighbor) {
+		t.logger.Printf("Skipping %s: circuit open", neighbor)
+		return nil, fmt.Errorf("%s: %w", neighbor, ErrCircuitOpen)
+	}
+	endpoint := fmt.Sprintf("http://%s%s", neighbor, path)
+	req, _ := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewBuffer(body))
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.logger.Printf("ERROR: %v", err)
+		if ctx.Err() == nil && t.breaker.failure(neighbor) {
+			t.logger.Printf("Circuit of %s open for %v", neighbor, BreakerCooldown)
+		}
+		return nil, err
+	}
+	t.logger.Printf("method=%s, neighbor=%s, path=%s, status=%d", method, neighbor, path, resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		if t.breaker.failure(neighbor) {
+			t.logger.Printf("Circuit of %s open for %v", neighbor, BreakerCooldown)
+		}
+	} else {
+		t.breaker.success(neighbor)
+	}
+	return resp, nil
+}
+
+// send is do for requests whose response only carries a status
+func (t *HTTPTransport) send(ctx context.Context, method string, neighbor string, path string, body []byte) error {
+	resp, err := t.do(ctx, method, neighbor, path, body)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s %s: %s", method, neighbor, path, resp.Status)
+	}
+	return nil
+}
+
+// closeBody reads the rest of the body, so the connection is reused, and closes it
+func closeBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+}
+
+// SendTransaction is PUT /transactions
+func (t *HTTPTransport) SendTransaction(ctx context.Context, neighbor string, tr *TransactionRequest) error {
+	m, _ := json.Marshal(tr)
+	return t.send(ctx, http.MethodPut, neighbor, "/transactions", m)
+}
+
+// ClearTransactions is DELETE /transactions
+func (t *HTTPTransport) ClearTransactions(ctx context.Context, neighbor string) error {
+	return t.send(ctx, http.MethodDelete, neighbor, "/transactions", nil)
+}
+
+// RequestConsensus is PUT /consensus
+func (t *HTTPTransport) RequestConsensus(ctx context.Context, neighbor string) error {
+	return t.send(ctx, http.MethodPut, neighbor, "/consensus", nil)
+}
+
+// SendAlert is POST /alerts
+func (t *HTTPTransport) SendAlert(ctx context.Context, neighbor string, a *DoubleSpendAlert) error {
+	m, _ := json.Marshal(a)
+	return t.send(ctx, http.MethodPost, neighbor, "/alerts", m)
+}
+
+// GetChain is GET /chain
+func (t *HTTPTransport) GetChain(ctx context.Context, neighbor string, maxSize int64) ([]*Block, bool) {
+	resp, err := t.do(ctx, http.MethodGet, neighbor, "/chain", nil)
+	if err != nil {
+		return nil, false
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	var bcResp Blockchain
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, maxSize))
+	if err := decoder.Decode(&bcResp); err != nil {
+		t.logger.Printf("ERROR: %v", err)
+		return nil, false
+	}
+	return bcResp.Chain(), true
+}
+
+// Handshake is GET /handshake
+func (t *HTTPTransport) Handshake(ctx context.Context, neighbor string) (NodeInfo, bool) {
+	var ni NodeInfo
+	resp, err := t.do(ctx, http.MethodGet, neighbor, "/handshake", nil)
+	if err != nil {
+		return ni, false
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return ni, false
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ni); err != nil {
+		t.logger.Printf("ERROR: %v", err)
+		return ni, false
+	}
+	return ni, true
+}
+
+// SnapshotResponse is a Snapshot and the blocks following it. Checkpoint is only informative:
+// the checkpoint a node loads the snapshot against must come from elsewhere.
+type SnapshotResponse struct {
+	Checkpoint string    `json:"checkpoint"`
+	Snapshot   *Snapshot `json:"snapshot"`
+	Blocks     []*Block  `json:"blocks"`
+}
+
+// GetSnapshot is GET /snapshot?height=N
+func (t *HTTPTransport) GetSnapshot(ctx context.Context, peer string, height int) (*SnapshotResponse, error) {
+	resp, err := t.do(ctx, http.MethodGet, peer, fmt.Sprintf("/snapshot?height=%d", height), nil)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot from %s: %w", peer, err)
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("snapshot from %s: %s", peer, resp.Status)
+	}
+	var sr SnapshotResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return nil, fmt.Errorf("snapshot from %s: %w", peer, err)
+	}
+	if sr.Snapshot == nil {
+		return nil, fmt.Errorf("snapshot from %s: missing snapshot", peer)
+	}
+	return &sr, nil
+}
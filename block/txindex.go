@@ -0,0 +1,127 @@
+package block
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+const (
+	// TransactionPending is the status of a transaction in the transaction pool
+	TransactionPending = "pending"
+	// TransactionConfirmed is the status of a transaction in a block of the chain
+	TransactionConfirmed = "confirmed"
+)
+
+// TransactionStatus is where a transaction is: waiting in the pool or confirmed in a block
+type TransactionStatus struct {
+	TxID          string       `json:"txid"`
+	Status        string       `json:"status"`
+	Height        int          `json:"height,omitempty"`
+	BlockHash     string       `json:"block_hash,omitempty"`
+	Confirmations int          `json:"confirmations"`
+	Transaction   *Transaction `json:"transaction"`
+}
+
+// ParseTxID decodes a transaction id, the hex encoded Hash of the transaction
+func ParseTxID(s string) ([32]byte, error) {
+	var txid [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(txid) {
+		return txid, errors.New("txid must be 32 hex encoded bytes")
+	}
+	copy(txid[:], b)
+	return txid, nil
+}
+
+// TransactionProof proves that the transaction TxID is in the block at Height: MerklePath leads from
+// TxID to MerkleRoot, the root of the transactions of the block with hash BlockHash.
+type TransactionProof struct {
+	TxID       string        `json:"txid"`
+	Height     int           `json:"height"`
+	BlockHash  string        `json:"block_hash"`
+	MerkleRoot string        `json:"merkle_root"`
+	MerklePath []*MerkleNode `json:"merkle_path"`
+}
+
+// Verify reports whether the proof is consistent and about the block with hash blockHash, e.g. taken
+// from headers synced independently of whoever made the proof. MerkleRoot is taken from the proof,
+// VerifyHeader also checks it against the header of the block.
+func (p *TransactionProof) Verify(blockHash string) bool {
+	txid, err := ParseTxID(p.TxID)
+	if err != nil {
+		return false
+	}
+	return p.BlockHash == blockHash && VerifyTransactionInclusion(txid, p)
+}
+
+// VerifyHeader reports whether the proof is consistent and about the block of header h. The merkle root
+// of blocks whose hash commits to it must be the one of the header.
+func (p *TransactionProof) VerifyHeader(h BlockHeader) bool {
+	if h.MerkleRoot != "" && p.MerkleRoot != h.MerkleRoot {
+		return false
+	}
+	return p.Verify(h.Hash)
+}
+
+// VerifyTransactionInclusion reports whether proof proves that the transaction with hash txHash is in
+// the transactions of merkle root proof.MerkleRoot, without the transactions of the block
+func VerifyTransactionInclusion(txHash [32]byte, proof *TransactionProof) bool {
+	if proof.TxID != hexHash(txHash) {
+		return false
+	}
+	root, err := ParseTxID(proof.MerkleRoot)
+	if err != nil {
+		return false
+	}
+	return VerifyMerklePath(txHash, proof.MerklePath, root)
+}
+
+// findConfirmed returns the height of the block including the transaction with txid and its index there,
+// found in the balance index. The caller must hold mux.
+func (bc *Blockchain) findConfirmed(txid [32]byte) (int, int, bool) {
+	idx, _ := bc.balanceIndex()
+	defer bc.muxChain.RUnlock()
+	l, ok := idx.find(txid)
+	return l.height, l.index, ok
+}
+
+// TransactionStatus returns the status of the transaction with txid. A transaction
+// found in the chain wins over an identical one in the pool.
+func (bc *Blockchain) TransactionStatus(txid [32]byte) (*TransactionStatus, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if height, j, ok := bc.findConfirmed(txid); ok {
+		return &TransactionStatus{
+			TxID:          hexHash(txid),
+			Status:        TransactionConfirmed,
+			Height:        height,
+			BlockHash:     hexHash(blockView(bc.chain, height).Hash),
+			Confirmations: len(bc.chain) - height,
+			Transaction:   bc.chain[height].transactions[j],
+		}, true
+	}
+	if t, ok := bc.mempool.Find(txid); ok {
+		return &TransactionStatus{TxID: hexHash(txid), Status: TransactionPending, Transaction: t}, true
+	}
+	return nil, false
+}
+
+// TransactionProof returns the TransactionProof of the confirmed transaction with txid
+func (bc *Blockchain) TransactionProof(txid [32]byte) (*TransactionProof, bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	height, j, ok := bc.findConfirmed(txid)
+	if !ok {
+		return nil, false
+	}
+	b := bc.chain[height]
+	return &TransactionProof{
+		TxID:       hexHash(txid),
+		Height:     height,
+		BlockHash:  hexHash(b.Hash()),
+		MerkleRoot: hexHash(b.MerkleRoot()),
+		MerklePath: MerklePath(b.transactions, j),
+	}, true
+}
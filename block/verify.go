@@ -0,0 +1,123 @@
+package block
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ChainError describes the first rule a chain violates
+type ChainError struct {
+	// Height is the index of the offending block
+	Height int
+	// Transaction is the index of the offending transaction in the block, or -1
+	Transaction int
+	Reason      string
+}
+
+func (e *ChainError) Error() string {
+	if e.Transaction < 0 {
+		return fmt.Sprintf("block %d: %s", e.Height, e.Reason)
+	}
+	return fmt.Sprintf("block %d transaction %d: %s", e.Height, e.Transaction, e.Reason)
+}
+
+// VerifyChain replays chain from the genesis block, checking that it is the block of hash genesis, hash links, merkle roots, proof of work against
+// the target recorded in each block and no easier than difficulty, lock times, a single coinbase paying at most
+// the reward of emission and the fees of its block, and that no address other than the mining and anchor senders
+// spends more than its balance. It returns the first violation as a *ChainError.
+func VerifyChain(chain []*Block, genesis [32]byte, difficulty int, emission Emission) error {
+	return verifyChain(context.Background(), chain, genesis, difficulty, emission, make(map[string]float32), nil)
+}
+
+// verifyChain is VerifyChain starting from balances and calling progress, if not nil, with the number of blocks verified.
+// The transactions of pruned blocks are not known: only their hash links are checked.
+func verifyChain(ctx context.Context, chain []*Block, genesis [32]byte, difficulty int, emission Emission, balances map[string]float32, progress func(height int)) error {
+	if len(chain) == 0 {
+		return &ChainError{Height: 0, Transaction: -1, Reason: "missing genesis block"}
+	}
+	if chain[0].Hash() != genesis {
+		return &ChainError{Height: 0, Transaction: -1, Reason: fmt.Sprintf("genesis block hash %x is not the expected %x", chain[0].Hash(), genesis)}
+	}
+	minTarget := BitsToTarget(DifficultyToBits(difficulty))
+	for i, b := range chain {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(i)
+		}
+		if i > 0 {
+			if b.previousHash != chain[i-1].Hash() {
+				return &ChainError{Height: i, Transaction: -1, Reason: fmt.Sprintf("previous hash %x does not match block %d hash %x", b.previousHash, i-1, chain[i-1].Hash())}
+			}
+			if b.pruned {
+				continue
+			}
+			if b.bits == 0 || BitsToTarget(b.bits).Cmp(minTarget) > 0 {
+				return &ChainError{Height: i, Transaction: -1, Reason: fmt.Sprintf("bits %08x is easier than difficulty %d", b.bits, difficulty)}
+			}
+			if !validProof(b.nonce, b.previousHash, b.transactions, b.bits, b.extraData) {
+				return &ChainError{Height: i, Transaction: -1, Reason: fmt.Sprintf("nonce %d is not a valid proof of work at bits %08x", b.nonce, b.bits)}
+			}
+			if j := invalidCoinbase(b.transactions, emission.Reward(i)); j >= 0 {
+				return &ChainError{Height: i, Transaction: j, Reason: fmt.Sprintf("second coinbase or coinbase paying %.8g, above the reward %.8g and fees %.8g", b.transactions[j].value, emission.Reward(i), totalFees(b.transactions))}
+			}
+			if j := invalidNameRegistration(b.transactions); j >= 0 {
+				return &ChainError{Height: i, Transaction: j, Reason: "invalid name registration"}
+			}
+		}
+		// Blocks made before blocks carried the merkle root have none
+		if !b.pruned && b.merkleRoot != ([32]byte{}) && b.merkleRoot != MerkleRoot(b.transactions) {
+			return &ChainError{Height: i, Transaction: -1, Reason: fmt.Sprintf("merkle root %x does not match the transactions", b.merkleRoot)}
+		}
+		for j, t := range b.transactions {
+			if t.IsLocked(i, b.timestamp/int64(time.Second)) {
+				return &ChainError{Height: i, Transaction: j, Reason: fmt.Sprintf("transaction is locked until %d", t.lockUntil)}
+			}
+			if t.value < 0 {
+				return &ChainError{Height: i, Transaction: j, Reason: "negative value"}
+			}
+			sender := t.senderBlockchainAddress
+			if sender == BurnAddress {
+				return &ChainError{Height: i, Transaction: j, Reason: "burn address can not spend"}
+			}
+			if sender != MiningSender && sender != AnchorSender {
+				if balances[sender] < t.value+t.fee {
+					return &ChainError{Height: i, Transaction: j, Reason: fmt.Sprintf("%s spends %.8g with a balance of %.8g", sender, t.value+t.fee, balances[sender])}
+				}
+				balances[sender] -= t.value + t.fee
+			}
+			balances[t.recipientBlockchainAddress] += t.value
+		}
+	}
+	if progress != nil {
+		progress(len(chain))
+	}
+	return nil
+}
+
+// Verify replays the chain of the node like VerifyChain, starting from the balances of its snapshot if it has one,
+// and checks the targets against the retarget algorithm. progress, if not nil, is called with the number of blocks verified.
+func (bc *Blockchain) Verify(ctx context.Context, progress func(height int)) error {
+	bc.mux.Lock()
+	chain := make([]*Block, len(bc.chain))
+	copy(chain, bc.chain)
+	balances := make(map[string]float32)
+	if bc.base != nil {
+		for a, v := range bc.base.Balances {
+			balances[a] = v
+		}
+	}
+	bc.mux.Unlock()
+
+	if err := verifyChain(ctx, chain, chain[0].Hash(), bc.Difficulty(), bc.Emission(), balances, progress); err != nil {
+		return err
+	}
+	for i := 1; i < len(chain); i++ {
+		if !chain[i].pruned && chain[i].bits != bc.requiredBits(chain[:i]) {
+			return &ChainError{Height: i, Transaction: -1, Reason: fmt.Sprintf("bits %08x do not match the required target %08x", chain[i].bits, bc.requiredBits(chain[:i]))}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxMiningJobs is the number of outstanding mining jobs kept for external miners
+const MaxMiningJobs = 16
+
+// MiningJob is a candidate block handed to an external miner. The miner searches for a nonce
+// such that sha256(HeaderPrefix + nonce + HeaderSuffix), read as a number, is not above Target.
+type MiningJob struct {
+	id           string
+	previousHash [32]byte
+	transactions []*Transaction
+	difficulty   int
+	bits         uint32
+	extraData    string
+}
+
+// ID returns the job id used to submit work
+func (j *MiningJob) ID() string {
+	return j.id
+}
+
+// MarshalJSON is returns a struct with job_id, previous_hash, difficulty, bits, target, extra_data, header_prefix,
+// header_suffix, transactions
+func (j *MiningJob) MarshalJSON() ([]byte, error) {
+	m, _ := json.Marshal(&Block{previousHash: j.previousHash, bits: j.bits, extraData: j.extraData, transactions: j.transactions})
+	i := bytes.Index(m, []byte(`"nonce":0`)) + len(`"nonce":`)
+	return json.Marshal(struct {
+		JobID        string         `json:"job_id"`
+		PreviousHash string         `json:"previous_hash"`
+		Difficulty   int            `json:"difficulty"`
+		Bits         uint32         `json:"bits"`
+		Target       string         `json:"target"`
+		ExtraData    string         `json:"extra_data,omitempty"`
+		HeaderPrefix string         `json:"header_prefix"`
+		HeaderSuffix string         `json:"header_suffix"`
+		Transactions []*Transaction `json:"transactions"`
+	}{
+		JobID:        j.id,
+		PreviousHash: hexHash(j.previousHash),
+		Difficulty:   j.difficulty,
+		Bits:         j.bits,
+		Target:       targetString(j.bits),
+		ExtraData:    j.extraData,
+		HeaderPrefix: string(m[:i]),
+		HeaderSuffix: string(m[i+1:]),
+		Transactions: j.transactions,
+	})
+}
+
+// NewMiningJob returns a job for the next block with the unlocked pool transactions and the mining reward
+func (bc *Blockchain) NewMiningJob() *MiningJob {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	transactions := bc.selectTransactions()
+	reward := bc.emission.Reward(len(bc.chain)) + totalFees(transactions)
+	transactions = append(transactions, NewTransaction(MiningSender, bc.blockchainAddress, reward, 0).seal())
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	j := &MiningJob{
+		id:           fmt.Sprintf("%x", id),
+		previousHash: bc.LastBlock().Hash(),
+		transactions: transactions,
+		difficulty:   bc.difficulty,
+		bits:         bc.requiredBits(bc.chain),
+		extraData:    bc.extraData,
+	}
+	if bc.jobs == nil || len(bc.jobs) >= MaxMiningJobs {
+		bc.jobs = make(map[string]*MiningJob)
+	}
+	bc.jobs[j.id] = j
+	return j
+}
+
+// SubmitWork appends the block of the job when nonce is a valid proof of work for it
+// and the job still extends the last block.
+func (bc *Blockchain) SubmitWork(ctx context.Context, jobID string, nonce int) bool {
+	if !bc.submitWork(jobID, nonce) {
+		return false
+	}
+	bc.announceBlock(ctx)
+	return true
+}
+
+func (bc *Blockchain) submitWork(jobID string, nonce int) bool {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	j, ok := bc.jobs[jobID]
+	if !ok {
+		bc.logger.Println("ERROR: Unknown mining job")
+		return false
+	}
+	if j.previousHash != bc.LastBlock().Hash() {
+		bc.logger.Println("ERROR: Stale mining job")
+		delete(bc.jobs, jobID)
+		return false
+	}
+	if !validProof(nonce, j.previousHash, j.transactions, j.bits, j.extraData) {
+		bc.logger.Println("ERROR: Invalid proof of work")
+		return false
+	}
+
+	bc.appendMinedBlock(nonce, j.previousHash, j.extraData, j.transactions)
+	bc.logger.Println("action=mining, status=success, miner=external")
+	return true
+}
+
+// selectTransactions returns the pool transactions that can be included in the next block, highest fee first and
+// at most MaxBlockTransactions - 1 to leave room for the mining reward. The caller must hold mux.
+func (bc *Blockchain) selectTransactions() []*Transaction {
+	height := len(bc.chain)
+	now := bc.clock.Now().Unix()
+	transactions := bc.mempool.Unlocked(height, now)
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].fee > transactions[j].fee
+	})
+	if len(transactions) > MaxBlockTransactions-1 {
+		transactions = transactions[:MaxBlockTransactions-1]
+	}
+	return transactions
+}
+
+// appendMinedBlock appends a block of transactions tagged with extraData found by someone else than Mining.
+// The caller must hold mux and then announce the block to the neighbors.
+func (bc *Blockchain) appendMinedBlock(nonce int, previousHash [32]byte, extraData string, transactions []*Transaction) *Block {
+	b := bc.createBlock(nonce, previousHash, extraData, transactions)
+	bc.jobs = nil
+	return b
+}
+
+// BlockTemplate is a candidate block for custom mining software. A miner adds the
+// coinbase transaction (or its own), finds a nonce and submits the block, carrying Bits, with SubmitBlock.
+// ExtraData is the tag of the node, which the miner may replace with its own.
+type BlockTemplate struct {
+	Height       int            `json:"height"`
+	PreviousHash string         `json:"previous_hash"`
+	Difficulty   int            `json:"difficulty"`
+	Bits         uint32         `json:"bits"`
+	Target       string         `json:"target"`
+	Reward       float32        `json:"reward"`
+	Fees         float32        `json:"fees"`
+	ExtraData    string         `json:"extra_data,omitempty"`
+	Coinbase     *Transaction   `json:"coinbase"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// BlockTemplate returns the candidate next block
+func (bc *Blockchain) BlockTemplate() *BlockTemplate {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return bc.template(bc.selectTransactions())
+}
+
+// template returns the candidate next block of transactions. The caller must hold mux.
+func (bc *Blockchain) template(transactions []*Transaction) *BlockTemplate {
+	bits := bc.requiredBits(bc.chain)
+	reward := bc.emission.Reward(len(bc.chain))
+	fees := totalFees(transactions)
+	return &BlockTemplate{
+		Height:       len(bc.chain),
+		PreviousHash: hexHash(bc.LastBlock().Hash()),
+		Difficulty:   bc.difficulty,
+		Bits:         bits,
+		Target:       targetString(bits),
+		Reward:       reward,
+		Fees:         fees,
+		ExtraData:    bc.extraData,
+		Coinbase:     NewTransaction(MiningSender, bc.blockchainAddress, reward+fees, 0),
+		Transactions: transactions,
+	}
+}
+
+// SubmitBlock appends a block mined outside the node. The block must extend the last block,
+// carry a valid proof of work, pay at most the scheduled reward plus fees in a single coinbase transaction and
+// otherwise only contain unlocked transactions from the pool, since blocks do not carry signatures.
+func (bc *Blockchain) SubmitBlock(ctx context.Context, b *Block) error {
+	if err := bc.submitBlock(b); err != nil {
+		return err
+	}
+	bc.announceBlock(ctx)
+	return nil
+}
+
+func (bc *Blockchain) submitBlock(b *Block) error {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	height := len(bc.chain)
+	if b.previousHash != bc.LastBlock().Hash() {
+		return &ChainError{Height: height, Transaction: -1, Reason: "block does not extend the last block"}
+	}
+	if b.bits != bc.requiredBits(bc.chain) {
+		return &ChainError{Height: height, Transaction: -1, Reason: "block bits do not match the required target"}
+	}
+	if !validProof(b.nonce, b.previousHash, b.transactions, b.bits, b.extraData) {
+		return &ChainError{Height: height, Transaction: -1, Reason: "invalid proof of work"}
+	}
+	pool := make(map[[32]byte]bool, bc.mempool.Len())
+	for _, t := range bc.mempool.Transactions() {
+		pool[t.Hash()] = true
+	}
+	if i := invalidCoinbase(b.transactions, bc.emission.Reward(height)); i >= 0 {
+		return &ChainError{Height: height, Transaction: i, Reason: "invalid coinbase transaction"}
+	}
+	idx, _ := bc.balanceIndex()
+	i := idx.replayed(b.transactions)
+	bc.muxChain.RUnlock()
+	if i >= 0 {
+		return &ChainError{Height: height, Transaction: i, Reason: "transaction is already in the chain or reuses a nonce"}
+	}
+	for i, t := range b.transactions {
+		if t.senderBlockchainAddress == MiningSender {
+			continue
+		}
+		if !pool[t.Hash()] {
+			return &ChainError{Height: height, Transaction: i, Reason: "transaction is not in the pool"}
+		}
+		if t.IsLocked(height, b.timestamp/int64(time.Second)) {
+			return &ChainError{Height: height, Transaction: i, Reason: "transaction is locked"}
+		}
+	}
+	bc.appendMinedBlock(b.nonce, b.previousHash, b.extraData, b.transactions)
+	bc.logger.Println("action=mining, status=success, miner=submitted")
+	return nil
+}
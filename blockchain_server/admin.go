@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// SetAdminToken sets the bearer token of the /admin endpoints. They are disabled while it is empty.
+func (bcs *BlockchainServer) SetAdminToken(token string) {
+	bcs.adminToken = token
+}
+
+// admin wraps h so that it is only served to requests carrying the admin token
+func (bcs *BlockchainServer) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bcs.adminToken == "" {
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(bcs.adminToken)) != 1 {
+			bcs.logger.Printf("ERROR: unauthorized %s %s", r.Method, r.URL.Path)
+			w.Header().Add("Content-Type", "application/json")
+			w.Header().Add("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// VerifyStatus is the progress and result of the last full chain verification
+type VerifyStatus struct {
+	Running  bool   `json:"running"`
+	Verified int    `json:"verified"`
+	Height   int    `json:"height"`
+	Started  int64  `json:"started,omitempty"`
+	Finished int64  `json:"finished,omitempty"`
+	Valid    *bool  `json:"valid,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type verifier struct {
+	status VerifyStatus
+	mux    sync.Mutex
+}
+
+// start begins a verification of height blocks, false if one is already running
+func (v *verifier) start(height int) bool {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if v.status.Running {
+		return false
+	}
+	v.status = VerifyStatus{Running: true, Height: height, Started: time.Now().Unix()}
+	return true
+}
+
+func (v *verifier) progress(verified int) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.status.Verified = verified
+}
+
+func (v *verifier) finish(err error) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	valid := err == nil
+	v.status.Running = false
+	v.status.Finished = time.Now().Unix()
+	v.status.Valid = &valid
+	if err != nil {
+		v.status.Error = err.Error()
+	}
+}
+
+func (v *verifier) get() VerifyStatus {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return v.status
+}
+
+// AdminVerify is handler function that starts re-verifying the whole chain in the background
+func (bcs *BlockchainServer) AdminVerify(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		if !bcs.verifier.start(len(bc.Chain())) {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		go func() {
+			err := bc.Verify(bcs.ctx, bcs.verifier.progress)
+			if err != nil {
+				bcs.logger.Printf("ERROR: chain verification: %v", err)
+			} else {
+				bcs.logger.Println("Chain verification OK")
+			}
+			bcs.verifier.finish(err)
+		}()
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// AdminVerifyStatus is handler function that returns the VerifyStatus of the last verification
+func (bcs *BlockchainServer) AdminVerifyStatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.verifier.get())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// AdminMiner is handler function that returns the MinerStatus of the node
+func (bcs *BlockchainServer) AdminMiner(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.GetBlockchain().Miner().Status())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// AdminMinerControl is handler function that starts, stops, pauses or resumes the Miner
+// (POST /admin/miner/{start,stop,pause,resume}, or /admin/mining/...) and returns its MinerStatus
+func (bcs *BlockchainServer) AdminMinerControl(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		miner := bcs.GetBlockchain().Miner()
+		w.Header().Add("Content-Type", "application/json")
+		switch action := path.Base(r.URL.Path); action {
+		case "start":
+			miner.Start(bcs.ctx)
+		case "stop":
+			miner.Stop()
+		case "pause":
+			miner.Pause()
+		case "resume":
+			miner.Resume()
+		default:
+			bcs.logger.Printf("ERROR: Unknown miner action %q", action)
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(miner.Status())
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// writePeers writes the neighbors and the bans in effect
+func (bcs *BlockchainServer) writePeers(w http.ResponseWriter) {
+	bc := bcs.GetBlockchain()
+	m, _ := json.Marshal(struct {
+		Peers []string        `json:"peers"`
+		Bans  []block.PeerBan `json:"bans"`
+	}{
+		Peers: bc.Neighbors(),
+		Bans:  bc.Bans(),
+	})
+	io.WriteString(w, string(m))
+}
+
+// AdminPeers is handler function that returns the neighbors and the bans, or adds the peer
+// {"address": "host:port"} to the neighbors and keeps it there through the scans
+func (bcs *BlockchainServer) AdminPeers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		bcs.writePeers(w)
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var p struct {
+			Address string `json:"address"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			bcs.logger.Printf("ERROR %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if err := bcs.GetBlockchain().AddPeer(r.Context(), p.Address); err != nil {
+			bcs.writeError(w, err)
+			return
+		}
+		bcs.logger.Printf("Added peer %s", p.Address)
+		w.WriteHeader(http.StatusCreated)
+		bcs.writePeers(w)
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// AdminPeer is handler function that removes the peer of DELETE /admin/peers/{addr} from the neighbors,
+// or bans it with POST /admin/peers/{addr}/ban for ?duration=, DefaultBanDuration by default
+func (bcs *BlockchainServer) AdminPeer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/admin/peers/")
+	bc := bcs.GetBlockchain()
+	switch {
+	case r.Method == http.MethodDelete && !strings.Contains(path, "/"):
+		if err := bc.RemovePeer(path); err != nil {
+			bcs.writeError(w, err)
+			return
+		}
+		bcs.logger.Printf("Removed peer %s", path)
+		bcs.writePeers(w)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/ban"):
+		addr := strings.TrimSuffix(path, "/ban")
+		var d time.Duration
+		if v := r.URL.Query().Get("duration"); v != "" {
+			var err error
+			if d, err = time.ParseDuration(v); err != nil || d <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+		}
+		ban, err := bc.BanPeer(addr, d)
+		if err != nil {
+			bcs.writeError(w, err)
+			return
+		}
+		bcs.logger.Printf("Banned peer %s until %s", addr, time.Unix(ban.Until, 0).UTC().Format(time.RFC3339))
+		bcs.writePeers(w)
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+func TestAdminStopKeepsMiningStopped(t *testing.T) {
+	const token = "secret"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bcs := NewBlockchainServer(0, true, block.FixedRetarget{}, "", true, block.DefaultEmission)
+	bcs.ctx = ctx
+	bcs.SetAdminToken(token)
+	routes := bcs.routes()
+	bc := bcs.GetBlockchain()
+
+	serve := func(method string, path string, authorized bool) int {
+		r := httptest.NewRequest(method, path, nil)
+		if authorized {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve(http.MethodPost, "/admin/mining/start", true); code != http.StatusOK {
+		t.Fatalf("POST /admin/mining/start = %d", code)
+	}
+	if code := serve(http.MethodPost, "/admin/mining/stop", true); code != http.StatusOK {
+		t.Fatalf("POST /admin/mining/stop = %d", code)
+	}
+	height := len(bc.Chain())
+
+	for _, path := range []string{"/mine/start", "/mine"} {
+		if code := serve(http.MethodGet, path, false); code != http.StatusUnauthorized {
+			t.Errorf("GET %s without the admin token = %d, want %d", path, code, http.StatusUnauthorized)
+		}
+	}
+	if code := serve(http.MethodGet, "/mine", true); code != http.StatusConflict {
+		t.Errorf("GET /mine while stopped = %d, want %d", code, http.StatusConflict)
+	}
+	if state := bc.Miner().Status().State; state != block.MinerStopped {
+		t.Errorf("miner is %s, want %s", state, block.MinerStopped)
+	}
+	if got := len(bc.Chain()); got != height {
+		t.Errorf("chain grew from %d to %d blocks while mining was stopped", height, got)
+	}
+}
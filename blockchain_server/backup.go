@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// DefaultBackupInterval is the interval of chain backups
+const DefaultBackupInterval = time.Hour
+
+// Uploader stores chain backups, e.g. in a local directory or an object store
+type Uploader interface {
+	// Upload stores data under name, replacing an object of the same name
+	Upload(ctx context.Context, name string, data []byte) error
+}
+
+// ParseUploader returns the Uploader of dest: s3://bucket/prefix for an S3 compatible
+// object store at endpoint, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY,
+// otherwise a local directory.
+func ParseUploader(dest string, endpoint string, region string) (Uploader, error) {
+	if !strings.HasPrefix(dest, "s3://") {
+		return DirUploader{Dir: dest}, nil
+	}
+	bucket := strings.TrimPrefix(dest, "s3://")
+	prefix := ""
+	if i := strings.Index(bucket, "/"); i >= 0 {
+		bucket, prefix = bucket[:i], strings.Trim(bucket[i+1:], "/")
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("backup destination %q: missing bucket", dest)
+	}
+	u := &S3Uploader{
+		Endpoint:  endpoint,
+		Region:    region,
+		Bucket:    bucket,
+		Prefix:    prefix,
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		Client:    &http.Client{Timeout: time.Minute},
+	}
+	if u.AccessKey == "" || u.SecretKey == "" {
+		return nil, errors.New("backups to S3 need AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return u, nil
+}
+
+// DirUploader is an Uploader writing backups to a local directory
+type DirUploader struct {
+	Dir string
+}
+
+// Upload writes data to a temporary file and renames it, so that a backup is never half written
+func (u DirUploader) Upload(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(u.Dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(u.Dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(u.Dir, name))
+}
+
+// S3Uploader is an Uploader putting backups in a bucket of an S3 compatible object store,
+// addressed path-style and signed with AWS Signature Version 4
+type S3Uploader struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// Upload is PUT /{bucket}/{prefix}/{name}
+func (u *S3Uploader) Upload(ctx context.Context, name string, data []byte) error {
+	key := path.Join(u.Prefix, name)
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	endpoint := strings.TrimSuffix(u.Endpoint, "/") + "/" + url.PathEscape(u.Bucket) + "/" + strings.Join(segments, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	u.sign(req, data, time.Now().UTC())
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		m, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PUT %s: %s %s", req.URL, resp.Status, m)
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 headers of the request carrying payload
+func (u *S3Uploader) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", amzDate)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + u.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + u.SecretKey)
+	for _, s := range []string{date, u.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", u.AccessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// SetBackups makes the node upload its chain, as JSON Lines loadable with -load-chain, every interval.
+// It must be called before Run.
+func (bcs *BlockchainServer) SetBackups(u Uploader, interval time.Duration) {
+	bcs.uploader = u
+	bcs.backupInterval = interval
+}
+
+// backup uploads the chain as chain-{height}.jsonl
+func (bcs *BlockchainServer) backup(ctx context.Context) error {
+	chain := bcs.GetBlockchain().Chain()
+	var buf bytes.Buffer
+	if err := block.WriteChain(&buf, chain); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("chain-%d.jsonl", len(chain)-1)
+	if err := bcs.uploader.Upload(ctx, name, buf.Bytes()); err != nil {
+		return err
+	}
+	bcs.logger.Printf("Backed up %d blocks to %s", len(chain), name)
+	return nil
+}
+
+// StartBackups uploads a backup every backup interval until ctx is done
+func (bcs *BlockchainServer) StartBackups(ctx context.Context) {
+	if bcs.uploader == nil {
+		return
+	}
+	ticker := time.NewTicker(bcs.backupInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			if err := bcs.backup(ctx); err != nil {
+				bcs.logger.Printf("ERROR: backup: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -1,27 +1,180 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/noise"
 	"github.com/hirasawayuki/block_chain/utils"
 	"github.com/hirasawayuki/block_chain/wallet"
 )
 
-var cache map[string]*block.Blockchain = make(map[string]*block.Blockchain)
+// DefaultDifficultyHistory is the number of blocks returned by /difficulty without ?last=N
+const DefaultDifficultyHistory = 100
 
-// BlockchainServer is struct with port
+// DefaultBlocksPage is the number of blocks returned by /blocks without ?count=N
+const DefaultBlocksPage = 20
+
+// BlockchainServer is struct with port, regtest
 type BlockchainServer struct {
-	port uint16
+	port           uint16
+	regtest        bool
+	retarget       block.Retarget
+	minerAddress   string
+	mineEmpty      bool
+	emission       block.Emission
+	logger         utils.Logger
+	role           block.Role
+	pruneDepth     int
+	snapshotKey    wallet.Signer
+	snapshotSync   *snapshotSync
+	chainFile      string
+	adminToken     string
+	verifier       *verifier
+	uploader       Uploader
+	backupInterval time.Duration
+	driftThreshold time.Duration
+	driftWebhook   string
+	reorgWebhook   string
+	chainID        string
+	extraData      string
+	dataDir        string
+	compressStore  bool
+	noiseKey       *noise.Key
+	noiseRequired  bool
+	noisePeers     [][32]byte
+	blockchain     *block.Blockchain
+	ctx            context.Context
+	config         block.Config
+}
+
+// snapshotSync is where and how a fresh node fetches its starting Snapshot
+type snapshotSync struct {
+	peer       string
+	checkpoint block.Checkpoint
+	trusted    *ecdsa.PublicKey
+}
+
+// NewBlockchainServer is constructor that returns a BlockchainServer.
+// Mining rewards are paid to minerAddress, or to a new wallet when it is empty.
+func NewBlockchainServer(port uint16, regtest bool, retarget block.Retarget, minerAddress string, mineEmpty bool, emission block.Emission) *BlockchainServer {
+	return &BlockchainServer{
+		port:           port,
+		regtest:        regtest,
+		retarget:       retarget,
+		minerAddress:   minerAddress,
+		mineEmpty:      mineEmpty,
+		emission:       emission,
+		logger:         utils.StdLogger{},
+		role:           block.RoleArchive,
+		pruneDepth:     block.DefaultPruneDepth,
+		verifier:       &verifier{},
+		backupInterval: DefaultBackupInterval,
+		driftThreshold: block.DefaultMaxClockDrift,
+		ctx:            context.Background(),
+		config:         block.DefaultConfig(),
+	}
+}
+
+// SetNoise makes the node talk to its peers over Noise handshakes with the node key, encrypting and
+// authenticating the connections. Clients without Noise, e.g. wallet servers, are still served unless SetNoisePeers
+// requires the handshake. It must be called before Run.
+func (bcs *BlockchainServer) SetNoise(key *noise.Key) {
+	bcs.noiseKey = key
+}
+
+// SetNoisePeers makes the node refuse clients without the Noise handshake when required is true, and
+// initiators whose node key is not one of peers unless peers is nil. It must be called before Run.
+func (bcs *BlockchainServer) SetNoisePeers(required bool, peers [][32]byte) {
+	bcs.noiseRequired = required
+	bcs.noisePeers = peers
+}
+
+// transport returns a HTTPTransport for requests to peers outside the Blockchain
+func (bcs *BlockchainServer) transport() *block.HTTPTransport {
+	t := block.NewHTTPTransport()
+	if bcs.noiseKey != nil {
+		t.SetNoise(bcs.noiseKey)
+	}
+	return t
+}
+
+// SetConfig sets the mining difficulty, reward and interval, the neighbor sync interval and the range
+// scanned for neighbors, block.DefaultConfig by default. The reward replaces the initial reward of the
+// emission. It must be called before Run.
+func (bcs *BlockchainServer) SetConfig(c block.Config) {
+	bcs.config = c
+	bcs.emission.InitialReward = c.Reward
+}
+
+// SetChainID sets the ID of the network transactions are signed for, by default block.RegtestChainID
+// in regtest mode and block.DefaultChainID otherwise. It must be called before Run.
+func (bcs *BlockchainServer) SetChainID(chainID string) {
+	bcs.chainID = chainID
+}
+
+// SetExtraData sets the tag of the blocks mined by the node, at most block.MaxExtraDataSize bytes.
+// It must be called before Run.
+func (bcs *BlockchainServer) SetExtraData(extraData string) {
+	bcs.extraData = extraData
+}
+
+// ChainID returns the ID of the network transactions are signed for
+func (bcs *BlockchainServer) ChainID() string {
+	switch {
+	case bcs.chainID != "":
+		return bcs.chainID
+	case bcs.regtest:
+		return block.RegtestChainID
+	default:
+		return block.DefaultChainID
+	}
+}
+
+// SetChainFile makes the node start from the chain exported to path instead of its own genesis block.
+// It must be called before Run.
+func (bcs *BlockchainServer) SetChainFile(path string) {
+	bcs.chainFile = path
+}
+
+// SetDataDir makes the node keep its chain in a block.FileStore in dir, so that it survives restarts, compressed
+// when compress is true. The chain is kept in memory only when dir is empty. It must be called before Run.
+func (bcs *BlockchainServer) SetDataDir(dir string, compress bool) {
+	bcs.dataDir = dir
+	bcs.compressStore = compress
+}
+
+// SetSnapshotKey sets the key signing the snapshots the node serves, a new key every start by default
+func (bcs *BlockchainServer) SetSnapshotKey(key wallet.Signer) {
+	bcs.snapshotKey = key
 }
 
-// NewBlockchainServer is constructor that returns a BlockchainServer
-func NewBlockchainServer(port uint16) *BlockchainServer {
-	return &BlockchainServer{port}
+// SetSnapshotSync makes the node start from the snapshot of peer at the checkpoint height, and the blocks after it,
+// instead of syncing the whole chain. The snapshot must match checkpoint and be signed by trusted if it is not nil.
+// It must be called before Run.
+func (bcs *BlockchainServer) SetSnapshotSync(peer string, checkpoint block.Checkpoint, trusted *ecdsa.PublicKey) {
+	bcs.snapshotSync = &snapshotSync{peer, checkpoint, trusted}
+}
+
+// SetRole sets whether the node serves the whole chain or only its last pruneDepth blocks. It must be called before Run.
+func (bcs *BlockchainServer) SetRole(role block.Role, pruneDepth int) {
+	bcs.role = role
+	bcs.pruneDepth = pruneDepth
+}
+
+// SetLogger sets where the server and its Blockchain write their logs. It must be called before Run.
+func (bcs *BlockchainServer) SetLogger(l utils.Logger) {
+	bcs.logger = l
 }
 
 // Port is return BlockchainServer port
@@ -31,18 +184,53 @@ func (bcs *BlockchainServer) Port() uint16 {
 
 // GetBlockchain is return Blockchain pointer
 func (bcs *BlockchainServer) GetBlockchain() *block.Blockchain {
-	bc, ok := cache["blockchain"]
-	if !ok {
-		minersWallet := wallet.NewWallet()
-		bc = block.NewBlockChain(minersWallet.BlockchainAddress(), bcs.Port())
-		cache["blockchain"] = bc
-		log.Printf("private key: %v", minersWallet.PrivateKeyStr())
-		log.Printf("public key: %v", minersWallet.PublicKeyStr())
-		log.Printf("blockchain address: %v", minersWallet.BlockchainAddress())
+	bc := bcs.blockchain
+	if bc == nil {
+		minerAddress := bcs.minerAddress
+		if minerAddress == "" {
+			minersWallet := wallet.NewWallet()
+			minerAddress = minersWallet.BlockchainAddress()
+			bcs.logger.Printf("private key: %v", minersWallet.PrivateKeyStr())
+			bcs.logger.Printf("public key: %v", minersWallet.PublicKeyStr())
+		}
+		opts := []block.Option{block.WithConfig(bcs.config), block.WithLogger(bcs.logger), block.WithRole(bcs.role), block.WithPruneDepth(bcs.pruneDepth), block.WithClockDriftAlert(bcs.driftThreshold, bcs.driftAlert), block.WithAlertKey(bcs.snapshotKey), block.WithChainID(bcs.ChainID()), block.WithExtraData(bcs.extraData)}
+		if bcs.noiseKey != nil {
+			opts = append(opts, block.WithNoise(bcs.noiseKey))
+		}
+		bc = block.NewBlockChain(minerAddress, bcs.Port(), opts...)
+		bc.SetRegtest(bcs.regtest)
+		bc.SetRetarget(bcs.retarget)
+		bc.SetMineEmpty(bcs.mineEmpty)
+		bc.SetEmission(bcs.emission)
+		if bcs.dataDir != "" {
+			store, err := block.OpenFileStore(bcs.dataDir, bcs.compressStore)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := bc.UseStore(store); err != nil {
+				log.Fatalf("%s: %v", bcs.dataDir, err)
+			}
+		}
+		bcs.blockchain = bc
+		bcs.logger.Printf("blockchain address: %v", minerAddress)
 	}
 	return bc
 }
 
+// syncSnapshot loads the snapshot configured with SetSnapshotSync into the blockchain
+func (bcs *BlockchainServer) syncSnapshot(ctx context.Context) error {
+	ss := bcs.snapshotSync
+	sr, err := bcs.transport().GetSnapshot(ctx, ss.peer, ss.checkpoint.Height)
+	if err != nil {
+		return err
+	}
+	if err := bcs.GetBlockchain().LoadSnapshot(sr.Snapshot, sr.Blocks, ss.checkpoint, ss.trusted); err != nil {
+		return fmt.Errorf("snapshot from %s: %w", ss.peer, err)
+	}
+	bcs.logger.Printf("Loaded snapshot %s from %s with %d blocks", ss.checkpoint, ss.peer, len(sr.Blocks))
+	return nil
+}
+
 func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
@@ -62,77 +250,120 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 		var t block.TransactionRequest
 		err := decoder.Decode(&t)
 		if err != nil {
-			log.Printf("ERROR %v", err)
+			bcs.logger.Printf("ERROR %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !t.Validate() {
+			bcs.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		signature := utils.SignatureFromString(*t.Signature)
 
 		bc := bcs.GetBlockchain()
-		isCreated := bc.CreateTransaction(*t.SenderBlockchainAddress, *t.RecipientBlockchainAddress, *t.Value, publicKey, signature)
 		w.Header().Add("Content-Type", "application/json")
-		var m []byte
-		if !isCreated {
-			w.WriteHeader(http.StatusBadRequest)
-			m = utils.JsonStatus("fail")
-		} else {
-			w.WriteHeader(http.StatusCreated)
-			m = utils.JsonStatus("success")
+		result, err := bc.CreateTransactionRequest(req.Context(), &t)
+		if err != nil {
+			bcs.writeError(w, err)
+			return
 		}
-		io.WriteString(w, string(m))
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(broadcastStatus("success", result)))
 	case http.MethodPut:
 		decoder := json.NewDecoder(req.Body)
 		var t block.TransactionRequest
 		err := decoder.Decode(&t)
 		if err != nil {
-			log.Printf("ERROR %v", err)
+			bcs.logger.Printf("ERROR %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !t.Validate() {
+			bcs.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		signature := utils.SignatureFromString(*t.Signature)
 
 		bc := bcs.GetBlockchain()
-		isUpdated := bc.AddTransaction(*t.SenderBlockchainAddress, *t.RecipientBlockchainAddress, *t.Value, publicKey, signature)
 		w.Header().Add("Content-Type", "application/json")
-		var m []byte
-		if !isUpdated {
-			w.WriteHeader(http.StatusBadRequest)
-			m = utils.JsonStatus("fail")
-		} else {
-			m = utils.JsonStatus("success")
+		if err := bc.AddTransactionRequest(&t); err != nil {
+			bcs.writeError(w, err)
+			return
 		}
-		io.WriteString(w, string(m))
+		io.WriteString(w, string(utils.JsonStatus("success")))
 	case http.MethodDelete:
 		bc := bcs.GetBlockchain()
 		bc.ClearTransactionPool()
 		io.WriteString(w, string(utils.JsonStatus("success")))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
 	}
 }
 
 // GetChain is Handler
 func (bcs *BlockchainServer) GetChain(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
-	switch http.MethodGet {
+	switch r.Method {
 	case http.MethodGet:
 		bc := bcs.GetBlockchain()
 		m, _ := bc.MarshalJSON()
 		io.WriteString(w, string(m[:]))
 	default:
-		log.Printf("ERROR: Invalid HTTP Method")
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
 	}
 }
 
+// ChainStream is handler function that writes the chain as JSON Lines, one block per line,
+// from the block at ?from=N, by default the genesis block
+func (bcs *BlockchainServer) ChainStream(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		chain := bcs.GetBlockchain().Chain()
+		from := 0
+		if f := r.URL.Query().Get("from"); f != "" {
+			n, err := strconv.Atoi(f)
+			if err != nil || n < 0 {
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			from = n
+		}
+		w.Header().Add("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for i := from; i < len(chain); i++ {
+			if r.Context().Err() != nil {
+				return
+			}
+			if err := encoder.Encode(chain[i]); err != nil {
+				bcs.logger.Printf("ERROR: %v", err)
+				return
+			}
+		}
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Mine is handler function that mines a block now, served with the admin token while the Miner is running
 func (bcs *BlockchainServer) Mine(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		bc := bcs.GetBlockchain()
-		isMined := bc.Mining()
+		// A Miner paused or stopped by the operator stays so until /admin/miner starts or resumes it
+		if state := bc.Miner().Status().State; state != block.MinerRunning {
+			bcs.logger.Printf("ERROR: miner is %s", state)
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		isMined := bc.Mining(r.Context())
 
 		var m []byte
 		if !isMined {
@@ -144,21 +375,133 @@ func (bcs *BlockchainServer) Mine(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
 		io.WriteString(w, string(m))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusBadRequest)
 	}
 }
 
+// Generate is handler function that mines ?blocks=N blocks immediately in regtest mode
+func (bcs *BlockchainServer) Generate(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		if !bc.Regtest() {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		blocks := 1
+		if b := r.URL.Query().Get("blocks"); b != "" {
+			n, err := strconv.Atoi(b)
+			if err != nil || n < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			blocks = n
+		}
+		m, _ := json.Marshal(struct {
+			Blocks int `json:"blocks"`
+			Height int `json:"height"`
+		}{
+			Blocks: bc.Generate(r.Context(), blocks),
+			Height: len(bc.Chain()) - 1,
+		})
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// MiningWork is handler function that hands out mining jobs (GET /mining/work)
+// and accepts the nonces found by external miners (POST /mining/submit)
+func (bcs *BlockchainServer) MiningWork(w http.ResponseWriter, r *http.Request) {
+	bc := bcs.GetBlockchain()
+	w.Header().Add("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/mining/work":
+		m, _ := json.Marshal(bc.NewMiningJob())
+		io.WriteString(w, string(m))
+	case r.Method == http.MethodPost && r.URL.Path == "/mining/submit":
+		var sr struct {
+			JobID *string `json:"job_id"`
+			Nonce *int    `json:"nonce"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&sr); err != nil || sr.JobID == nil || sr.Nonce == nil {
+			bcs.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !bc.SubmitWork(r.Context(), *sr.JobID, *sr.Nonce) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// MiningTemplate is handler function that returns the candidate next block
+func (bcs *BlockchainServer) MiningTemplate(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(bc.BlockTemplate())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// SubmitBlock is handler function that accepts a full block mined outside the node
+func (bcs *BlockchainServer) SubmitBlock(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		m, err := ioutil.ReadAll(io.LimitReader(r.Body, block.MaxBlockSize+1))
+		if err != nil {
+			bcs.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		b, err := block.DecodeBlock(m)
+		if err == nil {
+			err = bcs.GetBlockchain().SubmitBlock(r.Context(), b)
+		}
+		if err != nil {
+			bcs.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// StartMine is handler function that mines now and starts or resumes the Miner, served with the admin token
 func (bcs *BlockchainServer) StartMine(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		bc := bcs.GetBlockchain()
-		bc.StartMining()
+		bc.StartMining(bcs.ctx)
 		m := utils.JsonStatus("success")
 		w.Header().Add("Content-Type", "application/json")
 		io.WriteString(w, string(m))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusBadRequest)
 	}
 }
@@ -170,14 +513,405 @@ func (bcs *BlockchainServer) Amount(w http.ResponseWriter, r *http.Request) {
 		bc := bcs.GetBlockchain()
 		amount := bc.CaluculateTotalAmount(blockchainAddress)
 		ar := &block.AmountResponse{
-			Amount: amount,
+			Amount:  amount,
+			Pending: bc.Mempool().PendingSpend(blockchainAddress),
+		}
+		if c := r.URL.Query().Get("confirmations"); c != "" {
+			confirmations, err := strconv.Atoi(c)
+			if err != nil || confirmations < 0 {
+				bcs.logger.Println("ERROR: invalid confirmations")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			spendable := bc.SpendableAmount(blockchainAddress, confirmations)
+			ar.Spendable = &spendable
 		}
 
 		m, _ := ar.MarshalJSON()
 		w.Header().Add("Content-Type", "application/json")
 		io.WriteString(w, string(m[:]))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Accounts is handler function that returns the AccountNonce of /accounts/{address}/nonce, the
+// unspent outputs of /accounts/{address}/unspent and the confirmed transactions of
+// /accounts/{address}/transactions, followed by the pending ones with ?pending=true.
+// /address/{address}/... is the same.
+func (bcs *BlockchainServer) Accounts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		p := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/address/")
+		parts := strings.Split(p, "/")
+		if len(parts) != 2 || parts[0] == "" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		bc := bcs.GetBlockchain()
+		switch parts[1] {
+		case "nonce":
+			m, _ := json.Marshal(bc.NextNonce(parts[0]))
+			io.WriteString(w, string(m))
+		case "unspent":
+			outputs := bc.UnspentOutputs(parts[0])
+			m, _ := json.Marshal(struct {
+				Amount  float32               `json:"amount"`
+				Outputs []block.UnspentOutput `json:"outputs"`
+				Length  int                   `json:"length"`
+			}{
+				Amount:  bc.CaluculateTotalAmount(parts[0]),
+				Outputs: outputs,
+				Length:  len(outputs),
+			})
+			io.WriteString(w, string(m))
+		case "transactions":
+			transactions := bc.TransactionsFor(parts[0])
+			if r.URL.Query().Get("pending") == "true" {
+				transactions = append(transactions, bc.PendingTransactionsFor(parts[0])...)
+			}
+			m, _ := json.Marshal(struct {
+				Address      string                     `json:"address"`
+				Transactions []block.AddressTransaction `json:"transactions"`
+				Length       int                        `json:"length"`
+			}{
+				Address:      parts[0],
+				Transactions: transactions,
+				Length:       len(transactions),
+			})
+			io.WriteString(w, string(m))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+		}
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Burned is handler function that returns the burned coins and the circulating supply
+func (bcs *BlockchainServer) Burned(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(struct {
+			BurnAddress string  `json:"burn_address"`
+			Burned      float32 `json:"burned"`
+			Supply      float32 `json:"supply"`
+		}{
+			BurnAddress: block.BurnAddress,
+			Burned:      bc.BurnedAmount(),
+			Supply:      bc.TotalSupply(),
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Supply is handler function that returns the coins minted so far, the current mining reward and,
+// with halvings, the maximum supply, all computed from the emission rules
+func (bcs *BlockchainServer) Supply(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		height := len(bc.Chain())
+		e := bc.Emission()
+		var max *float64
+		if p, ok := e.ProjectedSupply(); ok {
+			max = &p
+		}
+		m, _ := json.Marshal(struct {
+			Height      int      `json:"height"`
+			Minted      float64  `json:"minted"`
+			Reward      float32  `json:"reward"`
+			NextHalving int      `json:"next_halving,omitempty"`
+			MaxSupply   *float64 `json:"max_supply,omitempty"`
+		}{
+			Height:      height,
+			Minted:      e.Minted(height),
+			Reward:      e.Reward(height),
+			NextHalving: e.NextHalving(height),
+			MaxSupply:   max,
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Difficulty is handler function that returns the difficulty required of the next block and the difficulty
+// and block interval of the last blocks, DefaultDifficultyHistory unless ?last=N is given
+func (bcs *BlockchainServer) Difficulty(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		last := DefaultDifficultyHistory
+		if l := r.URL.Query().Get("last"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			last = n
+		}
+		bc := bcs.GetBlockchain()
+		bits := bc.NextBits()
+		m, _ := json.Marshal(struct {
+			Retarget      string                  `json:"retarget"`
+			TargetSpacing float64                 `json:"target_spacing"`
+			Bits          uint32                  `json:"bits"`
+			Difficulty    float64                 `json:"difficulty"`
+			Blocks        []block.DifficultyPoint `json:"blocks"`
+		}{
+			Retarget:      block.RetargetName(bcs.retarget),
+			TargetSpacing: block.RetargetSpacing(bcs.retarget).Seconds(),
+			Bits:          bits,
+			Difficulty:    block.BitsToDifficulty(bits),
+			Blocks:        bc.DifficultyHistory(last),
+		})
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Mempool is handler function that returns the pending transactions, only those sent from or to
+// ?address= and paying at least ?min_fee= when given, with their place among all pending transactions
+func (bcs *BlockchainServer) Mempool(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		q := r.URL.Query()
+		f := block.MempoolFilter{Address: q.Get("address")}
+		if v := q.Get("min_fee"); v != "" {
+			fee, err := strconv.ParseFloat(v, 32)
+			if err != nil || fee < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			f.MinFee = float32(fee)
+		}
+		bc := bcs.GetBlockchain()
+		entries := bc.MempoolEntries(f)
+		m, _ := json.Marshal(struct {
+			Pending      int                  `json:"pending"`
+			Transactions []block.MempoolEntry `json:"transactions"`
+			Length       int                  `json:"length"`
+		}{
+			Pending:      bc.Mempool().Len(),
+			Transactions: entries,
+			Length:       len(entries),
+		})
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// FeeStats is handler function that returns the FeeStats of the last block.DefaultFeeStatsBlocks blocks,
+// or of the last ?blocks=N
+func (bcs *BlockchainServer) FeeStats(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		blocks := block.DefaultFeeStatsBlocks
+		if b := r.URL.Query().Get("blocks"); b != "" {
+			n, err := strconv.Atoi(b)
+			if err != nil || n < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			blocks = n
+		}
+		m, _ := json.Marshal(bcs.GetBlockchain().FeeStats(blocks))
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Sync is handler function that returns the SyncStatus of the node
+func (bcs *BlockchainServer) Sync(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.GetBlockchain().SyncStatus())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Ready is handler function for readiness checks: it fails with 503 Service Unavailable while the node is syncing
+func (bcs *BlockchainServer) Ready(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		if bcs.GetBlockchain().SyncStatus().Syncing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, string(utils.JsonStatus("syncing")))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Headers is handler function that returns the headers of the blocks from ?from=, 0 by default,
+// at most ?count= of them, block.MaxHeaders by default
+func (bcs *BlockchainServer) Headers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		from, count := 0, block.MaxHeaders
+		q := r.URL.Query()
+		for _, p := range []struct {
+			name string
+			v    *int
+		}{{"from", &from}, {"count", &count}} {
+			if s := q.Get(p.name); s != "" {
+				n, err := strconv.Atoi(s)
+				if err != nil || n < 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					io.WriteString(w, string(utils.JsonStatus("fail")))
+					return
+				}
+				*p.v = n
+			}
+		}
+		m, _ := json.Marshal(bcs.GetBlockchain().Headers(from, count))
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Stats is handler function that returns the chain height, the mining reward schedule, the supply
+// and the clock drift
+func (bcs *BlockchainServer) Stats(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		height := len(bc.Chain())
+		e := bc.Emission()
+		var projected *float64
+		if p, ok := e.ProjectedSupply(); ok {
+			projected = &p
+		}
+		m, _ := json.Marshal(struct {
+			Height          int                  `json:"height"`
+			Emission        block.Emission       `json:"emission"`
+			Reward          float32              `json:"reward"`
+			NextHalving     int                  `json:"next_halving,omitempty"`
+			Supply          float32              `json:"supply"`
+			ProjectedSupply *float64             `json:"projected_supply,omitempty"`
+			Broadcast       block.BroadcastStats `json:"broadcast"`
+			ClockDrift      block.ClockDrift     `json:"clock_drift"`
+			Miners          []block.MinerStats   `json:"miners"`
+		}{
+			Height:          height,
+			Emission:        e,
+			Reward:          e.Reward(height),
+			NextHalving:     e.NextHalving(height),
+			Supply:          bc.TotalSupply(),
+			ProjectedSupply: projected,
+			Broadcast:       bc.BroadcastStats(),
+			ClockDrift:      bc.ClockDrift(),
+			Miners:          bc.MinerStats(),
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Names is handler function that returns the blockchain address registered for /names/{name}
+func (bcs *BlockchainServer) Names(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		name := strings.TrimPrefix(r.URL.Path, "/names/")
+		bc := bcs.GetBlockchain()
+		w.Header().Add("Content-Type", "application/json")
+		address, ok := bc.LookupName(name)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Name              string `json:"name"`
+			BlockchainAddress string `json:"blockchain_address"`
+		}{
+			Name:              name,
+			BlockchainAddress: address,
+		})
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Anchors is handler function that anchors a document hash (POST /anchors) and
+// returns its inclusion proof once mined (GET /anchors/{hash})
+func (bcs *BlockchainServer) Anchors(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		documentHash := strings.TrimPrefix(r.URL.Path, "/anchors/")
+		bc := bcs.GetBlockchain()
+		w.Header().Add("Content-Type", "application/json")
+		proof, ok := bc.FindAnchor(documentHash)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(proof)
+		io.WriteString(w, string(m))
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var ar struct {
+			Hash *string `json:"hash"`
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if err := decoder.Decode(&ar); err != nil || ar.Hash == nil {
+			bcs.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		bc := bcs.GetBlockchain()
+		result, err := bc.CreateAnchor(r.Context(), *ar.Hash)
+		if err != nil {
+			bcs.writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(broadcastStatus("success", result)))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusBadRequest)
 	}
 }
@@ -186,7 +920,7 @@ func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPut:
 		bc := bcs.GetBlockchain()
-		replaced := bc.ResolveConflicts()
+		replaced := bc.ResolveConflicts(r.Context())
 		w.Header().Add("Content-Type", "application/json")
 		if replaced {
 			io.WriteString(w, string(utils.JsonStatus("success")))
@@ -194,19 +928,449 @@ func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, r *http.Request) {
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 		}
 	default:
-		log.Printf("ERROR: Invalid HTTP Method")
+		bcs.logger.Printf("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Alerts is handler function that returns the recent double-spend alerts, newest first, and receives
+// the alerts of the neighbors
+func (bcs *BlockchainServer) Alerts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(struct {
+			Alerts []*block.DoubleSpendAlert `json:"alerts"`
+		}{
+			Alerts: bc.Alerts(),
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var a block.DoubleSpendAlert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			bcs.logger.Printf("ERROR %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if err := bcs.GetBlockchain().ReceiveAlert(r.Context(), &a); err != nil {
+			bcs.writeError(w, err)
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Orphans is handler function that returns the blocks orphaned by reorgs and the rejected chain tips
+func (bcs *BlockchainServer) Orphans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(bc.Orphans())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusBadRequest)
 	}
 }
 
-// Run is start HTTP Server
-func (bcs *BlockchainServer) Run() {
-	bcs.GetBlockchain().Run()
-	http.HandleFunc("/", bcs.GetChain)
-	http.HandleFunc("/transactions", bcs.Transactions)
-	http.HandleFunc("/mine", bcs.Mine)
-	http.HandleFunc("/mine/start", bcs.StartMine)
-	http.HandleFunc("/amount", bcs.Amount)
-	http.HandleFunc("/consensus", bcs.Consensus)
-	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(bcs.Port())), nil))
+// Peers is handler function that returns the neighbors of the node
+func (bcs *BlockchainServer) Peers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		neighbors := bc.Neighbors()
+		roles := make(map[string]block.Role, len(neighbors))
+		for _, n := range neighbors {
+			if ni, ok := bc.PeerInfo(n); ok {
+				roles[n] = ni.Role
+			}
+		}
+		m, _ := json.Marshal(struct {
+			Peers []string              `json:"peers"`
+			Roles map[string]block.Role `json:"roles"`
+			Keys  map[string]string     `json:"keys,omitempty"`
+		}{
+			Peers: neighbors,
+			Roles: roles,
+			Keys:  bc.PeerKeys(),
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Handshake is handler function that returns the NodeInfo the node advertises to its peers
+func (bcs *BlockchainServer) Handshake(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.GetBlockchain().NodeInfo())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Blocks is handler function that returns the block at /blocks/{height} or /blocks/hash/{hash} with its height
+// and hash, and ?count=N blocks from height ?from=H at /blocks, by default the last DefaultBlocksPage blocks.
+// Pruned nodes redirect requests for blocks older than their prune depth to a peer serving them.
+func (bcs *BlockchainServer) Blocks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		p := strings.TrimPrefix(r.URL.Path, "/blocks")
+		var v block.BlockView
+		ok := false
+		switch {
+		case p == "" || p == "/":
+			bcs.blockPage(w, r)
+			return
+		case strings.HasPrefix(p, "/hash/"):
+			if hash, err := block.ParseTxID(strings.TrimPrefix(p, "/hash/")); err == nil {
+				v, ok = bc.FindBlock(hash)
+			}
+		default:
+			if height, err := strconv.Atoi(strings.TrimPrefix(p, "/")); err == nil {
+				v, ok = bc.BlockAt(height)
+			}
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !bc.Serves(v.Height) {
+			bcs.delegateHistory(w, r, v.Height)
+			return
+		}
+		m, _ := json.Marshal(v)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// blockPage writes the blocks of /blocks?from=H&count=N, oldest first
+func (bcs *BlockchainServer) blockPage(w http.ResponseWriter, r *http.Request) {
+	bc := bcs.GetBlockchain()
+	height := len(bc.Chain()) - 1
+	from, count := -1, DefaultBlocksPage
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"from", &from}, {"count", &count}} {
+		if s := q.Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			*p.v = n
+		}
+	}
+	if count > block.MaxBlocks {
+		count = block.MaxBlocks
+	}
+	if from < 0 {
+		from = height + 1 - count
+		if from < 0 {
+			from = 0
+		}
+	}
+	blocks := bc.Blocks(from, count)
+	if len(blocks) > 0 && !bc.Serves(from) {
+		bcs.delegateHistory(w, r, from)
+		return
+	}
+	m, _ := json.Marshal(struct {
+		Height int               `json:"height"`
+		From   int               `json:"from"`
+		Blocks []block.BlockView `json:"blocks"`
+	}{
+		Height: height,
+		From:   from,
+		Blocks: blocks,
+	})
+	io.WriteString(w, string(m))
+}
+
+// delegateHistory redirects a request for history at height, pruned on this node, to a peer serving it
+func (bcs *BlockchainServer) delegateHistory(w http.ResponseWriter, r *http.Request, height int) {
+	peer, ok := bcs.GetBlockchain().HistoryPeer(height)
+	if !ok {
+		bcs.logger.Printf("ERROR: block %d is pruned and no peer serves it", height)
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+		return
+	}
+	http.Redirect(w, r, "http://"+peer+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+}
+
+// Snapshot is handler function that returns a signed Snapshot of the first ?height=N blocks and the blocks after
+// them, by default of all but the last DefaultSnapshotBlocks blocks
+func (bcs *BlockchainServer) Snapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		height := len(bc.Chain()) - block.DefaultSnapshotBlocks
+		if h := r.URL.Query().Get("height"); h != "" {
+			n, err := strconv.Atoi(h)
+			if err != nil || n < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			height = n
+		}
+		s, chain, err := bc.Snapshot(height, bcs.snapshotKey)
+		if err != nil {
+			bcs.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		checkpoint := block.Checkpoint{Height: s.Height, Hash: s.Hash()}
+		m, _ := json.Marshal(&block.SnapshotResponse{Checkpoint: checkpoint.String(), Snapshot: s, Blocks: chain})
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// Events is handler function that streams a server-sent event for every block added to the chain,
+// for every reorg and for every transaction accepted into the pool
+func (bcs *BlockchainServer) Events(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			bcs.logger.Println("ERROR: Streaming unsupported")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		events, unsubscribe := bcs.GetBlockchain().Subscribe()
+		defer unsubscribe()
+		w.Header().Add("Content-Type", "text/event-stream")
+		w.Header().Add("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		for {
+			select {
+			case e := <-events:
+				var m []byte
+				switch e := e.(type) {
+				case block.BlockEvent:
+					m, _ = json.Marshal(struct {
+						Height    int      `json:"height"`
+						Hash      string   `json:"hash"`
+						Addresses []string `json:"addresses"`
+					}{
+						Height:    e.Height,
+						Hash:      fmt.Sprintf("%x", e.Block.Hash()),
+						Addresses: e.Addresses(),
+					})
+				default:
+					m, _ = json.Marshal(e)
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind(), m)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// TransactionStatus is handler function that returns the status of /transactions/{txid}, the TransactionProof
+// of a confirmed transaction for /transactions/{txid}/proof or its signed TransactionReceipt for /transactions/{txid}/receipt
+func (bcs *BlockchainServer) TransactionStatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		p := strings.TrimPrefix(r.URL.Path, "/transactions/")
+		proof := strings.HasSuffix(p, "/proof")
+		receipt := strings.HasSuffix(p, "/receipt")
+		txid, err := block.ParseTxID(strings.TrimSuffix(strings.TrimSuffix(p, "/proof"), "/receipt"))
+		if err != nil {
+			bcs.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		bc := bcs.GetBlockchain()
+		if receipt {
+			tr, ok, err := bc.TransactionReceipt(txid, bcs.snapshotKey)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			if err != nil {
+				bcs.logger.Printf("ERROR: receipt: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			if !bc.Serves(tr.Height) {
+				bcs.delegateHistory(w, r, tr.Height)
+				return
+			}
+			m, _ := json.Marshal(tr)
+			io.WriteString(w, string(m))
+			return
+		}
+		if proof {
+			tp, ok := bc.TransactionProof(txid)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+			if !bc.Serves(tp.Height) {
+				bcs.delegateHistory(w, r, tp.Height)
+				return
+			}
+			m, _ := json.Marshal(tp)
+			io.WriteString(w, string(m))
+			return
+		}
+		status, ok := bc.TransactionStatus(txid)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if status.Status == block.TransactionConfirmed && !bc.Serves(status.Height) {
+			bcs.delegateHistory(w, r, status.Height)
+			return
+		}
+		m, _ := json.Marshal(status)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// routes returns the handlers of the server by path
+func (bcs *BlockchainServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", bcs.GetChain)
+	mux.HandleFunc("/chain", bcs.GetChain)
+	mux.HandleFunc("/chain/stream", bcs.ChainStream)
+	mux.HandleFunc("/transactions", bcs.Transactions)
+	mux.HandleFunc("/transactions/", bcs.TransactionStatus)
+	mux.HandleFunc("/mine", bcs.admin(bcs.Mine))
+	mux.HandleFunc("/mine/start", bcs.admin(bcs.StartMine))
+	mux.HandleFunc("/generate", bcs.Generate)
+	mux.HandleFunc("/mining/work", bcs.MiningWork)
+	mux.HandleFunc("/mining/submit", bcs.MiningWork)
+	mux.HandleFunc("/mining/template", bcs.MiningTemplate)
+	mux.HandleFunc("/blocks/submit", bcs.SubmitBlock)
+	mux.HandleFunc("/blocks/orphans", bcs.Orphans)
+	mux.HandleFunc("/alerts", bcs.Alerts)
+	mux.HandleFunc("/blocks", bcs.Blocks)
+	mux.HandleFunc("/blocks/", bcs.Blocks)
+	mux.HandleFunc("/headers", bcs.Headers)
+	mux.HandleFunc("/handshake", bcs.Handshake)
+	mux.HandleFunc("/snapshot", bcs.Snapshot)
+	mux.HandleFunc("/amount", bcs.Amount)
+	mux.HandleFunc("/accounts/", bcs.Accounts)
+	mux.HandleFunc("/address/", bcs.Accounts)
+	mux.HandleFunc("/consensus", bcs.Consensus)
+	mux.HandleFunc("/burned", bcs.Burned)
+	mux.HandleFunc("/supply", bcs.Supply)
+	mux.HandleFunc("/difficulty", bcs.Difficulty)
+	mux.HandleFunc("/mempool", bcs.Mempool)
+	mux.HandleFunc("/fees/stats", bcs.FeeStats)
+	mux.HandleFunc("/version", bcs.Version)
+	mux.HandleFunc("/stats", bcs.Stats)
+	mux.HandleFunc("/peers", bcs.Peers)
+	mux.HandleFunc("/sync", bcs.Sync)
+	mux.HandleFunc("/ready", bcs.Ready)
+	mux.HandleFunc("/events", bcs.Events)
+	mux.HandleFunc("/ws", bcs.WebSocket)
+	mux.HandleFunc("/names/", bcs.Names)
+	mux.HandleFunc("/anchors", bcs.Anchors)
+	mux.HandleFunc("/anchors/", bcs.Anchors)
+	mux.HandleFunc("/admin/verify", bcs.admin(bcs.AdminVerify))
+	mux.HandleFunc("/admin/verify/status", bcs.admin(bcs.AdminVerifyStatus))
+	mux.HandleFunc("/admin/miner", bcs.admin(bcs.AdminMiner))
+	mux.HandleFunc("/admin/miner/", bcs.admin(bcs.AdminMinerControl))
+	mux.HandleFunc("/admin/mining/status", bcs.admin(bcs.AdminMiner))
+	mux.HandleFunc("/admin/mining/", bcs.admin(bcs.AdminMinerControl))
+	mux.HandleFunc("/admin/peers", bcs.admin(bcs.AdminPeers))
+	mux.HandleFunc("/admin/peers/", bcs.admin(bcs.AdminPeer))
+	return mux
+}
+
+// Run is start HTTP Server, serving until ctx is done. The mining, sync and backup loops stop with ctx,
+// and the chain store is closed once the requests being served are done.
+func (bcs *BlockchainServer) Run(ctx context.Context) error {
+	// Listen before creating the blockchain, which is identified to its neighbors by the port
+	l, err := utils.Listen(bcs.port)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	bcs.ctx = ctx
+	bcs.port = utils.ListenPort(l)
+	bcs.logger.Printf("Listening on port %d", bcs.port)
+	if bcs.noiseKey != nil {
+		nl := noise.NewListener(l, bcs.noiseKey)
+		if bcs.noiseRequired {
+			nl.RequireHandshake()
+		}
+		if bcs.noisePeers != nil {
+			nl.AllowPeers(bcs.noisePeers)
+		}
+		l = nl
+		bcs.logger.Printf("Noise node key: %s", bcs.noiseKey)
+	}
+	bcs.logger.Printf("Version %s, commit %q, protocol %d, chain %s", Version, buildCommit(), block.ProtocolVersion, bcs.ChainID())
+	if bcs.snapshotKey == nil {
+		bcs.snapshotKey = wallet.NewWallet()
+	}
+	snapshotPublicKey := bcs.snapshotKey.PublicKey()
+	bcs.logger.Printf("snapshot public key: %064x%064x", snapshotPublicKey.X.Bytes(), snapshotPublicKey.Y.Bytes())
+	if bcs.chainFile != "" {
+		if err := bcs.loadChain(bcs.chainFile); err != nil {
+			return err
+		}
+	}
+	if bcs.snapshotSync != nil {
+		if err := bcs.syncSnapshot(ctx); err != nil {
+			return err
+		}
+	}
+	bc := bcs.GetBlockchain()
+	bc.Run(ctx)
+	bcs.StartBackups(ctx)
+	bcs.startReorgWebhook(ctx)
+	err = utils.Serve(ctx, l, bcs.routes())
+	bcs.logger.Println("Shut down")
+	if cerr := bc.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// loadChain replaces the chain of the blockchain with the chain in path, after validating it fully
+func (bcs *BlockchainServer) loadChain(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	chain, err := block.ReadChain(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	bc := bcs.GetBlockchain()
+	if err := block.VerifyChain(chain, bc.Genesis().Hash(), bc.Difficulty(), bc.Emission()); err != nil {
+		return fmt.Errorf("%s: %w: %v", path, block.ErrChainInvalid, err)
+	}
+	if err := bc.ReplaceChain(chain); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	bcs.logger.Printf("Loaded %d blocks from %s", len(chain), path)
+	return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// SetClockDriftAlert sets the clock drift over which the node warns, block.DefaultMaxClockDrift when not
+// positive, and the URL the block.ClockDrift is posted to as JSON when it goes over, none when empty
+func (bcs *BlockchainServer) SetClockDriftAlert(threshold time.Duration, webhook string) {
+	bcs.driftThreshold = threshold
+	bcs.driftWebhook = webhook
+}
+
+// driftAlert posts d to the clock drift webhook
+func (bcs *BlockchainServer) driftAlert(d block.ClockDrift) {
+	if bcs.driftWebhook == "" {
+		return
+	}
+	bcs.postWebhook("clock drift", bcs.driftWebhook, d)
+}
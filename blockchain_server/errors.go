@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// statusOf returns the HTTP status code of a validation error of the block package
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, block.ErrInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.Is(err, block.ErrInsufficientBalance):
+		return http.StatusPaymentRequired
+	case errors.Is(err, block.ErrDuplicateTransaction), errors.Is(err, block.ErrNameTaken):
+		return http.StatusConflict
+	case errors.Is(err, block.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, block.ErrChainInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, block.ErrUnknownPeer):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// writeError logs err and responds with its status code and a fail message carrying the reason
+func (bcs *BlockchainServer) writeError(w http.ResponseWriter, err error) {
+	bcs.logger.Printf("ERROR: %v", err)
+	w.WriteHeader(statusOf(err))
+	m, _ := json.Marshal(struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}{
+		Message: "fail",
+		Error:   err.Error(),
+	})
+	io.WriteString(w, string(m))
+}
+
+// broadcastStatus is utils.JsonStatus with the result of sending the request on to the neighbors
+func broadcastStatus(message string, result block.BroadcastResult) []byte {
+	m, _ := json.Marshal(struct {
+		Message   string                `json:"message"`
+		Broadcast block.BroadcastResult `json:"broadcast"`
+	}{
+		Message:   message,
+		Broadcast: result,
+	})
+	return m
+}
@@ -1,8 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/address"
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/noise"
+	"github.com/hirasawayuki/block_chain/pkcs11"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
 )
 
 func init() {
@@ -10,8 +25,170 @@ func init() {
 }
 
 func main() {
-	port := flag.Uint("port", 5000, "TCP Port Number for Blockchain Server")
+	port := flag.Uint("port", 5000, "TCP Port Number for Blockchain Server (0: pick a free port)")
+	regtest := flag.Bool("regtest", false, "Regtest mode: minimal difficulty and instant mining")
+	retargetName := flag.String("retarget", "fixed", "Difficulty adjustment algorithm: fixed, sma or asert")
+	blockInterval := flag.Duration("block-interval", block.TargetBlockSpacing, "Block interval the sma and asert algorithms of -retarget adjust the difficulty for")
+	configFile := flag.String("config", "", "JSON file of the mining and neighbor settings below, overridden by BLOCKCHAIN_* environment variables and then by the flags given")
+	difficulty := flag.Int("difficulty", block.MiningDifficulty, "Number of leading zeros required of block hashes outside regtest mode")
+	reward := flag.Float64("reward", block.MiningReward, "Mining reward before any halving")
+	miningInterval := flag.Duration("mining-interval", block.MiningTimerSec*time.Second, "Interval of the mining timer")
+	miningWorkers := flag.Int("mining-workers", runtime.NumCPU(), "Number of goroutines searching proof of work nonces in parallel")
+	syncInterval := flag.Duration("sync-interval", block.BlockchainNeiborSyncTimeSec*time.Second, "Interval of the neighbor sync")
+	neighborPorts := flag.String("neighbor-ports", fmt.Sprintf("%d-%d", block.BlockchainPortRangeStart, block.BlockchainPortRangeEnd), "Port range scanned for neighbors, start-end")
+	minerAddress := flag.String("miner-address", "", "Blockchain address receiving mining rewards (default: a new wallet every start)")
+	mineEmpty := flag.Bool("mine-empty", false, "Mine blocks on the timer even when there are no transactions")
+	halvingInterval := flag.Int("halving-interval", 0, "Halve the mining reward every N blocks (0: never)")
+	roleName := flag.String("role", "archive", "Node role: archive serves every block, pruned only the last -prune-depth blocks")
+	pruneDepth := flag.Int("prune-depth", block.DefaultPruneDepth, "Number of recent blocks a pruned node serves")
+	snapshotKey := flag.String("snapshot-key", "", "Private key signing the snapshots served (default: a new key every start)")
+	snapshotPeer := flag.String("snapshot-peer", "", "Start from a snapshot of this host:port instead of syncing the whole chain")
+	checkpointStr := flag.String("checkpoint", "", "height:hash of the snapshot expected from -snapshot-peer")
+	trustedKey := flag.String("snapshot-trusted-key", "", "Public key that must have signed the snapshot of -snapshot-peer")
+	dataDir := flag.String("datadir", "", "Directory the chain is kept in across restarts (default: in memory only)")
+	compressStore := flag.Bool("datadir-compress", false, "Compress the blocks kept in -datadir with gzip")
+	loadChain := flag.String("load-chain", "", "Start from a chain exported by GET /, GET /chain/stream or a backup, validated fully")
+	adminToken := flag.String("admin-token", "", "Bearer token of the /admin endpoints (default: disabled)")
+	backupTo := flag.String("backup-to", "", "Back up the chain to this directory or s3://bucket/prefix (default: no backups)")
+	backupInterval := flag.Duration("backup-interval", DefaultBackupInterval, "Interval of chain backups")
+	s3Endpoint := flag.String("backup-s3-endpoint", "https://s3.amazonaws.com", "Endpoint of the S3 compatible object store of -backup-to")
+	s3Region := flag.String("backup-s3-region", "us-east-1", "Region of the S3 compatible object store of -backup-to")
+	maxClockDrift := flag.Duration("max-clock-drift", block.DefaultMaxClockDrift, "Clock drift against the peers and the chain tip over which the node warns")
+	driftWebhook := flag.String("drift-webhook", "", "URL the clock drift is posted to as JSON when it exceeds -max-clock-drift (default: only log)")
+	reorgWebhook := flag.String("reorg-webhook", "", "URL every reorg is posted to as JSON with its depth and affected transactions (default: none)")
+	chainID := flag.String("chain-id", "", "ID of the network transactions are signed for (default: mainnet, or regtest with -regtest)")
+	extraData := flag.String("extra-data", "", "Tag of the blocks mined by the node, e.g. the name of a pool")
+	noiseEnabled := flag.Bool("noise", false, "Encrypt and authenticate the connections to peers with a Noise handshake")
+	nodeKey := flag.String("node-key", "node.key", "File of the node key of -noise, created when it does not exist")
+	noiseRequired := flag.Bool("noise-required", false, "Refuse connections without the Noise handshake of -noise, including wallet servers")
+	noisePeers := flag.String("noise-peers", "", "File of the node keys of the peers allowed to connect over -noise, one per line (default: any)")
+	pkcs11Module := flag.String("pkcs11-module", "", "PKCS#11 library of the HSM holding the snapshot key, instead of -snapshot-key (needs a build with -tags pkcs11)")
+	pkcs11Slot := flag.Uint("pkcs11-slot", 0, "Slot ID of the token of -pkcs11-module")
+	pkcs11PIN := flag.String("pkcs11-pin", pkcs11.DefaultPIN, "Where the PIN of the token is read from, env:NAME or file:PATH")
+	pkcs11Label := flag.String("pkcs11-label", "node", "Label of the key pair of the token signing snapshots and double-spend alerts")
 	flag.Parse()
-	app := NewBlockchainServer(uint16(*port))
-	app.Run()
+	if *minerAddress != "" {
+		if err := address.Validate(*minerAddress, address.MainNetVersion); err != nil {
+			log.Fatalf("invalid miner address %q: %v", *minerAddress, err)
+		}
+	}
+	cfg, err := block.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "difficulty":
+			cfg.Difficulty = *difficulty
+		case "reward":
+			cfg.Reward = float32(*reward)
+		case "mining-interval":
+			cfg.MiningInterval = block.Duration(*miningInterval)
+		case "mining-workers":
+			cfg.MiningWorkers = *miningWorkers
+		case "sync-interval":
+			cfg.SyncInterval = block.Duration(*syncInterval)
+		case "neighbor-ports":
+			if _, err := fmt.Sscanf(*neighborPorts, "%d-%d", &cfg.NeighborPortStart, &cfg.NeighborPortEnd); err != nil {
+				log.Fatalf("invalid neighbor ports %q", *neighborPorts)
+			}
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	retarget, err := block.NewRetarget(*retargetName, *blockInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	role, err := block.ParseRole(*roleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *pruneDepth < 1 {
+		log.Fatalf("invalid prune depth %d", *pruneDepth)
+	}
+	app := NewBlockchainServer(uint16(*port), *regtest, retarget, *minerAddress, *mineEmpty, block.Emission{HalvingInterval: *halvingInterval})
+	app.SetConfig(cfg)
+	app.SetRole(role, *pruneDepth)
+	app.SetChainID(*chainID)
+	if len(*extraData) > block.MaxExtraDataSize {
+		log.Fatalf("-extra-data is longer than %d bytes", block.MaxExtraDataSize)
+	}
+	app.SetExtraData(*extraData)
+	if *noiseEnabled {
+		key, err := noise.LoadKey(*nodeKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.SetNoise(key)
+		var peers [][32]byte
+		if *noisePeers != "" {
+			if peers, err = noise.LoadPublicKeys(*noisePeers); err != nil {
+				log.Fatal(err)
+			}
+		}
+		app.SetNoisePeers(*noiseRequired, peers)
+	} else if *noiseRequired || *noisePeers != "" {
+		log.Fatal("-noise-required and -noise-peers need -noise")
+	}
+	if *snapshotKey != "" {
+		w, err := wallet.NewWalletFromPrivateKeyStr(*snapshotKey)
+		if err != nil {
+			log.Fatalf("invalid snapshot key: %v", err)
+		}
+		app.SetSnapshotKey(w)
+	}
+	if *pkcs11Module != "" {
+		if *snapshotKey != "" {
+			log.Fatal("-snapshot-key and -pkcs11-module can not be used together")
+		}
+		token, err := pkcs11.Open(pkcs11.Config{Module: *pkcs11Module, Slot: *pkcs11Slot, PIN: *pkcs11PIN})
+		if err != nil {
+			log.Fatal(err)
+		}
+		key, err := token.Key(*pkcs11Label)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.SetSnapshotKey(key)
+	}
+	if *loadChain != "" && *snapshotPeer != "" {
+		log.Fatal("-load-chain and -snapshot-peer can not be used together")
+	}
+	app.SetDataDir(*dataDir, *compressStore)
+	app.SetChainFile(*loadChain)
+	app.SetAdminToken(*adminToken)
+	app.SetClockDriftAlert(*maxClockDrift, *driftWebhook)
+	app.SetReorgWebhook(*reorgWebhook)
+	if *backupTo != "" {
+		if *backupInterval <= 0 {
+			log.Fatalf("invalid backup interval %v", *backupInterval)
+		}
+		uploader, err := ParseUploader(*backupTo, *s3Endpoint, *s3Region)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.SetBackups(uploader, *backupInterval)
+	}
+	if *snapshotPeer != "" {
+		checkpoint, err := block.ParseCheckpoint(*checkpointStr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var trusted *ecdsa.PublicKey
+		if *trustedKey != "" {
+			if len(*trustedKey) != 128 {
+				log.Fatalf("invalid snapshot trusted key %q", *trustedKey)
+			}
+			trusted = utils.PublicKeyFromString(*trustedKey)
+		}
+		app.SetSnapshotSync(*snapshotPeer, checkpoint, trusted)
+	}
+	// Stop on Ctrl-C or SIGTERM, finishing the requests being served
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// Version and Commit identify the build, injected at build time with
+//
+//	go build -ldflags "-X main.Version=1.2.0 -X main.Commit=$(git rev-parse HEAD)"
+//
+// Without -X main.Commit the commit recorded by the go command, if any, is reported.
+var (
+	Version = "0.0.0-dev"
+	Commit  = ""
+)
+
+// BuildInfo is the build and the enabled features of a node
+type BuildInfo struct {
+	Version         string            `json:"version"`
+	Commit          string            `json:"commit,omitempty"`
+	GoVersion       string            `json:"go_version"`
+	ProtocolVersion int               `json:"protocol_version"`
+	ChainFormat     int               `json:"chain_format_version"`
+	ChainID         string            `json:"chain_id"`
+	Features        map[string]string `json:"features"`
+}
+
+// buildCommit returns Commit, or the VCS revision the go command stamped into the binary
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// BuildInfo returns the build of the node and the features it runs with
+func (bcs *BlockchainServer) BuildInfo() BuildInfo {
+	halving := "off"
+	if bcs.emission.HalvingInterval > 0 {
+		halving = "on"
+	}
+	regtest := "off"
+	if bcs.regtest {
+		regtest = "on"
+	}
+	return BuildInfo{
+		Version:         Version,
+		Commit:          buildCommit(),
+		GoVersion:       runtime.Version(),
+		ProtocolVersion: block.ProtocolVersion,
+		ChainFormat:     block.ChainFormatVersion,
+		ChainID:         bcs.ChainID(),
+		Features: map[string]string{
+			"ledger":    "account",
+			"consensus": "pow",
+			"retarget":  block.RetargetName(bcs.retarget),
+			"halving":   halving,
+			"role":      string(bcs.role),
+			"regtest":   regtest,
+		},
+	}
+}
+
+// Version is handler function that returns the BuildInfo of the node
+func (bcs *BlockchainServer) Version(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(bcs.BuildInfo())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
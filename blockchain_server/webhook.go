@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// WebhookTimeout is the timeout of posting to a webhook
+const WebhookTimeout = 5 * time.Second
+
+// postWebhook posts v as JSON to url, logging failures as those of the webhook name
+func (bcs *BlockchainServer) postWebhook(name string, url string, v interface{}) {
+	m, _ := json.Marshal(v)
+	client := &http.Client{Timeout: WebhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(m))
+	if err != nil {
+		bcs.logger.Printf("ERROR: %s webhook: %v", name, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		bcs.logger.Printf("ERROR: %s webhook: %s", name, resp.Status)
+	}
+}
+
+// SetReorgWebhook sets the URL every block.ReorgEvent is posted to as JSON, none when empty
+func (bcs *BlockchainServer) SetReorgWebhook(url string) {
+	bcs.reorgWebhook = url
+}
+
+// startReorgWebhook posts the reorgs of the chain to the reorg webhook in the background until ctx is done
+func (bcs *BlockchainServer) startReorgWebhook(ctx context.Context) {
+	if bcs.reorgWebhook == "" {
+		return
+	}
+	events, unsubscribe := bcs.GetBlockchain().Subscribe()
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+	go func() {
+		for e := range events {
+			if r, ok := e.(block.ReorgEvent); ok {
+				bcs.postWebhook("reorg", bcs.reorgWebhook, r)
+			}
+		}
+	}()
+}
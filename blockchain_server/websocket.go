@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// WebSocketPingInterval is how often /ws pings its clients, keeping idle connections open through proxies
+const WebSocketPingInterval = 30 * time.Second
+
+// WebSocket is handler function that pushes the events of the chain to a WebSocket client, a JSON text
+// message each: "block" for every block added to the chain, "transaction" for every transaction accepted
+// into the pool and "reorg" when the chain is replaced. ?events=block,reorg limits the types sent.
+func (bcs *BlockchainServer) WebSocket(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		kinds, ok := parseEventKinds(r.URL.Query().Get("events"))
+		if !ok {
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		// Subscribe first so that no event is missed once connected
+		events, unsubscribe := bcs.GetBlockchain().Subscribe()
+		defer unsubscribe()
+		ws, err := utils.UpgradeWebSocket(w, r)
+		if err != nil {
+			bcs.logger.Printf("ERROR: %v", err)
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		defer ws.Close()
+		ping := time.NewTicker(WebSocketPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case e := <-events:
+				if kinds != nil && !kinds[e.Kind()] {
+					continue
+				}
+				if err := ws.WriteText(webSocketMessage(e)); err != nil {
+					return
+				}
+			case <-ping.C:
+				if err := ws.Ping(); err != nil {
+					return
+				}
+			case <-ws.Done():
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		bcs.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// parseEventKinds returns the set of comma separated event kinds, nil for all of them when s is empty
+func parseEventKinds(s string) (map[string]bool, bool) {
+	if s == "" {
+		return nil, true
+	}
+	kinds := make(map[string]bool)
+	for _, k := range strings.Split(s, ",") {
+		switch k {
+		case block.BlockEvent{}.Kind(), block.ReorgEvent{}.Kind(), block.TransactionEvent{}.Kind():
+			kinds[k] = true
+		default:
+			return nil, false
+		}
+	}
+	return kinds, true
+}
+
+// webSocketMessage returns the JSON message of e, its fields after its kind as "type"
+func webSocketMessage(e block.Event) []byte {
+	var m []byte
+	switch e := e.(type) {
+	case block.BlockEvent:
+		m, _ = json.Marshal(struct {
+			Type      string       `json:"type"`
+			Height    int          `json:"height"`
+			Hash      string       `json:"hash"`
+			Addresses []string     `json:"addresses"`
+			Block     *block.Block `json:"block"`
+		}{
+			Type:      e.Kind(),
+			Height:    e.Height,
+			Hash:      fmt.Sprintf("%x", e.Block.Hash()),
+			Addresses: e.Addresses(),
+			Block:     e.Block,
+		})
+	case block.ReorgEvent:
+		m, _ = json.Marshal(struct {
+			Type string `json:"type"`
+			block.ReorgEvent
+		}{e.Kind(), e})
+	case block.TransactionEvent:
+		m, _ = json.Marshal(struct {
+			Type string `json:"type"`
+			block.TransactionEvent
+		}{e.Kind(), e})
+	}
+	return m
+}
@@ -0,0 +1,137 @@
+// Package blocktest builds Blockchain fixtures with a given height, balances and forks
+// so tests of validation, reorgs and indexes do not have to drive the mining timers.
+package blocktest
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// Genesis is the time of the genesis block of fixtures
+var Genesis = time.Unix(1600000000, 0)
+
+// BlockInterval is the time between fixture blocks
+const BlockInterval = time.Minute
+
+// DefaultMiner is the address receiving the mining rewards of fixtures
+const DefaultMiner = "blocktest-miner"
+
+type config struct {
+	height   int
+	miner    string
+	balances map[string]float32
+	order    []string
+}
+
+// Option configures NewChain
+type Option func(*config)
+
+// WithHeight sets the number of blocks after the genesis block
+func WithHeight(height int) Option {
+	return func(c *config) {
+		c.height = height
+	}
+}
+
+// WithMiner sets the address receiving the mining rewards
+func WithMiner(address string) Option {
+	return func(c *config) {
+		c.miner = address
+	}
+}
+
+// WithBalance credits amount to address in the genesis block
+func WithBalance(address string, amount float32) Option {
+	return func(c *config) {
+		if _, ok := c.balances[address]; !ok {
+			c.order = append(c.order, address)
+		}
+		c.balances[address] += amount
+	}
+}
+
+// NopTransport is a block.Transport without neighbors
+type NopTransport struct{}
+
+func (NopTransport) FindNeighbors(ctx context.Context, port uint16) []string { return nil }
+func (NopTransport) SendTransaction(ctx context.Context, neighbor string, tr *block.TransactionRequest) error {
+	return nil
+}
+func (NopTransport) ClearTransactions(ctx context.Context, neighbor string) error { return nil }
+func (NopTransport) RequestConsensus(ctx context.Context, neighbor string) error  { return nil }
+func (NopTransport) GetChain(ctx context.Context, neighbor string, maxSize int64) ([]*block.Block, bool) {
+	return nil, false
+}
+func (NopTransport) Handshake(ctx context.Context, neighbor string) (block.NodeInfo, bool) {
+	return block.NodeInfo{}, false
+}
+func (NopTransport) SendAlert(ctx context.Context, neighbor string, a *block.DoubleSpendAlert) error {
+	return nil
+}
+
+// NewChain returns a Blockchain with a block.ManualClock and the configured blocks
+func NewChain(opts ...Option) (*block.Blockchain, error) {
+	c := &config{miner: DefaultMiner, balances: make(map[string]float32)}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	clock := block.NewManualClock(Genesis)
+	balances := make([]block.Option, 0, len(c.order))
+	for _, address := range c.order {
+		balances = append(balances, block.WithGenesisBalance(address, c.balances[address]))
+	}
+	bc := newBlockchain(c.miner, clock, balances...)
+	if err := Extend(bc, c.height, "main"); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+// newBlockchain returns a Blockchain mining at the easiest target, so fixtures find a nonce in a few tries
+func newBlockchain(miner string, clock *block.ManualClock, opts ...block.Option) *block.Blockchain {
+	opts = append([]block.Option{block.WithClock(clock), block.WithGenesisTime(Genesis), block.WithDifficulty(block.RegtestDifficulty), block.WithMiningWorkers(1)}, opts...)
+	return block.NewBlockChainWithTransport(miner, 0, NopTransport{}, opts...)
+}
+
+// Extend mines n more blocks on bc. Blocks are tagged with branch so that
+// different branches extending the same parent produce different blocks.
+func Extend(bc *block.Blockchain, n int, branch string) error {
+	for i := 0; i < n; i++ {
+		height := len(bc.Chain())
+		tag := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", branch, height)))
+		if err := bc.AddAnchor(fmt.Sprintf("%x", tag)); err != nil {
+			return fmt.Errorf("blocktest: block %d: %w", height, err)
+		}
+		if clock, ok := bc.Clock().(*block.ManualClock); ok {
+			clock.Advance(BlockInterval)
+		}
+		if !bc.Mining(context.Background()) {
+			return fmt.Errorf("blocktest: block %d not mined", height)
+		}
+	}
+	return nil
+}
+
+// Fork returns a new Blockchain sharing the blocks of bc up to height and extended by n blocks of its own
+func Fork(bc *block.Blockchain, height int, n int, branch string) (*block.Blockchain, error) {
+	chain := bc.Chain()
+	if height >= len(chain) {
+		height = len(chain) - 1
+	}
+	clock := block.NewManualClock(Genesis.Add(BlockInterval * time.Duration(height)))
+	fork := newBlockchain(DefaultMiner, clock, block.WithGenesis(chain[0]))
+	prefix := make([]*block.Block, height+1)
+	copy(prefix, chain[:height+1])
+	if err := fork.ReplaceChain(prefix); err != nil {
+		return nil, err
+	}
+	if err := Extend(fork, n, branch); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
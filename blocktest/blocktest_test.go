@@ -0,0 +1,112 @@
+package blocktest
+
+import (
+	"testing"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+func TestNewChainIsValid(t *testing.T) {
+	bc, err := NewChain(WithHeight(5), WithBalance("alice", 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := bc.Chain()
+	if len(chain) != 6 {
+		t.Fatalf("chain has %d blocks, want 6", len(chain))
+	}
+	if !bc.ValidChain(chain) {
+		t.Fatal("NewChain built an invalid chain")
+	}
+	if err := block.VerifyChain(chain, bc.Genesis().Hash(), bc.Difficulty(), bc.Emission()); err != nil {
+		t.Fatal(err)
+	}
+	if got := bc.CaluculateTotalAmount("alice"); got != 10 {
+		t.Errorf("alice has %g, want 10", got)
+	}
+	// Dropping a block breaks the hash links
+	broken := append(append([]*block.Block{}, chain[:2]...), chain[3:]...)
+	if bc.ValidChain(broken) {
+		t.Error("chain without block 2 is valid")
+	}
+}
+
+func TestForkIsValid(t *testing.T) {
+	bc, err := NewChain(WithHeight(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork, err := Fork(bc, 2, 3, "fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, forked := bc.Chain(), fork.Chain()
+	if len(forked) != 6 {
+		t.Fatalf("fork has %d blocks, want 6", len(forked))
+	}
+	for i := 0; i <= 2; i++ {
+		if forked[i].Hash() != chain[i].Hash() {
+			t.Fatalf("fork block %d differs from the chain it forks", i)
+		}
+	}
+	if forked[3].Hash() == chain[3].Hash() {
+		t.Fatal("fork block 3 is the block of the chain it forks")
+	}
+	if !fork.ValidChain(forked) || !bc.ValidChain(forked) {
+		t.Fatal("Fork built an invalid chain")
+	}
+	if err := bc.ReplaceChain(forked); err != nil {
+		t.Fatal(err)
+	}
+	if bc.LastBlock().Hash() != fork.LastBlock().Hash() {
+		t.Error("chain did not switch to the fork")
+	}
+}
+
+func TestChainWithAnotherGenesisIsInvalid(t *testing.T) {
+	bc, err := NewChain(WithHeight(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A chain built the same way shares the genesis block
+	same, err := NewChain(WithHeight(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bc.ValidChain(same.Chain()) {
+		t.Fatal("chain with the same genesis block is invalid")
+	}
+	// A genesis block crediting another balance starts another chain, however long
+	other, err := NewChain(WithHeight(4), WithBalance("mallory", 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.ValidChain(other.Chain()) {
+		t.Fatal("chain with another genesis block is valid")
+	}
+	if err := bc.ReplaceChain(other.Chain()); err == nil {
+		t.Fatal("replaced the chain with a chain of another genesis block")
+	}
+	if got := bc.CaluculateTotalAmount("mallory"); got != 0 {
+		t.Errorf("mallory has %g, want 0", got)
+	}
+}
+
+func TestVerifyChainReportsAnotherGenesis(t *testing.T) {
+	bc, err := NewChain(WithHeight(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewChain(WithHeight(2), WithBalance("mallory", 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = block.VerifyChain(other.Chain(), bc.Genesis().Hash(), bc.Difficulty(), bc.Emission())
+	ce, ok := err.(*block.ChainError)
+	if !ok {
+		t.Fatalf("VerifyChain returned %v, want a *block.ChainError", err)
+	}
+	if ce.Height != 0 || ce.Transaction != -1 {
+		t.Errorf("violation at block %d transaction %d, want the genesis block", ce.Height, ce.Transaction)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+func bench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	duration := fs.Duration("duration", 3*time.Second, "How long to hash")
+	difficulty := fs.Int("difficulty", block.MiningDifficulty, "Difficulty to estimate the block time for")
+	transactions := fs.Int("transactions", 10, "Number of transactions in the benchmark block")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *duration <= 0 || *difficulty < 0 {
+		fmt.Fprintln(os.Stderr, "usage: blockchain bench [-duration D] [-difficulty N] [-transactions N]")
+		return 2
+	}
+
+	bc := block.NewBlockChainWithTransport("bench", 0, nil)
+	pool := make([]*block.Transaction, 0, *transactions)
+	for i := 0; i < *transactions; i++ {
+		pool = append(pool, block.NewTransaction(fmt.Sprintf("sender%d", i), fmt.Sprintf("recipient%d", i), float32(i+1), 0))
+	}
+	previousHash := bc.LastBlock().Hash()
+
+	// A difficulty no nonce can meet, so every attempt does the full hash and comparison
+	const impossible = 64
+	attempts := 0
+	start := time.Now()
+	for time.Since(start) < *duration {
+		for i := 0; i < 1000; i++ {
+			bc.ValidProof(attempts, previousHash, pool, impossible)
+			attempts++
+		}
+	}
+	elapsed := time.Since(start)
+	rate := float64(attempts) / elapsed.Seconds()
+	// Every leading hex zero divides the chance of a hash by 16
+	expected := math.Pow(16, float64(*difficulty))
+
+	fmt.Printf("attempts:      %d in %v\n", attempts, elapsed.Round(time.Millisecond))
+	fmt.Printf("hashrate:      %.0f attempts/s\n", rate)
+	fmt.Printf("difficulty:    %d (%.0f expected attempts per block)\n", *difficulty, expected)
+	fmt.Printf("block time:    %v expected\n", time.Duration(expected/rate*float64(time.Second)).Round(time.Millisecond))
+	return 0
+}
@@ -0,0 +1,38 @@
+// Command blockchain is a toolbox for blockchain node operators.
+//
+//	blockchain verify [-difficulty N] [-genesis HASH] <chainfile|url>
+//	blockchain bench [-duration D] [-difficulty N] [-transactions N]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: blockchain <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  verify   replay and validate a chain file or a node's /chain")
+	fmt.Fprintln(os.Stderr, "  bench    measure the proof of work hashrate and expected block time")
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "verify":
+		os.Exit(verify(args))
+	case "bench":
+		os.Exit(bench(args))
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// readChain reads a chain from a file or, for http(s) sources, from a node
+func readChain(source string) ([]*block.Block, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", source, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	return block.ReadChain(r)
+}
+
+func verify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	difficulty := fs.Int("difficulty", block.MiningDifficulty, "Minimum proof of work difficulty of the blocks in the chain")
+	reward := fs.Float64("reward", block.MiningReward, "Mining reward before any halving of the chain")
+	halvingInterval := fs.Int("halving-interval", 0, "Blocks between halvings of the mining reward of the chain (0: never)")
+	genesisHash := fs.String("genesis", fmt.Sprintf("%x", block.DefaultGenesis().Hash()), "Hash of the genesis block the chain must start from")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: blockchain verify [-difficulty N] [-reward R] [-halving-interval N] [-genesis HASH] <chainfile|url>")
+		return 2
+	}
+	genesis, err := block.ParseTxID(*genesisHash)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -genesis: %v\n", err)
+		return 2
+	}
+
+	chain, err := readChain(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	transactions := 0
+	for _, b := range chain {
+		transactions += len(b.Transactions())
+	}
+	fmt.Printf("blocks:        %d\n", len(chain))
+	fmt.Printf("transactions:  %d\n", transactions)
+	fmt.Println("checks:        structure, genesis block, hash links, merkle roots, proof of work, coinbases, lock times, balances")
+	fmt.Println("skipped:       signatures")
+
+	emission := block.Emission{InitialReward: float32(*reward), HalvingInterval: *halvingInterval}
+	if err := block.VerifyChain(chain, genesis, *difficulty, emission); err != nil {
+		fmt.Printf("result:        INVALID\n")
+		fmt.Printf("violation:     %v\n", err)
+		if ce, ok := err.(*block.ChainError); ok && ce.Height < len(chain) {
+			fmt.Println("#############################")
+			chain[ce.Height].Print()
+		}
+		return 1
+	}
+	fmt.Printf("result:        OK\n")
+	return 0
+}
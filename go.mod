@@ -1,8 +1,9 @@
 module github.com/hirasawayuki/block_chain
 
-go 1.15
+go 1.18
 
 require (
 	github.com/btcsuite/btcutil v1.0.2
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 )
@@ -0,0 +1,289 @@
+package noise
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Magic starts the connections of initiators, so a Listener tells them from plain HTTP clients.
+// HTTP requests never start with a zero byte.
+const Magic = "\x00noise\x00"
+
+// HandshakeTimeout is how long the handshake of a connection may take
+const HandshakeTimeout = 10 * time.Second
+
+var (
+	// ErrHandshakeRequired is returned for a plain connection to a Listener requiring the handshake
+	ErrHandshakeRequired = errors.New("noise handshake required")
+	// ErrUnknownPeer is returned when the static key of an initiator is not one a Listener allows
+	ErrUnknownPeer = errors.New("unknown noise peer key")
+)
+
+// Conn is a connection encrypted with the keys of a completed handshake
+type Conn struct {
+	net.Conn
+	send   *cipherState
+	recv   *cipherState
+	remote [32]byte
+	unread []byte
+	rmux   sync.Mutex
+	wmux   sync.Mutex
+}
+
+// RemoteKey returns the hex encoded static public key of the other side
+func (c *Conn) RemoteKey() string {
+	return hex.EncodeToString(c.remote[:])
+}
+
+// Read reads the plaintext of the next messages
+func (c *Conn) Read(p []byte) (int, error) {
+	c.rmux.Lock()
+	defer c.rmux.Unlock()
+	if len(c.unread) == 0 {
+		m, err := readMessage(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		if c.unread, err = c.recv.decrypt(nil, m); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.unread)
+	c.unread = c.unread[n:]
+	return n, nil
+}
+
+// Write sends p in as many messages as needed
+func (c *Conn) Write(p []byte) (int, error) {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > MaxMessage-tagSize {
+			n = MaxMessage - tagSize
+		}
+		if err := writeMessage(c.Conn, c.send.encrypt(nil, p[:n])); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// readMessage reads a message framed by its 2 byte big endian length
+func readMessage(r io.Reader) ([]byte, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	m := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func writeMessage(w io.Writer, m []byte) error {
+	framed := make([]byte, 2+len(m))
+	binary.BigEndian.PutUint16(framed, uint16(len(m)))
+	copy(framed[2:], m)
+	_, err := w.Write(framed)
+	return err
+}
+
+// Client runs the handshake as initiator over conn with the static key s
+func Client(conn net.Conn, s *Key) (*Conn, error) {
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	hs, err := newHandshakeState(s)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(conn, Magic); err != nil {
+		return nil, err
+	}
+	if err := writeMessage(conn, hs.writeMessage1()); err != nil {
+		return nil, err
+	}
+	m, err := readMessage(conn)
+	if err != nil {
+		return nil, ErrHandshake
+	}
+	if err := hs.readMessage2(m); err != nil {
+		return nil, err
+	}
+	if m, err = hs.writeMessage3(); err != nil {
+		return nil, err
+	}
+	if err := writeMessage(conn, m); err != nil {
+		return nil, err
+	}
+	send, recv := hs.split()
+	return newConn(conn, send, recv, hs.rs), nil
+}
+
+// Server runs the handshake as responder over conn with the static key s. The Magic must have been read.
+func Server(conn net.Conn, s *Key) (*Conn, error) {
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	hs, err := newHandshakeState(s)
+	if err != nil {
+		return nil, err
+	}
+	m, err := readMessage(conn)
+	if err != nil {
+		return nil, ErrHandshake
+	}
+	if err := hs.readMessage1(m); err != nil {
+		return nil, err
+	}
+	if m, err = hs.writeMessage2(); err != nil {
+		return nil, err
+	}
+	if err := writeMessage(conn, m); err != nil {
+		return nil, err
+	}
+	if m, err = readMessage(conn); err != nil {
+		return nil, ErrHandshake
+	}
+	if err := hs.readMessage3(m); err != nil {
+		return nil, err
+	}
+	recv, send := hs.split()
+	return newConn(conn, send, recv, hs.rs), nil
+}
+
+func newConn(conn net.Conn, send *cipherState, recv *cipherState, rs []byte) *Conn {
+	c := &Conn{Conn: conn, send: send, recv: recv}
+	copy(c.remote[:], rs)
+	return c
+}
+
+// Listener accepts both Noise initiators, answered with the handshake, and plain connections,
+// e.g. of wallet servers and operators, which are passed through unencrypted unless the handshake is required.
+// Initiators may be restricted to a set of static keys.
+type Listener struct {
+	net.Listener
+	key      *Key
+	required bool
+	// peers are the static keys of the initiators allowed, any when nil
+	peers map[[32]byte]bool
+}
+
+// NewListener returns a Listener answering the handshakes of initiators with the static key s
+func NewListener(l net.Listener, s *Key) *Listener {
+	return &Listener{Listener: l, key: s}
+}
+
+// RequireHandshake makes the Listener close the connections that do not start with the handshake.
+// It must be called before Accept.
+func (l *Listener) RequireHandshake() {
+	l.required = true
+}
+
+// AllowPeers makes the Listener close the connections of initiators whose static key is not one of keys.
+// It must be called before Accept.
+func (l *Listener) AllowPeers(keys [][32]byte) {
+	l.peers = make(map[[32]byte]bool, len(keys))
+	for _, k := range keys {
+		l.peers[k] = true
+	}
+}
+
+// Accept returns the next connection. It is told apart, and the handshake run, on its first read,
+// so that a slow client does not hold up the others.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &sniffConn{Conn: conn, listener: l}, nil
+}
+
+// sniffConn is a connection accepted by a Listener, an encrypted Conn or a plain connection once
+// its first bytes are read
+type sniffConn struct {
+	net.Conn
+	listener *Listener
+	once     sync.Once
+	inner    net.Conn
+	err      error
+}
+
+func (c *sniffConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(HandshakeTimeout))
+		var first [1]byte
+		if _, err := io.ReadFull(c.Conn, first[:]); err != nil {
+			c.err = err
+			return
+		}
+		c.Conn.SetReadDeadline(time.Time{})
+		if first[0] != Magic[0] {
+			if c.listener.required {
+				c.fail(ErrHandshakeRequired)
+				return
+			}
+			c.inner = &prefixConn{Conn: c.Conn, prefix: first[:]}
+			return
+		}
+		rest := make([]byte, len(Magic)-1)
+		if _, err := io.ReadFull(c.Conn, rest); err != nil || !bytes.Equal(rest, []byte(Magic[1:])) {
+			c.fail(ErrHandshake)
+			return
+		}
+		nc, err := Server(c.Conn, c.listener.key)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if c.listener.peers != nil && !c.listener.peers[nc.remote] {
+			c.fail(ErrUnknownPeer)
+			return
+		}
+		c.inner = nc
+	})
+}
+
+// fail closes the connection, answering its reads and writes with err
+func (c *sniffConn) fail(err error) {
+	c.err = err
+	c.Conn.Close()
+}
+
+func (c *sniffConn) Read(p []byte) (int, error) {
+	if c.init(); c.err != nil {
+		return 0, c.err
+	}
+	return c.inner.Read(p)
+}
+
+func (c *sniffConn) Write(p []byte) (int, error) {
+	if c.init(); c.err != nil {
+		return 0, c.err
+	}
+	return c.inner.Write(p)
+}
+
+// prefixConn is a connection whose first bytes were already read
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(p, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
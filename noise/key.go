@@ -0,0 +1,100 @@
+package noise
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// Key is a Curve25519 key pair, the static key identifying a node or an ephemeral key of a handshake
+type Key struct {
+	Private [32]byte
+	Public  [32]byte
+}
+
+// GenerateKey returns a new random Key
+func GenerateKey() (*Key, error) {
+	private, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	return keyFromPrivate(private)
+}
+
+func keyFromPrivate(private [32]byte) (*Key, error) {
+	public, err := curve25519.X25519(private[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	k := &Key{Private: private}
+	copy(k.Public[:], public)
+	return k, nil
+}
+
+// String returns the hex encoded public key
+func (k *Key) String() string {
+	return hex.EncodeToString(k.Public[:])
+}
+
+// ParsePublicKey decodes a hex encoded public key, as returned by String
+func ParsePublicKey(s string) ([32]byte, error) {
+	var k [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != keySize {
+		return k, fmt.Errorf("invalid node public key %q", s)
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// LoadPublicKeys returns the hex encoded public keys in the file at path, one per line.
+// Empty lines and lines starting with # are skipped.
+func LoadPublicKeys(path string) ([][32]byte, error) {
+	m, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([][32]byte, 0)
+	for _, line := range strings.Split(string(m), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, err := ParsePublicKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// LoadKey returns the Key whose hex encoded private key is stored in the file at path,
+// generating and storing a new one when the file does not exist
+func LoadKey(path string) (*Key, error) {
+	m, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		k, err := GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, []byte(hex.EncodeToString(k.Private[:])+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	private, err := hex.DecodeString(strings.TrimSpace(string(m)))
+	if err != nil || len(private) != keySize {
+		return nil, fmt.Errorf("invalid node key %s", path)
+	}
+	var p [32]byte
+	copy(p[:], private)
+	return keyFromPrivate(p)
+}
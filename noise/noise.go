@@ -0,0 +1,257 @@
+// Package noise encrypts and mutually authenticates connections between nodes with the
+// Noise_XX_25519_AESGCM_SHA256 handshake of the Noise Protocol Framework. Each node has a
+// persistent static key; after the handshake both sides know the key of the other, without a CA.
+package noise
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	// ProtocolName is the Noise protocol spoken, mixed into the handshake hash
+	ProtocolName = "Noise_XX_25519_AESGCM_SHA256"
+	// Prologue binds the handshake to the peer protocol of the blockchain nodes
+	Prologue = "block_chain/1"
+	// MaxMessage is the largest Noise message, a frame of the transport
+	MaxMessage = 65535
+
+	keySize = 32
+	tagSize = 16
+)
+
+// ErrHandshake is returned when a peer does not complete the handshake
+var ErrHandshake = errors.New("noise handshake failed")
+
+// cipherState encrypts the messages of one direction with a key and a counter nonce
+type cipherState struct {
+	aead  cipher.AEAD
+	nonce uint64
+}
+
+func newCipherState(k []byte) *cipherState {
+	block, _ := aes.NewCipher(k)
+	aead, _ := cipher.NewGCM(block)
+	return &cipherState{aead: aead}
+}
+
+// nonceBytes is the AESGCM nonce of the Noise specification: 32 zero bits then the counter big endian
+func (c *cipherState) nonceBytes() []byte {
+	var n [12]byte
+	binary.BigEndian.PutUint64(n[4:], c.nonce)
+	return n[:]
+}
+
+func (c *cipherState) encrypt(ad []byte, plaintext []byte) []byte {
+	ciphertext := c.aead.Seal(nil, c.nonceBytes(), plaintext, ad)
+	c.nonce++
+	return ciphertext
+}
+
+func (c *cipherState) decrypt(ad []byte, ciphertext []byte) ([]byte, error) {
+	plaintext, err := c.aead.Open(nil, c.nonceBytes(), ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+	c.nonce++
+	return plaintext, nil
+}
+
+// symmetricState is the chaining key and handshake hash of a handshake, with the cipher once keyed
+type symmetricState struct {
+	ck     [32]byte
+	h      [32]byte
+	cipher *cipherState
+}
+
+func newSymmetricState() *symmetricState {
+	s := &symmetricState{}
+	// The protocol name fits in a hash and is padded with zeros
+	copy(s.h[:], ProtocolName)
+	s.ck = s.h
+	s.mixHash([]byte(Prologue))
+	return s
+}
+
+func (s *symmetricState) mixHash(data []byte) {
+	h := sha256.New()
+	h.Write(s.h[:])
+	h.Write(data)
+	copy(s.h[:], h.Sum(nil))
+}
+
+func (s *symmetricState) mixKey(ikm []byte) {
+	ck, k := hkdf(s.ck[:], ikm)
+	copy(s.ck[:], ck)
+	s.cipher = newCipherState(k)
+}
+
+func (s *symmetricState) encryptAndHash(plaintext []byte) []byte {
+	ciphertext := plaintext
+	if s.cipher != nil {
+		ciphertext = s.cipher.encrypt(s.h[:], plaintext)
+	}
+	s.mixHash(ciphertext)
+	return ciphertext
+}
+
+func (s *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext := ciphertext
+	if s.cipher != nil {
+		var err error
+		if plaintext, err = s.cipher.decrypt(s.h[:], ciphertext); err != nil {
+			return nil, err
+		}
+	}
+	s.mixHash(ciphertext)
+	return plaintext, nil
+}
+
+// split returns the ciphers of the initiator to responder and responder to initiator directions
+func (s *symmetricState) split() (*cipherState, *cipherState) {
+	k1, k2 := hkdf(s.ck[:], nil)
+	return newCipherState(k1), newCipherState(k2)
+}
+
+// hkdf is the HKDF of the Noise specification with two outputs
+func hkdf(ck []byte, ikm []byte) ([]byte, []byte) {
+	mac := hmac.New(sha256.New, ck)
+	mac.Write(ikm)
+	temp := mac.Sum(nil)
+	mac = hmac.New(sha256.New, temp)
+	mac.Write([]byte{1})
+	out1 := mac.Sum(nil)
+	mac = hmac.New(sha256.New, temp)
+	mac.Write(out1)
+	mac.Write([]byte{2})
+	return out1, mac.Sum(nil)
+}
+
+func dh(private []byte, public []byte) ([]byte, error) {
+	return curve25519.X25519(private, public)
+}
+
+// handshakeState runs the XX pattern:
+//
+//	-> e
+//	<- e, ee, s, es
+//	-> s, se
+type handshakeState struct {
+	*symmetricState
+	s  *Key
+	e  *Key
+	re []byte
+	rs []byte
+}
+
+func newHandshakeState(s *Key) (*handshakeState, error) {
+	e, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &handshakeState{symmetricState: newSymmetricState(), s: s, e: e}, nil
+}
+
+// mixDH mixes the Diffie-Hellman of private and public into the chaining key
+func (hs *handshakeState) mixDH(private []byte, public []byte) error {
+	k, err := dh(private, public)
+	if err != nil {
+		return ErrHandshake
+	}
+	hs.mixKey(k)
+	return nil
+}
+
+// writeMessage1 is "-> e" by the initiator
+func (hs *handshakeState) writeMessage1() []byte {
+	hs.mixHash(hs.e.Public[:])
+	return append(hs.e.Public[:], hs.encryptAndHash(nil)...)
+}
+
+func (hs *handshakeState) readMessage1(m []byte) error {
+	if len(m) != keySize {
+		return ErrHandshake
+	}
+	hs.re = m[:keySize]
+	hs.mixHash(hs.re)
+	_, err := hs.decryptAndHash(m[keySize:])
+	return err
+}
+
+// writeMessage2 is "<- e, ee, s, es" by the responder
+func (hs *handshakeState) writeMessage2() ([]byte, error) {
+	m := append([]byte{}, hs.e.Public[:]...)
+	hs.mixHash(hs.e.Public[:])
+	if err := hs.mixDH(hs.e.Private[:], hs.re); err != nil {
+		return nil, err
+	}
+	m = append(m, hs.encryptAndHash(hs.s.Public[:])...)
+	if err := hs.mixDH(hs.s.Private[:], hs.re); err != nil {
+		return nil, err
+	}
+	return append(m, hs.encryptAndHash(nil)...), nil
+}
+
+func (hs *handshakeState) readMessage2(m []byte) error {
+	if len(m) != keySize+keySize+tagSize+tagSize {
+		return ErrHandshake
+	}
+	hs.re = m[:keySize]
+	hs.mixHash(hs.re)
+	if err := hs.mixDH(hs.e.Private[:], hs.re); err != nil {
+		return err
+	}
+	rs, err := hs.decryptAndHash(m[keySize : 2*keySize+tagSize])
+	if err != nil {
+		return ErrHandshake
+	}
+	hs.rs = rs
+	if err := hs.mixDH(hs.e.Private[:], hs.rs); err != nil {
+		return err
+	}
+	if _, err := hs.decryptAndHash(m[2*keySize+tagSize:]); err != nil {
+		return ErrHandshake
+	}
+	return nil
+}
+
+// writeMessage3 is "-> s, se" by the initiator
+func (hs *handshakeState) writeMessage3() ([]byte, error) {
+	m := hs.encryptAndHash(hs.s.Public[:])
+	if err := hs.mixDH(hs.s.Private[:], hs.re); err != nil {
+		return nil, err
+	}
+	return append(m, hs.encryptAndHash(nil)...), nil
+}
+
+func (hs *handshakeState) readMessage3(m []byte) error {
+	if len(m) != keySize+tagSize+tagSize {
+		return ErrHandshake
+	}
+	rs, err := hs.decryptAndHash(m[:keySize+tagSize])
+	if err != nil {
+		return ErrHandshake
+	}
+	hs.rs = rs
+	if err := hs.mixDH(hs.e.Private[:], hs.rs); err != nil {
+		return err
+	}
+	if _, err := hs.decryptAndHash(m[keySize+tagSize:]); err != nil {
+		return ErrHandshake
+	}
+	return nil
+}
+
+// randomKey returns 32 random bytes
+func randomKey() ([32]byte, error) {
+	var k [32]byte
+	_, err := rand.Read(k[:])
+	return k, err
+}
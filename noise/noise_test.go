@@ -0,0 +1,179 @@
+package noise
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func testKey(t *testing.T, private string) *Key {
+	t.Helper()
+	var p [32]byte
+	copy(p[:], mustHex(t, private))
+	k, err := keyFromPrivate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// The expected messages were computed with an independent implementation of Noise_XX_25519_AESGCM_SHA256
+// with the prologue block_chain/1, whose X25519 and AES-GCM pass the RFC 7748 and GCM specification vectors.
+func TestHandshakeKnownAnswer(t *testing.T) {
+	initiator := &handshakeState{
+		symmetricState: newSymmetricState(),
+		s:              testKey(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		e:              testKey(t, "202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f"),
+	}
+	responder := &handshakeState{
+		symmetricState: newSymmetricState(),
+		s:              testKey(t, "404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f"),
+		e:              testKey(t, "606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f"),
+	}
+
+	m1 := initiator.writeMessage1()
+	if want := "358072d6365880d1aeea329adf9121383851ed21a28e3b75e965d0d2cd166254"; hex.EncodeToString(m1) != want {
+		t.Fatalf("message 1 = %x, want %s", m1, want)
+	}
+	if err := responder.readMessage1(m1); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := responder.writeMessage2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "675dd574ed7789310b3d2e7681f3790b466c773b1521fecf36577958371ea52f612b92700b6794bc8668390588f9b69aaa554b7ccdbe3ec346313aca7e5165aaff4d9e907f86d9f38a5cc8202cd780db265ff3dbacbbc8fe15f058c26ec0e332"; hex.EncodeToString(m2) != want {
+		t.Fatalf("message 2 = %x, want %s", m2, want)
+	}
+	if err := initiator.readMessage2(m2); err != nil {
+		t.Fatal(err)
+	}
+	m3, err := initiator.writeMessage3()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "c84510cdb41da99f2d84ead62432d2e3c5d5999ac4f4831b8ed64a162324fa727aab88c55a5c1341a0e53e1897766e773b4550ac209047d2a0758d78850f8432"; hex.EncodeToString(m3) != want {
+		t.Fatalf("message 3 = %x, want %s", m3, want)
+	}
+	if err := responder.readMessage3(m3); err != nil {
+		t.Fatal(err)
+	}
+
+	wantHash := mustHex(t, "5983a3c31ffcfebaeda8ba54ec8eabc30e12cf53b3e0ca5b342be09699ea3cd8")
+	if !bytes.Equal(initiator.h[:], wantHash) || !bytes.Equal(responder.h[:], wantHash) {
+		t.Fatalf("handshake hashes %x and %x, want %x", initiator.h, responder.h, wantHash)
+	}
+	if !bytes.Equal(initiator.rs, responder.s.Public[:]) || !bytes.Equal(responder.rs, initiator.s.Public[:]) {
+		t.Fatal("static keys not exchanged")
+	}
+
+	send, _ := initiator.split()
+	if got, want := send.encrypt(nil, []byte("hello responder")), "03289e3fabd8f0fd7255783c4ba7ce6c5c0ed718f7b5df254363748be57ba9"; hex.EncodeToString(got) != want {
+		t.Errorf("initiator transport message = %x, want %s", got, want)
+	}
+	_, send = responder.split()
+	if got, want := send.encrypt(nil, []byte("hello initiator")), "05f14bed2744ee4376bfb3a1aea6c99c82165e1dd37315f99ea26d666b3ef5"; hex.EncodeToString(got) != want {
+		t.Errorf("responder transport message = %x, want %s", got, want)
+	}
+}
+
+// serveOnce accepts one connection of l and returns the error of reading it, echoing what it reads
+func serveOnce(l net.Listener) <-chan error {
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			errs <- err
+			return
+		}
+		_, err = conn.Write(buf)
+		errs <- err
+	}()
+	return errs
+}
+
+func newTestListener(t *testing.T) (*Listener, *Key) {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tl.Close() })
+	return NewListener(tl, key), key
+}
+
+func TestListenerRequiresHandshake(t *testing.T) {
+	l, _ := newTestListener(t)
+	l.RequireHandshake()
+	errs := serveOnce(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /")
+	if err := <-errs; !errors.Is(err, ErrHandshakeRequired) {
+		t.Fatalf("plain connection served with %v, want %v", err, ErrHandshakeRequired)
+	}
+}
+
+func TestListenerAllowsPeers(t *testing.T) {
+	allowed, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := newTestListener(t)
+	l.AllowPeers([][32]byte{allowed.Public})
+
+	for _, tc := range []struct {
+		key  *Key
+		want error
+	}{{allowed, nil}, {stranger, ErrUnknownPeer}} {
+		errs := serveOnce(l)
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		nc, err := Client(conn, tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(nc, "hello")
+		if err := <-errs; !errors.Is(err, tc.want) {
+			t.Errorf("peer %s served with %v, want %v", tc.key, err, tc.want)
+		}
+		if tc.want == nil {
+			buf := make([]byte, 5)
+			if _, err := io.ReadFull(nc, buf); err != nil || string(buf) != "hello" {
+				t.Errorf("echo %q, %v", buf, err)
+			}
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,168 @@
+// Package pkcs11 keeps ECDSA P-256 keys in an HSM, or SoftHSM, reached through its PKCS#11 library.
+// A Key signs inside the token and implements wallet.Signer; the private key never leaves the token.
+// Tokens are only supported in binaries built with cgo and the pkcs11 build tag.
+package pkcs11
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+var (
+	// ErrUnsupported is returned by Open in binaries built without cgo or the pkcs11 build tag
+	ErrUnsupported = errors.New("pkcs11: not supported by this build, rebuild with -tags pkcs11")
+	// ErrKeyNotFound is returned when the token has no key pair with the label
+	ErrKeyNotFound = errors.New("pkcs11: key not found")
+	// ErrUnsupportedKey is returned for a key pair that is not on the P-256 curve
+	ErrUnsupportedKey = errors.New("pkcs11: key is not an ECDSA P-256 key")
+)
+
+// DefaultPIN is the PIN source of a Config without one
+const DefaultPIN = "env:PKCS11_PIN"
+
+// oidP256 is the object identifier of the P-256 curve, the CKA_EC_PARAMS of its keys
+var oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+
+// Config selects the token of a PKCS#11 library and how to log in to it
+type Config struct {
+	// Module is the path of the PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Module string
+	// Slot is the ID of the slot holding the token
+	Slot uint
+	// PIN is where the user PIN is read from, "env:NAME" or "file:PATH", DefaultPIN when empty
+	PIN string
+}
+
+// ReadPIN returns the PIN of source, the variable NAME of the environment for "env:NAME" or the first line
+// of the file at PATH for "file:PATH". A PIN is never taken from the command line, where other users see it.
+func ReadPIN(source string) (string, error) {
+	if source == "" {
+		source = DefaultPIN
+	}
+	switch {
+	case strings.HasPrefix(source, "env:"):
+		name := strings.TrimPrefix(source, "env:")
+		pin, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("pkcs11: PIN variable %s is not set", name)
+		}
+		return pin, nil
+	case strings.HasPrefix(source, "file:"):
+		m, err := ioutil.ReadFile(strings.TrimPrefix(source, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("pkcs11: PIN file: %w", err)
+		}
+		return strings.TrimRight(strings.SplitN(string(m), "\n", 2)[0], "\r"), nil
+	default:
+		return "", fmt.Errorf("pkcs11: invalid PIN source %q, must be env:NAME or file:PATH", source)
+	}
+}
+
+// Token is a logged in session with the token of a Config
+type Token struct {
+	session *session
+	// PKCS#11 sessions must not be used concurrently
+	mux sync.Mutex
+}
+
+// Open logs in to the token of c
+func Open(c Config) (*Token, error) {
+	pin, err := ReadPIN(c.PIN)
+	if err != nil {
+		return nil, err
+	}
+	s, err := openSession(c.Module, c.Slot, pin)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{session: s}, nil
+}
+
+// Close logs out and closes the session. The keys of the token can no longer sign.
+func (t *Token) Close() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.session.close()
+}
+
+// Key returns the key pair of the token whose objects have the CKA_LABEL label
+func (t *Token) Key(label string) (*Key, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	handle, params, point, err := t.session.findKey(label)
+	if err != nil {
+		return nil, err
+	}
+	public, err := parsePublicKey(params, point)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{token: t, label: label, handle: handle, public: public}, nil
+}
+
+// Key is a key pair of a token
+type Key struct {
+	token  *Token
+	label  string
+	handle uint
+	public *ecdsa.PublicKey
+}
+
+// Label returns the CKA_LABEL of the key
+func (k *Key) Label() string {
+	return k.label
+}
+
+// PublicKey returns the public key of the key pair
+func (k *Key) PublicKey() *ecdsa.PublicKey {
+	return k.public
+}
+
+// SignHash returns the signature of h made by the token
+func (k *Key) SignHash(h []byte) (*utils.Signature, error) {
+	k.token.mux.Lock()
+	sig, err := k.token.session.sign(k.handle, h)
+	k.token.mux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	// CKM_ECDSA signatures are r and s of the size of the curve, concatenated
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("pkcs11: invalid signature of %d bytes", len(sig))
+	}
+	s := &utils.Signature{R: new(big.Int).SetBytes(sig[:32]), S: new(big.Int).SetBytes(sig[32:])}
+	if !ecdsa.Verify(k.public, h, s.R, s.S) {
+		return nil, errors.New("pkcs11: token made an invalid signature")
+	}
+	return s, nil
+}
+
+// parsePublicKey returns the P-256 public key of the CKA_EC_PARAMS and CKA_EC_POINT of a key,
+// taking the point either DER encoded, as the specification says, or raw, as some tokens return it
+func parsePublicKey(params []byte, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(params, &oid); err != nil || len(rest) > 0 || !oid.Equal(oidP256) {
+		return nil, ErrUnsupportedKey
+	}
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err == nil && len(rest) == 0 {
+		point = raw
+	}
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil || !bytes.Equal(elliptic.Marshal(curve, x, y), point) {
+		return nil, ErrUnsupportedKey
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,246 @@
+//go:build pkcs11 && cgo && (linux || darwin)
+
+package pkcs11
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <string.h>
+
+// The few PKCS#11 types and constants used, as in pkcs11t.h of the specification, so that building
+// needs no headers of a vendor
+typedef unsigned long ck_ulong;
+
+typedef struct { unsigned char major, minor; } ck_version;
+typedef struct { ck_ulong type; void *value; ck_ulong len; } ck_attribute;
+typedef struct { ck_ulong mechanism; void *parameter; ck_ulong len; } ck_mechanism;
+typedef struct {
+	void *create_mutex, *destroy_mutex, *lock_mutex, *unlock_mutex;
+	ck_ulong flags;
+	void *reserved;
+} ck_initialize_args;
+
+// ck_function_list is CK_FUNCTION_LIST, whose function pointers follow the version in the order of the specification
+typedef struct {
+	ck_version version;
+	void *f[68];
+} ck_function_list;
+
+enum {
+	fn_initialize = 0,
+	fn_open_session = 12,
+	fn_close_session = 13,
+	fn_login = 18,
+	fn_logout = 19,
+	fn_get_attribute_value = 24,
+	fn_find_objects_init = 26,
+	fn_find_objects = 27,
+	fn_find_objects_final = 28,
+	fn_sign_init = 42,
+	fn_sign = 43,
+};
+
+#define CKR_OK 0x0UL
+#define CKR_GENERAL_ERROR 0x5UL
+#define CKR_USER_ALREADY_LOGGED_IN 0x100UL
+#define CKR_CRYPTOKI_ALREADY_INITIALIZED 0x191UL
+#define CKF_OS_LOCKING_OK 0x2UL
+#define CKF_SERIAL_SESSION 0x4UL
+#define CKU_USER 1UL
+#define CKA_CLASS 0x0UL
+#define CKA_LABEL 0x3UL
+#define CKA_KEY_TYPE 0x100UL
+#define CKA_EC_PARAMS 0x180UL
+#define CKA_EC_POINT 0x181UL
+#define CKO_PUBLIC_KEY 2UL
+#define CKO_PRIVATE_KEY 3UL
+#define CKK_EC 3UL
+#define CKM_ECDSA 0x1041UL
+
+typedef ck_ulong (*ck_get_function_list)(ck_function_list **);
+typedef ck_ulong (*ck_initialize)(void *);
+typedef ck_ulong (*ck_open_session)(ck_ulong, ck_ulong, void *, void *, ck_ulong *);
+typedef ck_ulong (*ck_close_session)(ck_ulong);
+typedef ck_ulong (*ck_login)(ck_ulong, ck_ulong, unsigned char *, ck_ulong);
+typedef ck_ulong (*ck_logout)(ck_ulong);
+typedef ck_ulong (*ck_get_attribute_value)(ck_ulong, ck_ulong, ck_attribute *, ck_ulong);
+typedef ck_ulong (*ck_find_objects_init)(ck_ulong, ck_attribute *, ck_ulong);
+typedef ck_ulong (*ck_find_objects)(ck_ulong, ck_ulong *, ck_ulong, ck_ulong *);
+typedef ck_ulong (*ck_find_objects_final)(ck_ulong);
+typedef ck_ulong (*ck_sign_init)(ck_ulong, ck_mechanism *, ck_ulong);
+typedef ck_ulong (*ck_sign)(ck_ulong, unsigned char *, ck_ulong, unsigned char *, ck_ulong *);
+
+// p11_load loads the library at path and initializes it for use from several threads
+static ck_ulong p11_load(const char *path, ck_function_list **fl) {
+	void *lib = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (lib == NULL) {
+		return CKR_GENERAL_ERROR;
+	}
+	ck_get_function_list get = (ck_get_function_list)dlsym(lib, "C_GetFunctionList");
+	if (get == NULL) {
+		return CKR_GENERAL_ERROR;
+	}
+	ck_ulong rv = get(fl);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	ck_initialize_args args;
+	memset(&args, 0, sizeof(args));
+	args.flags = CKF_OS_LOCKING_OK;
+	rv = ((ck_initialize)(*fl)->f[fn_initialize])(&args);
+	return rv == CKR_CRYPTOKI_ALREADY_INITIALIZED ? CKR_OK : rv;
+}
+
+// p11_open opens a session with the token of slot and logs in with pin
+static ck_ulong p11_open(ck_function_list *fl, ck_ulong slot, char *pin, ck_ulong *session) {
+	ck_ulong rv = ((ck_open_session)fl->f[fn_open_session])(slot, CKF_SERIAL_SESSION, NULL, NULL, session);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	rv = ((ck_login)fl->f[fn_login])(*session, CKU_USER, (unsigned char *)pin, strlen(pin));
+	if (rv != CKR_OK && rv != CKR_USER_ALREADY_LOGGED_IN) {
+		((ck_close_session)fl->f[fn_close_session])(*session);
+		return rv;
+	}
+	return CKR_OK;
+}
+
+static ck_ulong p11_close(ck_function_list *fl, ck_ulong session) {
+	((ck_logout)fl->f[fn_logout])(session);
+	return ((ck_close_session)fl->f[fn_close_session])(session);
+}
+
+// p11_find finds the EC key object of class labelled label, setting found to the number of them
+static ck_ulong p11_find(ck_function_list *fl, ck_ulong session, ck_ulong class, char *label, ck_ulong *object, ck_ulong *found) {
+	ck_ulong key_type = CKK_EC;
+	ck_attribute template[3] = {
+		{CKA_CLASS, &class, sizeof(class)},
+		{CKA_KEY_TYPE, &key_type, sizeof(key_type)},
+		{CKA_LABEL, label, strlen(label)},
+	};
+	ck_ulong rv = ((ck_find_objects_init)fl->f[fn_find_objects_init])(session, template, 3);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	ck_ulong objects[2];
+	rv = ((ck_find_objects)fl->f[fn_find_objects])(session, objects, 2, found);
+	((ck_find_objects_final)fl->f[fn_find_objects_final])(session);
+	if (rv == CKR_OK && *found > 0) {
+		*object = objects[0];
+	}
+	return rv;
+}
+
+// p11_attribute reads the attribute type of object into value, or only its length when value is NULL
+static ck_ulong p11_attribute(ck_function_list *fl, ck_ulong session, ck_ulong object, ck_ulong type, void *value, ck_ulong *len) {
+	ck_attribute attribute = {type, value, *len};
+	ck_ulong rv = ((ck_get_attribute_value)fl->f[fn_get_attribute_value])(session, object, &attribute, 1);
+	*len = attribute.len;
+	return rv;
+}
+
+static ck_ulong p11_sign(ck_function_list *fl, ck_ulong session, ck_ulong key, void *data, ck_ulong len, void *sig, ck_ulong *sig_len) {
+	ck_mechanism mechanism = {CKM_ECDSA, NULL, 0};
+	ck_ulong rv = ((ck_sign_init)fl->f[fn_sign_init])(session, &mechanism, key);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	return ((ck_sign)fl->f[fn_sign])(session, (unsigned char *)data, len, (unsigned char *)sig, sig_len);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// session is a logged in session with a token
+type session struct {
+	fl     *C.ck_function_list
+	handle C.ck_ulong
+}
+
+// ckError is the CK_RV of a failed call
+func ckError(call string, rv C.ck_ulong) error {
+	return fmt.Errorf("pkcs11: %s: CK_RV 0x%x", call, uint64(rv))
+}
+
+func openSession(module string, slot uint, pin string) (*session, error) {
+	cmodule := C.CString(module)
+	defer C.free(unsafe.Pointer(cmodule))
+	s := &session{}
+	if rv := C.p11_load(cmodule, &s.fl); rv != C.CKR_OK {
+		return nil, ckError("load "+module, rv)
+	}
+	cpin := C.CString(pin)
+	defer C.free(unsafe.Pointer(cpin))
+	if rv := C.p11_open(s.fl, C.ck_ulong(slot), cpin, &s.handle); rv != C.CKR_OK {
+		return nil, ckError(fmt.Sprintf("open slot %d", slot), rv)
+	}
+	return s, nil
+}
+
+func (s *session) close() error {
+	if rv := C.p11_close(s.fl, s.handle); rv != C.CKR_OK {
+		return ckError("close", rv)
+	}
+	return nil
+}
+
+// findKey returns the private key object labelled label and the CKA_EC_PARAMS and CKA_EC_POINT
+// of the public key object with the same label
+func (s *session) findKey(label string) (uint, []byte, []byte, error) {
+	clabel := C.CString(label)
+	defer C.free(unsafe.Pointer(clabel))
+	var private, public, found C.ck_ulong
+	if rv := C.p11_find(s.fl, s.handle, C.CKO_PRIVATE_KEY, clabel, &private, &found); rv != C.CKR_OK {
+		return 0, nil, nil, ckError("find private key", rv)
+	}
+	if found == 0 {
+		return 0, nil, nil, fmt.Errorf("%w: private key %q", ErrKeyNotFound, label)
+	}
+	if found > 1 {
+		return 0, nil, nil, fmt.Errorf("pkcs11: several private keys %q", label)
+	}
+	if rv := C.p11_find(s.fl, s.handle, C.CKO_PUBLIC_KEY, clabel, &public, &found); rv != C.CKR_OK {
+		return 0, nil, nil, ckError("find public key", rv)
+	}
+	if found != 1 {
+		return 0, nil, nil, fmt.Errorf("%w: public key %q", ErrKeyNotFound, label)
+	}
+	params, err := s.attribute(public, C.CKA_EC_PARAMS)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	point, err := s.attribute(public, C.CKA_EC_POINT)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return uint(private), params, point, nil
+}
+
+func (s *session) attribute(object C.ck_ulong, typ C.ck_ulong) ([]byte, error) {
+	var l C.ck_ulong
+	if rv := C.p11_attribute(s.fl, s.handle, object, typ, nil, &l); rv != C.CKR_OK {
+		return nil, ckError("get attribute length", rv)
+	}
+	value := C.malloc(C.size_t(l) + 1)
+	defer C.free(value)
+	if rv := C.p11_attribute(s.fl, s.handle, object, typ, value, &l); rv != C.CKR_OK {
+		return nil, ckError("get attribute", rv)
+	}
+	return C.GoBytes(value, C.int(l)), nil
+}
+
+func (s *session) sign(handle uint, h []byte) ([]byte, error) {
+	data := C.CBytes(h)
+	defer C.free(data)
+	var sig [128]byte
+	l := C.ck_ulong(len(sig))
+	if rv := C.p11_sign(s.fl, s.handle, C.ck_ulong(handle), data, C.ck_ulong(len(h)), unsafe.Pointer(&sig[0]), &l); rv != C.CKR_OK {
+		return nil, ckError("sign", rv)
+	}
+	return sig[:l], nil
+}
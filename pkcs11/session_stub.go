@@ -0,0 +1,22 @@
+//go:build !pkcs11 || !cgo || !(linux || darwin)
+
+package pkcs11
+
+// session is a token session of builds without PKCS#11 support, which can not be opened
+type session struct{}
+
+func openSession(module string, slot uint, pin string) (*session, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *session) close() error {
+	return ErrUnsupported
+}
+
+func (s *session) findKey(label string) (uint, []byte, []byte, error) {
+	return 0, nil, nil, ErrUnsupported
+}
+
+func (s *session) sign(handle uint, h []byte) ([]byte, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,77 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ClientTimeout is the timeout of a request to the signer
+const ClientTimeout = 10 * time.Second
+
+// Client talks to a Signer listening on a unix socket
+type Client struct {
+	client *http.Client
+}
+
+// NewClient returns a Client of the Signer listening on the unix socket at path
+func NewClient(path string) *Client {
+	dialer := &net.Dialer{}
+	return &Client{client: &http.Client{
+		Timeout: ClientTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}}
+}
+
+// do sends a request to the signer and decodes its JSON response into v
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	var m []byte
+	if body != nil {
+		m, _ = json.Marshal(body)
+	}
+	// The host is ignored, the socket is dialed
+	req, err := http.NewRequestWithContext(ctx, method, "http://signer"+path, bytes.NewReader(m))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusNotFound:
+		return ErrUnknownKey
+	default:
+		return fmt.Errorf("signer: %s", resp.Status)
+	}
+}
+
+// Keys returns the keys held by the signer
+func (c *Client) Keys(ctx context.Context) ([]Key, error) {
+	var keys []Key
+	if err := c.do(ctx, http.MethodGet, "/keys", nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Sign returns the signature of t by the key of the blockchain address key
+func (c *Client) Sign(ctx context.Context, key string, t Transaction) (*SignResponse, error) {
+	var resp SignResponse
+	if err := c.do(ctx, http.MethodPost, "/sign", &SignRequest{Key: key, Transaction: t}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
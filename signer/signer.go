@@ -0,0 +1,194 @@
+// Package signer keeps wallet keys out of the internet-facing wallet server. A Signer holds the
+// decrypted keys, or keys of an HSM, and signs transactions for the clients of a local unix socket,
+// which only get signatures.
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/address"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// ErrUnknownKey is returned for a sign request with a key the signer does not hold
+var ErrUnknownKey = errors.New("unknown key")
+
+// Transaction is the transaction to sign, the fields of the signing payload of a wallet.Transaction
+type Transaction struct {
+	ChainID                    string  `json:"chain_id"`
+	SenderBlockchainAddress    string  `json:"sender_blockchain_address"`
+	RecipientBlockchainAddress string  `json:"recipient_blockchain_address"`
+	Value                      float32 `json:"value"`
+	LockUntil                  int64   `json:"lock_until,omitempty"`
+	Name                       string  `json:"name,omitempty"`
+	Fee                        float32 `json:"fee,omitempty"`
+	Nonce                      uint64  `json:"nonce,omitempty"`
+}
+
+// Sign returns the signature of t by key
+func Sign(key wallet.Signer, t Transaction) (*utils.Signature, error) {
+	transaction := wallet.NewTransaction(nil, key.PublicKey(), t.SenderBlockchainAddress, t.RecipientBlockchainAddress, t.Value, t.LockUntil)
+	if t.Name != "" {
+		transaction = wallet.NewNameTransaction(nil, key.PublicKey(), t.SenderBlockchainAddress, t.RecipientBlockchainAddress, t.Name, t.Value)
+	}
+	transaction.SetFee(t.Fee, t.Nonce)
+	transaction.SetChainID(t.ChainID)
+	return transaction.SignWith(key)
+}
+
+// SignRequest asks for the signature of Transaction by the key of the blockchain address Key.
+// Key is the sender, except for the participants signing the spending of an escrow.
+type SignRequest struct {
+	Key         string      `json:"key"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// SignResponse is the signature of a SignRequest and the public key verifying it
+type SignResponse struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// Key is a key held by the signer
+type Key struct {
+	BlockchainAddress string `json:"blockchain_address"`
+	PublicKey         string `json:"public_key"`
+}
+
+// Signer holds the keys of wallets and signs with them
+type Signer struct {
+	keys   map[string]wallet.Signer
+	logger utils.Logger
+	mux    sync.Mutex
+}
+
+// New returns a Signer without keys
+func New() *Signer {
+	return &Signer{keys: make(map[string]wallet.Signer), logger: utils.StdLogger{}}
+}
+
+// SetLogger sets where the signer writes its logs
+func (s *Signer) SetLogger(l utils.Logger) {
+	s.logger = l
+}
+
+// Add makes the signer sign with key, a *wallet.Wallet or a key of an HSM, for its blockchain address
+func (s *Signer) Add(key wallet.Signer) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	blockchainAddress := address.FromPublicKey(key.PublicKey())
+	s.keys[blockchainAddress] = key
+	return blockchainAddress
+}
+
+// Keys returns the keys held by the signer, sorted by blockchain address
+func (s *Signer) Keys() []Key {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	keys := make([]Key, 0, len(s.keys))
+	for blockchainAddress, key := range s.keys {
+		p := key.PublicKey()
+		keys = append(keys, Key{blockchainAddress, fmt.Sprintf("%064x%064x", p.X.Bytes(), p.Y.Bytes())})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].BlockchainAddress < keys[j].BlockchainAddress
+	})
+	return keys
+}
+
+// Sign signs the transaction of r
+func (s *Signer) Sign(r SignRequest) (*SignResponse, error) {
+	s.mux.Lock()
+	key, ok := s.keys[r.Key]
+	s.mux.Unlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	sig, err := Sign(key, r.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	p := key.PublicKey()
+	return &SignResponse{
+		PublicKey: fmt.Sprintf("%064x%064x", p.X.Bytes(), p.Y.Bytes()),
+		Signature: sig.String(),
+	}, nil
+}
+
+// Listen listens on the unix socket at path, replacing a stale socket file. Only the owner may connect.
+func Listen(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve answers the requests of the clients connecting to l until it is closed
+func (s *Signer) Serve(l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys", s.keysHandler)
+	mux.HandleFunc("/sign", s.signHandler)
+	return http.Serve(l, mux)
+}
+
+// keysHandler is handler function that returns the keys held by the signer
+func (s *Signer) keysHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(s.Keys())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		s.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// signHandler is handler function that signs the transaction of a SignRequest
+func (s *Signer) signHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var sr SignRequest
+		if err := json.NewDecoder(r.Body).Decode(&sr); err != nil {
+			s.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		resp, err := s.Sign(sr)
+		if err != nil {
+			s.logger.Printf("ERROR: %s: %v", sr.Key, err)
+			if errors.Is(err, ErrUnknownKey) {
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		s.logger.Printf("Signed for %s: %g to %s on %s", sr.Key, sr.Transaction.Value, sr.Transaction.RecipientBlockchainAddress, sr.Transaction.ChainID)
+		m, _ := json.Marshal(resp)
+		io.WriteString(w, string(m))
+	default:
+		s.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,79 @@
+// Command signer_server holds decrypted wallet keys, or keys of an HSM reached through PKCS#11, and signs
+// transactions for the wallet server over a unix socket, so that the internet-facing wallet server never
+// has private keys in memory. The passphrase of the keystores is read from SIGNER_PASSPHRASE rather than
+// a flag, which other users could read from the process list, and the PIN of the HSM likewise.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hirasawayuki/block_chain/pkcs11"
+	"github.com/hirasawayuki/block_chain/signer"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// PassphraseEnv is the environment variable of the passphrase decrypting the keystores
+const PassphraseEnv = "SIGNER_PASSPHRASE"
+
+func init() {
+	log.SetPrefix("Signer: ")
+}
+
+func main() {
+	socket := flag.String("socket", "signer.sock", "Unix socket the wallet server connects to")
+	keystores := flag.String("keystore", "", "Comma separated keystore or backup files of the keys to sign with")
+	pkcs11Module := flag.String("pkcs11-module", "", "PKCS#11 library of the HSM holding keys to sign with (needs a build with -tags pkcs11)")
+	pkcs11Slot := flag.Uint("pkcs11-slot", 0, "Slot ID of the token of -pkcs11-module")
+	pkcs11PIN := flag.String("pkcs11-pin", pkcs11.DefaultPIN, "Where the PIN of the token is read from, env:NAME or file:PATH")
+	pkcs11Labels := flag.String("pkcs11-label", "", "Comma separated labels of the key pairs of the token to sign with")
+	flag.Parse()
+
+	passphrase := os.Getenv(PassphraseEnv)
+	os.Unsetenv(PassphraseEnv)
+	s := signer.New()
+	for _, file := range strings.Split(*keystores, ",") {
+		if file == "" {
+			continue
+		}
+		m, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w, err := wallet.DecryptWalletFile(m, passphrase)
+		if err != nil {
+			log.Fatalf("%s: %v", file, err)
+		}
+		s.Add(w)
+		log.Printf("Loaded key of %s", w.BlockchainAddress())
+	}
+	if *pkcs11Module != "" {
+		token, err := pkcs11.Open(pkcs11.Config{Module: *pkcs11Module, Slot: *pkcs11Slot, PIN: *pkcs11PIN})
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, label := range strings.Split(*pkcs11Labels, ",") {
+			if label == "" {
+				continue
+			}
+			key, err := token.Key(label)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Loaded key %q of the token, %s", label, s.Add(key))
+		}
+	}
+	if len(s.Keys()) == 0 {
+		log.Fatal("no keys, use -keystore or -pkcs11-label")
+	}
+
+	l, err := signer.Listen(*socket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Listening on %s", *socket)
+	log.Fatal(s.Serve(l))
+}
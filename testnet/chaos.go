@@ -0,0 +1,135 @@
+package testnet
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// ErrDropped is returned for messages dropped by the Faults
+var ErrDropped = errors.New("message dropped")
+
+// Faults is a set of network faults shared by the ChaosTransports of a network:
+// latency added to every message, a random drop rate and partition groups.
+type Faults struct {
+	latency  time.Duration
+	dropRate float64
+	groups   map[string]int
+	rand     *rand.Rand
+	mux      sync.Mutex
+}
+
+// NewFaults returns Faults without any fault. seed makes the random drops reproducible.
+func NewFaults(seed int64) *Faults {
+	return &Faults{
+		groups: make(map[string]int),
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// SetLatency delays every delivered message by d
+func (f *Faults) SetLatency(d time.Duration) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.latency = d
+}
+
+// SetDropRate drops messages with probability p
+func (f *Faults) SetDropRate(p float64) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.dropRate = p
+}
+
+// Partition splits the nodes into groups that can only talk within the group.
+// Nodes not listed in any group form a group of their own.
+func (f *Faults) Partition(groups ...[]string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.groups = make(map[string]int)
+	for i, g := range groups {
+		for _, node := range g {
+			f.groups[node] = i + 1
+		}
+	}
+}
+
+// Heal removes all partitions
+func (f *Faults) Heal() {
+	f.Partition()
+}
+
+// deliver reports whether a message from one node reaches another, after waiting the latency
+func (f *Faults) deliver(from string, to string) bool {
+	f.mux.Lock()
+	latency := f.latency
+	ok := f.groups[from] == f.groups[to] && (f.dropRate <= 0 || f.rand.Float64() >= f.dropRate)
+	f.mux.Unlock()
+	if ok && latency > 0 {
+		time.Sleep(latency)
+	}
+	return ok
+}
+
+// ChaosTransport is a block.Transport that applies Faults to the messages of an inner Transport
+type ChaosTransport struct {
+	inner  block.Transport
+	self   string
+	faults *Faults
+}
+
+// NewChaosTransport returns a ChaosTransport of the node self
+func NewChaosTransport(inner block.Transport, self string, faults *Faults) *ChaosTransport {
+	return &ChaosTransport{inner: inner, self: self, faults: faults}
+}
+
+// FindNeighbors is not affected by faults; partitioned nodes still know each other
+func (t *ChaosTransport) FindNeighbors(ctx context.Context, port uint16) []string {
+	return t.inner.FindNeighbors(ctx, port)
+}
+
+func (t *ChaosTransport) SendTransaction(ctx context.Context, neighbor string, tr *block.TransactionRequest) error {
+	if !t.faults.deliver(t.self, neighbor) {
+		return ErrDropped
+	}
+	return t.inner.SendTransaction(ctx, neighbor, tr)
+}
+
+func (t *ChaosTransport) ClearTransactions(ctx context.Context, neighbor string) error {
+	if !t.faults.deliver(t.self, neighbor) {
+		return ErrDropped
+	}
+	return t.inner.ClearTransactions(ctx, neighbor)
+}
+
+func (t *ChaosTransport) RequestConsensus(ctx context.Context, neighbor string) error {
+	if !t.faults.deliver(t.self, neighbor) {
+		return ErrDropped
+	}
+	return t.inner.RequestConsensus(ctx, neighbor)
+}
+
+func (t *ChaosTransport) SendAlert(ctx context.Context, neighbor string, a *block.DoubleSpendAlert) error {
+	if !t.faults.deliver(t.self, neighbor) {
+		return ErrDropped
+	}
+	return t.inner.SendAlert(ctx, neighbor, a)
+}
+
+func (t *ChaosTransport) GetChain(ctx context.Context, neighbor string, maxSize int64) ([]*block.Block, bool) {
+	if !t.faults.deliver(t.self, neighbor) {
+		return nil, false
+	}
+	return t.inner.GetChain(ctx, neighbor, maxSize)
+}
+
+func (t *ChaosTransport) Handshake(ctx context.Context, neighbor string) (block.NodeInfo, bool) {
+	if !t.faults.deliver(t.self, neighbor) {
+		return block.NodeInfo{}, false
+	}
+	return t.inner.Handshake(ctx, neighbor)
+}
@@ -0,0 +1,179 @@
+// Package testnet wires several Blockchain nodes together in process so that
+// broadcast, consensus and reorg behavior can be tested without HTTP or ports.
+package testnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// ErrUnknownNode is returned for messages to a node that is not in the Network
+var ErrUnknownNode = errors.New("unknown node")
+
+// Network is a set of in-process Blockchain nodes named node0, node1, ...
+type Network struct {
+	names  []string
+	nodes  map[string]*block.Blockchain
+	miners map[string]*wallet.Wallet
+	faults *Faults
+	mux    sync.Mutex
+}
+
+// New returns a Network of n nodes. Neighbors are not known until Sync is called.
+func New(n int) *Network {
+	net := &Network{
+		nodes:  make(map[string]*block.Blockchain),
+		miners: make(map[string]*wallet.Wallet),
+		faults: NewFaults(1),
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("node%d", i)
+		miner := wallet.NewWallet()
+		net.names = append(net.names, name)
+		net.miners[name] = miner
+		transport := NewChaosTransport(&Transport{network: net, self: name}, name, net.faults)
+		net.nodes[name] = block.NewBlockChainWithTransport(miner.BlockchainAddress(), uint16(i), transport)
+	}
+	return net
+}
+
+// Len returns the number of nodes
+func (net *Network) Len() int {
+	return len(net.names)
+}
+
+// Node returns the i-th node
+func (net *Network) Node(i int) *block.Blockchain {
+	return net.nodes[net.names[i]]
+}
+
+// Miner returns the wallet receiving the mining rewards of the i-th node
+func (net *Network) Miner(i int) *wallet.Wallet {
+	return net.miners[net.names[i]]
+}
+
+// Faults returns the faults applied to the messages between nodes
+func (net *Network) Faults() *Faults {
+	return net.faults
+}
+
+// Partition splits the nodes, given by index, into groups that can only talk within the group
+func (net *Network) Partition(groups ...[]int) {
+	named := make([][]string, 0, len(groups))
+	for _, g := range groups {
+		names := make([]string, 0, len(g))
+		for _, i := range g {
+			names = append(names, net.names[i])
+		}
+		named = append(named, names)
+	}
+	net.faults.Partition(named...)
+}
+
+// SetClock makes every node use c, e.g. a shared block.ManualClock for deterministic tests
+func (net *Network) SetClock(c block.Clock) {
+	for _, name := range net.names {
+		net.nodes[name].SetClock(c)
+	}
+}
+
+// Sync makes every node discover its neighbors
+func (net *Network) Sync() {
+	for _, name := range net.names {
+		net.nodes[name].SyncNeighbors(context.Background())
+	}
+}
+
+func (net *Network) node(name string) (*block.Blockchain, bool) {
+	net.mux.Lock()
+	defer net.mux.Unlock()
+	bc, ok := net.nodes[name]
+	return bc, ok
+}
+
+// Transport is the in-process block.Transport of a node in a Network
+type Transport struct {
+	network *Network
+	self    string
+}
+
+// FindNeighbors returns every other node of the network
+func (t *Transport) FindNeighbors(ctx context.Context, port uint16) []string {
+	neighbors := make([]string, 0, len(t.network.names))
+	for _, name := range t.network.names {
+		if name != t.self {
+			neighbors = append(neighbors, name)
+		}
+	}
+	return neighbors
+}
+
+// SendTransaction adds the transaction to the neighbor's pool
+func (t *Transport) SendTransaction(ctx context.Context, neighbor string, tr *block.TransactionRequest) error {
+	bc, ok := t.network.node(neighbor)
+	if !ok {
+		return ErrUnknownNode
+	}
+	return bc.AddTransactionRequest(tr)
+}
+
+// ClearTransactions clears the neighbor's transaction pool
+func (t *Transport) ClearTransactions(ctx context.Context, neighbor string) error {
+	bc, ok := t.network.node(neighbor)
+	if !ok {
+		return ErrUnknownNode
+	}
+	bc.ClearTransactionPool()
+	return nil
+}
+
+// RequestConsensus makes the neighbor resolve conflicts
+func (t *Transport) RequestConsensus(ctx context.Context, neighbor string) error {
+	bc, ok := t.network.node(neighbor)
+	if !ok {
+		return ErrUnknownNode
+	}
+	bc.ResolveConflicts(ctx)
+	return nil
+}
+
+// SendAlert makes the neighbor receive a double-spend alert
+func (t *Transport) SendAlert(ctx context.Context, neighbor string, a *block.DoubleSpendAlert) error {
+	bc, ok := t.network.node(neighbor)
+	if !ok {
+		return ErrUnknownNode
+	}
+	return bc.ReceiveAlert(ctx, a)
+}
+
+// GetChain returns a copy of the neighbor's chain, encoded and decoded as over the wire
+func (t *Transport) GetChain(ctx context.Context, neighbor string, maxSize int64) ([]*block.Block, bool) {
+	bc, ok := t.network.node(neighbor)
+	if !ok {
+		return nil, false
+	}
+	m, err := json.Marshal(bc)
+	if err != nil || int64(len(m)) > maxSize {
+		return nil, false
+	}
+	var copied block.Blockchain
+	if err := json.Unmarshal(m, &copied); err != nil {
+		return nil, false
+	}
+	return copied.Chain(), true
+}
+
+// Handshake returns the NodeInfo of the neighbor
+func (t *Transport) Handshake(ctx context.Context, neighbor string) (block.NodeInfo, bool) {
+	bc, ok := t.network.node(neighbor)
+	if !ok {
+		return block.NodeInfo{}, false
+	}
+	return bc.NodeInfo(), true
+}
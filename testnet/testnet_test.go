@@ -0,0 +1,69 @@
+package testnet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+// tip returns the hash of the last block of the i-th node
+func tip(net *Network, i int) [32]byte {
+	return net.Node(i).LastBlock().Hash()
+}
+
+func TestPartitionConverges(t *testing.T) {
+	net := New(4)
+	net.Sync()
+	ctx := context.Background()
+
+	net.Partition([]int{0, 1}, []int{2, 3})
+	if n := net.Node(0).Generate(ctx, 1); n != 1 {
+		t.Fatalf("node0 mined %d blocks, want 1", n)
+	}
+	if n := net.Node(2).Generate(ctx, 3); n != 3 {
+		t.Fatalf("node2 mined %d blocks, want 3", n)
+	}
+	if tip(net, 1) != tip(net, 0) || tip(net, 3) != tip(net, 2) {
+		t.Fatal("nodes of a partition did not follow the node mining there")
+	}
+	if tip(net, 0) == tip(net, 2) {
+		t.Fatal("partitions share a tip while split")
+	}
+
+	net.Faults().Heal()
+	for i := 0; i < net.Len(); i++ {
+		net.Node(i).ResolveConflicts(ctx)
+	}
+	want := tip(net, 2)
+	for i := 0; i < net.Len(); i++ {
+		if tip(net, i) != want {
+			t.Errorf("node%d tip %x after healing, want %x", i, tip(net, i), want)
+		}
+		if !net.Node(i).ValidChain(net.Node(i).Chain()) {
+			t.Errorf("node%d chain is invalid after healing", i)
+		}
+	}
+}
+
+func TestChainAheadOfClockIsAdoptedLater(t *testing.T) {
+	net := New(2)
+	net.Sync()
+	ctx := context.Background()
+
+	behind := block.NewManualClock(time.Now())
+	net.Node(0).SetClock(block.NewManualClock(behind.Now().Add(time.Hour)))
+	net.Node(1).SetClock(behind)
+	if n := net.Node(0).Generate(ctx, 1); n != 1 {
+		t.Fatalf("node0 mined %d blocks, want 1", n)
+	}
+	if net.Node(1).ResolveConflicts(ctx) {
+		t.Fatal("node1 adopted a chain dated an hour ahead of its clock")
+	}
+	// The rejection depended on the clock of node1, so the chain is checked again once it caught up
+	behind.Advance(time.Hour)
+	if !net.Node(1).ResolveConflicts(ctx) || tip(net, 1) != tip(net, 0) {
+		t.Fatal("node1 did not adopt the chain once its clock caught up")
+	}
+}
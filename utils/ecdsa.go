@@ -4,6 +4,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 )
@@ -31,6 +32,32 @@ func String2BigIntTuple(s string) (big.Int, big.Int) {
 	return bix, biy
 }
 
+// ErrInvalidSignature is returned by ParseSignature for a malformed signature
+var ErrInvalidSignature = errors.New("signature must be 128 hex digits of R and S in [1, N-1]")
+
+// ParseSignature parses a signature in the format of Signature.String, checking that
+// R and S are in the range of valid P-256 signature values.
+func ParseSignature(s string) (*Signature, error) {
+	if len(s) != 128 {
+		return nil, ErrInvalidSignature
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	n := elliptic.P256().Params().N
+	r := new(big.Int).SetBytes(b[:32])
+	ss := new(big.Int).SetBytes(b[32:])
+	for _, v := range []*big.Int{r, ss} {
+		if v.Sign() == 0 || v.Cmp(n) >= 0 {
+			return nil, ErrInvalidSignature
+		}
+	}
+	return &Signature{r, ss}, nil
+}
+
+// SignatureFromString converts a signature in the format of Signature.String without validation.
+// Use ParseSignature for signatures from untrusted input.
 func SignatureFromString(s string) *Signature {
 	x, y := String2BigIntTuple(s)
 	return &Signature{&x, &y}
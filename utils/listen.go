@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// Listen listens for TCP connections on port of all interfaces. Port 0 picks a free port,
+// which ListenPort reports. A port already in use is reported as such.
+func Listen(port uint16) (net.Listener, error) {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil, fmt.Errorf("port %d is already in use, choose another one or 0 to pick a free one: %w", port, err)
+	}
+	return l, err
+}
+
+// ListenPort returns the port l listens on
+func ListenPort(l net.Listener) uint16 {
+	if a, ok := l.Addr().(*net.TCPAddr); ok {
+		return uint16(a.Port)
+	}
+	return 0
+}
+
+// LocalAddresses returns the IP addresses of the interfaces of this host
+func LocalAddresses() map[netip.Addr]bool {
+	local := map[netip.Addr]bool{netip.IPv6Loopback(): true, netip.MustParseAddr("127.0.0.1"): true}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return local
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			if addr, ok := netip.AddrFromSlice(ipnet.IP); ok {
+				local[addr.Unmap()] = true
+			}
+		}
+	}
+	return local
+}
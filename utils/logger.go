@@ -0,0 +1,31 @@
+package utils
+
+import "log"
+
+// Logger is where the packages write their logs; *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+	Println(v ...interface{})
+}
+
+// StdLogger is a Logger writing to the standard logger of the log package
+type StdLogger struct{}
+
+// Printf calls log.Printf
+func (StdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// Println calls log.Println
+func (StdLogger) Println(v ...interface{}) {
+	log.Println(v...)
+}
+
+// NopLogger is a Logger discarding everything, to silence tests
+type NopLogger struct{}
+
+// Printf does nothing
+func (NopLogger) Printf(format string, v ...interface{}) {}
+
+// Println does nothing
+func (NopLogger) Println(v ...interface{}) {}
@@ -1,57 +1,97 @@
 package utils
 
 import (
-	"fmt"
 	"log"
 	"net"
+	"net/netip"
 	"os"
-	"regexp"
 	"strconv"
 	"time"
 )
 
-var pattern = regexp.MustCompile(`(((25[0-5]|2[0-4]\d|1?\d{1,2})\.){3})(25[0-5]|2[0-4]\d|1?\d{1,2})`)
-
+// IsFoundHost reports whether something listens on port of host, an IPv4 or IPv6 address
 func IsFoundHost(host string, port uint16) bool {
-	target := fmt.Sprintf("%s:%d", host, port)
-	_, err := net.DialTimeout("tcp", target, 1*time.Second)
+	target := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	conn, err := net.DialTimeout("tcp", target, 1*time.Second)
 	if err != nil {
 		log.Printf("%s %v\n", target, err)
 		return false
 	}
+	conn.Close()
 	return true
 }
 
+// FindNeighbors returns the nodes listening in the port range on the hosts following myHost.
+// The node itself is skipped on every address of this host, not only on myHost.
+// Addresses are returned as host:port, with IPv6 hosts in brackets.
 func FindNeighbors(myHost string, myPort uint16, startIP uint8, endIP uint8, startPort uint16, endPort uint16) []string {
-	address := fmt.Sprintf("%s:%d", myHost, myPort)
-	m := pattern.FindStringSubmatch(myHost)
-	if m == nil {
+	host, err := netip.ParseAddr(myHost)
+	if err != nil {
 		return nil
 	}
-	prefixHost := m[1]
-	lastIP, _ := strconv.Atoi(m[len(m)-1])
+	host = host.Unmap()
+	local := LocalAddresses()
 	neighbors := make([]string, 0)
 
 	for port := startPort; port <= endPort; port++ {
-		for ip := startIP; ip <= endIP; ip++ {
-			guessHost := fmt.Sprintf("%s%d", prefixHost, lastIP+int(ip))
-			guessTarget := fmt.Sprintf("%s:%d", guessHost, port)
-			if guessTarget != address && IsFoundHost(guessHost, port) {
-				neighbors = append(neighbors, guessTarget)
+		guess := host
+		for i := uint8(0); i < startIP; i++ {
+			guess = guess.Next()
+		}
+		for ip := int(startIP); ip <= int(endIP) && guess.IsValid(); ip++ {
+			if port != myPort || (guess != host && !local[guess]) {
+				if IsFoundHost(guess.String(), port) {
+					neighbors = append(neighbors, netip.AddrPortFrom(guess, port).String())
+				}
 			}
+			guess = guess.Next()
 		}
 	}
 	return neighbors
 }
 
+// GetHost returns the address of this host, preferring a non-loopback interface.
+// IPv4 addresses are preferred over IPv6 ones.
 func GetHost() string {
-	hostname, err := os.Hostname()
-	if err != nil {
-		return "127.0.0.1"
+	return HostAddr().String()
+}
+
+// HostAddr returns the address of this host, preferring a non-loopback interface
+func HostAddr() netip.Addr {
+	var v6 netip.Addr
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(ipnet.IP)
+			if !ok {
+				continue
+			}
+			addr = addr.Unmap()
+			if !addr.IsGlobalUnicast() {
+				continue
+			}
+			if addr.Is4() {
+				return addr
+			}
+			if !v6.IsValid() {
+				v6 = addr
+			}
+		}
 	}
-	address, err := net.LookupHost(hostname)
-	if err != nil {
-		return "127.0.0.1"
+	if v6.IsValid() {
+		return v6
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		if addresses, err := net.LookupHost(hostname); err == nil {
+			for _, s := range addresses {
+				if addr, err := netip.ParseAddr(s); err == nil {
+					return addr.Unmap()
+				}
+			}
+		}
 	}
-	return address[0]
+	return netip.MustParseAddr("127.0.0.1")
 }
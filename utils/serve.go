@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ShutdownTimeout is how long Serve waits for the requests being served once its context is done
+const ShutdownTimeout = 10 * time.Second
+
+// Serve serves HTTP requests on l with handler until ctx is done, then shuts the server down, waiting up to
+// ShutdownTimeout for the requests being served. Requests carry ctx, so streams end on shutdown.
+// It returns nil once shut down.
+func Serve(ctx context.Context, l net.Listener, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
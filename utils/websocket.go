@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// webSocketGUID is the GUID of RFC 6455 the Sec-WebSocket-Accept key is derived with
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// MaxWebSocketFrame is the largest payload of a frame read from a client
+	MaxWebSocketFrame = 4096
+	// WebSocketWriteTimeout is how long a frame may take to send before the connection is given up
+	WebSocketWriteTimeout = 10 * time.Second
+)
+
+// WebSocket frame opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// ErrWebSocketHandshake is returned by UpgradeWebSocket for a request that is not a WebSocket opening handshake
+var ErrWebSocketHandshake = errors.New("not a websocket handshake")
+
+// WebSocket is the server end of a WebSocket connection (RFC 6455) sending text messages. Frames from
+// the client are read only to answer pings and the closing handshake.
+type WebSocket struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mux  sync.Mutex
+	done chan struct{}
+	once sync.Once
+}
+
+// UpgradeWebSocket answers the WebSocket opening handshake of r and takes over the connection of w.
+// Nothing is written to w when it returns an error.
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	if r.Method != http.MethodGet || !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		return nil, ErrWebSocketHandshake
+	}
+	if v := r.Header.Get("Sec-WebSocket-Version"); v != "13" {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrWebSocketHandshake, v)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("%w: missing key", ErrWebSocketHandshake)
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("websocket: connection can not be taken over")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	accept := sha1.Sum([]byte(key + webSocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ws := &WebSocket{conn: conn, r: rw.Reader, done: make(chan struct{})}
+	go ws.readLoop()
+	return ws, nil
+}
+
+// headerHas reports whether the comma separated header name has token, ignoring case
+func headerHas(h http.Header, name string, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteText sends m as a text message
+func (ws *WebSocket) WriteText(m []byte) error {
+	return ws.writeFrame(wsText, m)
+}
+
+// Ping sends a ping, which the client answers
+func (ws *WebSocket) Ping() error {
+	return ws.writeFrame(wsPing, nil)
+}
+
+// Done returns a channel closed once the connection is closed, by either end
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.done
+}
+
+// Close sends a normal closure and closes the connection
+func (ws *WebSocket) Close() error {
+	ws.writeFrame(wsClose, []byte{0x03, 0xe8})
+	return ws.close()
+}
+
+func (ws *WebSocket) close() error {
+	var err error
+	ws.once.Do(func() {
+		close(ws.done)
+		err = ws.conn.Close()
+	})
+	return err
+}
+
+// writeFrame sends a single unmasked frame, as servers do
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	ws.conn.SetWriteDeadline(time.Now().Add(WebSocketWriteTimeout))
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// readLoop answers the pings and the close of the client until the connection ends
+func (ws *WebSocket) readLoop() {
+	defer ws.close()
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsPing:
+			ws.writeFrame(wsPong, payload)
+		case wsClose:
+			// Echo the status code, if any
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			ws.writeFrame(wsClose, payload)
+			return
+		}
+	}
+}
+
+// readFrame reads a frame of the client, which must be masked
+func (ws *WebSocket) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("websocket: unmasked client frame")
+	}
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > MaxWebSocketFrame {
+		return 0, nil, fmt.Errorf("websocket: frame of %d bytes", n)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0f, payload, nil
+}
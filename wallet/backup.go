@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// BackupVersion is the version of the encrypted backup format
+	BackupVersion = 1
+	// scrypt parameters recommended for interactive logins
+	backupScryptN = 1 << 15
+	backupScryptR = 8
+	backupScryptP = 1
+)
+
+var (
+	// ErrInvalidPrivateKey is returned for a private key that is not a P-256 scalar
+	ErrInvalidPrivateKey = errors.New("invalid private key")
+	// ErrInvalidBackup is returned for a backup that is not in the backup format
+	ErrInvalidBackup = errors.New("invalid backup")
+	// ErrWrongPassphrase is returned when a backup cannot be decrypted with the passphrase
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted backup")
+)
+
+// Backup is a wallet private key encrypted with AES-256-GCM under a key derived from
+// a passphrase with scrypt. It is superseded by Keystore, which records its KDF parameters; backups are still restored. The blockchain address is left readable to tell backups apart,
+// and authenticated so that it cannot be swapped.
+type Backup struct {
+	Version           int    `json:"version"`
+	BlockchainAddress string `json:"blockchain_address"`
+	Salt              []byte `json:"salt"`
+	Nonce             []byte `json:"nonce"`
+	Ciphertext        []byte `json:"ciphertext"`
+}
+
+// NewWalletFromPrivateKeyStr returns the Wallet of a hex encoded private key, as returned by PrivateKeyStr
+func NewWalletFromPrivateKeyStr(s string) (*Wallet, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) > 32 {
+		return nil, ErrInvalidPrivateKey
+	}
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(b)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrInvalidPrivateKey
+	}
+	privateKey := new(ecdsa.PrivateKey)
+	privateKey.PublicKey.Curve = curve
+	privateKey.D = d
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+	return newWalletFromPrivateKey(privateKey), nil
+}
+
+func backupCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, backupScryptN, backupScryptR, backupScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptBackup returns the JSON encoded Backup of w encrypted with passphrase
+func EncryptBackup(w *Wallet, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := backupCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&Backup{
+		Version:           BackupVersion,
+		BlockchainAddress: w.blockchainAddress,
+		Salt:              salt,
+		Nonce:             nonce,
+		Ciphertext:        aead.Seal(nil, nonce, w.privateKey.D.FillBytes(make([]byte, 32)), []byte(w.blockchainAddress)),
+	}, "", "  ")
+}
+
+// DecryptBackup returns the Wallet of a JSON encoded Backup encrypted with passphrase
+func DecryptBackup(data []byte, passphrase string) (*Wallet, error) {
+	var b Backup
+	if err := json.Unmarshal(data, &b); err != nil || b.Version != BackupVersion || len(b.Salt) == 0 {
+		return nil, ErrInvalidBackup
+	}
+	aead, err := backupCipher(passphrase, b.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Nonce) != aead.NonceSize() {
+		return nil, ErrInvalidBackup
+	}
+	d, err := aead.Open(nil, b.Nonce, b.Ciphertext, []byte(b.BlockchainAddress))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	w, err := NewWalletFromPrivateKeyStr(hex.EncodeToString(d))
+	if err != nil {
+		return nil, err
+	}
+	if w.blockchainAddress != b.BlockchainAddress {
+		return nil, ErrInvalidBackup
+	}
+	return w, nil
+}
+
+// BackupRequest is a request to download the Keystore of a wallet, with KDF scrypt (the default) or pbkdf2
+type BackupRequest struct {
+	PrivateKey *string `json:"private_key,omitempty"`
+	Passphrase *string `json:"passphrase,omitempty"`
+	KDF        *string `json:"kdf,omitempty"`
+}
+
+func (br *BackupRequest) Validate() bool {
+	if br.PrivateKey == nil ||
+		br.Passphrase == nil ||
+		*br.Passphrase == "" {
+		return false
+	}
+	if br.KDF != nil && *br.KDF != KDFScrypt && *br.KDF != KDFPBKDF2 {
+		return false
+	}
+	return true
+}
+
+// KDFParams returns the KDF of the request and its default parameters
+func (br *BackupRequest) KDFParams() (string, KDFParams) {
+	if br.KDF != nil && *br.KDF == KDFPBKDF2 {
+		return KDFPBKDF2, DefaultPBKDF2Params
+	}
+	return KDFScrypt, DefaultScryptParams
+}
+
+// RestoreRequest is a request to restore or verify a wallet from its Keystore or Backup
+type RestoreRequest struct {
+	Backup     json.RawMessage `json:"backup,omitempty"`
+	Passphrase *string         `json:"passphrase,omitempty"`
+}
+
+func (rr *RestoreRequest) Validate() bool {
+	if len(rr.Backup) == 0 ||
+		rr.Passphrase == nil {
+		return false
+	}
+	return true
+}
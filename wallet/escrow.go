@@ -0,0 +1,40 @@
+package wallet
+
+// EscrowRequest is a request to create a 2-of-3 escrow between buyer, seller and arbiter
+type EscrowRequest struct {
+	BuyerBlockchainAddress  *string `json:"buyer_blockchain_address,omitempty"`
+	SellerBlockchainAddress *string `json:"seller_blockchain_address,omitempty"`
+	BuyerPublicKey          *string `json:"buyer_public_key,omitempty"`
+	SellerPublicKey         *string `json:"seller_public_key,omitempty"`
+	ArbiterPublicKey        *string `json:"arbiter_public_key,omitempty"`
+	Value                   *string `json:"value,omitempty"`
+}
+
+func (er *EscrowRequest) Validate() bool {
+	if er.BuyerBlockchainAddress == nil ||
+		er.SellerBlockchainAddress == nil ||
+		er.BuyerPublicKey == nil ||
+		er.SellerPublicKey == nil ||
+		er.ArbiterPublicKey == nil ||
+		er.Value == nil {
+		return false
+	}
+	return true
+}
+
+// EscrowActionRequest is a request of a participant to accept, release or refund an escrow
+type EscrowActionRequest struct {
+	EscrowAddress    *string `json:"escrow_address,omitempty"`
+	SignerPublicKey  *string `json:"signer_public_key,omitempty"`
+	SignerPrivateKey *string `json:"signer_private_key,omitempty"`
+	// ConfirmationCode is the passphrase or TOTP code required for amounts above the spending limit
+	ConfirmationCode *string `json:"confirmation_code,omitempty"`
+}
+
+func (ear *EscrowActionRequest) Validate() bool {
+	if ear.EscrowAddress == nil ||
+		ear.SignerPublicKey == nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ErrEmptyPassphrase is returned when saving a wallet without a passphrase
+var ErrEmptyPassphrase = errors.New("empty passphrase")
+
+// Save writes the Keystore of the wallet encrypted with passphrase, with DefaultScryptParams, to the file at path.
+// The file is readable only by its owner and replaced only once written in full.
+func (w *Wallet) Save(path string, passphrase string) error {
+	if passphrase == "" {
+		return ErrEmptyPassphrase
+	}
+	m, err := EncryptKeystore(w, passphrase, KDFScrypt, DefaultScryptParams)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", m, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Load returns the Wallet of the file at path, a Keystore or a Backup encrypted with passphrase,
+// or an unencrypted PEM private key, for which passphrase is ignored
+func Load(path string, passphrase string) (*Wallet, error) {
+	m, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(m), []byte("-----BEGIN")) {
+		return NewWalletFromPEM(m)
+	}
+	return DecryptWalletFile(m, passphrase)
+}
+
+// ParsePrivateKey returns the Wallet of a private key, hex encoded as returned by PrivateKeyStr or a PEM block
+func ParsePrivateKey(s string) (*Wallet, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "-----BEGIN") {
+		return NewWalletFromPEM([]byte(s))
+	}
+	return NewWalletFromPrivateKeyStr(s)
+}
+
+// ImportRequest is a request to import the private key of an existing wallet, hex encoded or a PEM block
+type ImportRequest struct {
+	PrivateKey *string `json:"private_key"`
+}
+
+func (ir *ImportRequest) Validate() bool {
+	if ir.PrivateKey == nil || *ir.PrivateKey == "" {
+		return false
+	}
+	return true
+}
+
+// LoadRequest is a request to load the wallet of a blockchain address from the keystore directory
+type LoadRequest struct {
+	BlockchainAddress *string `json:"blockchain_address"`
+	Passphrase        *string `json:"passphrase"`
+}
+
+func (lr *LoadRequest) Validate() bool {
+	if lr.BlockchainAddress == nil ||
+		lr.Passphrase == nil {
+		return false
+	}
+	return true
+}
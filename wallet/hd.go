@@ -0,0 +1,47 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+)
+
+// HDWallet derives any number of wallets from a single master seed. Backing up the
+// seed backs up every derived wallet, which can be derived again by its index.
+type HDWallet struct {
+	seed []byte
+}
+
+// NewHDWallet returns a HDWallet with the master seed
+func NewHDWallet(seed []byte) *HDWallet {
+	s := make([]byte, len(seed))
+	copy(s, seed)
+	return &HDWallet{seed: s}
+}
+
+// NewRandomHDWallet returns a HDWallet with a random 32 byte master seed
+func NewRandomHDWallet() (*HDWallet, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return &HDWallet{seed: seed}, nil
+}
+
+// Seed returns the master seed
+func (hd *HDWallet) Seed() []byte {
+	s := make([]byte, len(hd.seed))
+	copy(s, hd.seed)
+	return s
+}
+
+// Derive returns the wallet at index, whose key is generated from HMAC-SHA512(seed, index)
+func (hd *HDWallet) Derive(index uint32) *Wallet {
+	mac := hmac.New(sha512.New, hd.seed)
+	var i [4]byte
+	binary.BigEndian.PutUint32(i[:], index)
+	mac.Write(i[:])
+	return NewWalletFromReader(bytes.NewReader(mac.Sum(nil)))
+}
@@ -0,0 +1,18 @@
+package wallet
+
+// InvoiceRequest is a request to create an invoice
+type InvoiceRequest struct {
+	Value     *string `json:"value,omitempty"`
+	ExpirySec *int64  `json:"expiry_sec,omitempty"`
+	Memo      *string `json:"memo,omitempty"`
+}
+
+func (ir *InvoiceRequest) Validate() bool {
+	if ir.Value == nil {
+		return false
+	}
+	if ir.ExpirySec != nil && *ir.ExpirySec <= 0 {
+		return false
+	}
+	return true
+}
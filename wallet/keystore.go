@@ -0,0 +1,198 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// KeystoreVersion is the version of the keystore format
+	KeystoreVersion = 1
+	// KeystoreCipher is the cipher encrypting the private key of a keystore
+	KeystoreCipher = "aes-256-gcm"
+	// KDFScrypt derives the keystore key with scrypt
+	KDFScrypt = "scrypt"
+	// KDFPBKDF2 derives the keystore key with PBKDF2-HMAC-SHA256
+	KDFPBKDF2 = "pbkdf2"
+
+	// Upper bounds of the KDF parameters accepted when decrypting, so that a crafted
+	// keystore cannot make the node spend minutes or gigabytes deriving a key
+	maxScryptN       = 1 << 20
+	maxScryptRP      = 1 << 10
+	maxPBKDF2Rounds  = 10000000
+	keystoreKeyBytes = 32
+)
+
+// ErrInvalidKeystore is returned for data that is not a keystore this version can decrypt
+var ErrInvalidKeystore = errors.New("invalid keystore")
+
+// KDFParams are the parameters of the key derivation function of a keystore.
+// N, R and P are used by scrypt, C and PRF by PBKDF2.
+type KDFParams struct {
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+}
+
+// DefaultScryptParams are scrypt parameters suited to keys kept offline, slower than those of Backup
+var DefaultScryptParams = KDFParams{DKLen: keystoreKeyBytes, N: 1 << 18, R: 8, P: 1}
+
+// DefaultPBKDF2Params are PBKDF2 parameters for tools without scrypt
+var DefaultPBKDF2Params = KDFParams{DKLen: keystoreKeyBytes, C: 600000, PRF: "hmac-sha256"}
+
+// KeystoreCrypto is the encrypted private key and how to decrypt it
+type KeystoreCrypto struct {
+	Cipher       string `json:"cipher"`
+	CipherText   string `json:"ciphertext"`
+	CipherParams struct {
+		Nonce string `json:"nonce"`
+	} `json:"cipherparams"`
+	KDF       string    `json:"kdf"`
+	KDFParams KDFParams `json:"kdfparams"`
+}
+
+// Keystore is the cold storage export of a wallet key, modeled on Ethereum keystores: the private key
+// is encrypted with AES-256-GCM, authenticating the address, under a key derived from a passphrase.
+// Everything needed to decrypt it, except the passphrase, is recorded in the file.
+type Keystore struct {
+	Version           int            `json:"version"`
+	ID                string         `json:"id"`
+	BlockchainAddress string         `json:"blockchain_address"`
+	Crypto            KeystoreCrypto `json:"crypto"`
+}
+
+// deriveKey returns the key derived from passphrase with kdf and params, checking the parameters first
+func deriveKey(passphrase string, kdf string, params KDFParams) ([]byte, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil || len(salt) < 16 || params.DKLen != keystoreKeyBytes {
+		return nil, ErrInvalidKeystore
+	}
+	switch kdf {
+	case KDFScrypt:
+		if params.N < 2 || params.N > maxScryptN || params.N&(params.N-1) != 0 ||
+			params.R < 1 || params.R > maxScryptRP || params.P < 1 || params.P > maxScryptRP {
+			return nil, fmt.Errorf("%w: scrypt parameters out of range", ErrInvalidKeystore)
+		}
+		return scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	case KDFPBKDF2:
+		if params.PRF != "hmac-sha256" || params.C < 1 || params.C > maxPBKDF2Rounds {
+			return nil, fmt.Errorf("%w: pbkdf2 parameters out of range", ErrInvalidKeystore)
+		}
+		return pbkdf2.Key([]byte(passphrase), salt, params.C, params.DKLen, sha256.New), nil
+	}
+	return nil, fmt.Errorf("%w: unknown kdf %q", ErrInvalidKeystore, kdf)
+}
+
+// EncryptKeystore returns the JSON encoded Keystore of w encrypted with passphrase.
+// kdf is KDFScrypt or KDFPBKDF2 and params its parameters, without the salt.
+func EncryptKeystore(w *Wallet, passphrase string, kdf string, params KDFParams) ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params.Salt = hex.EncodeToString(salt)
+	key, err := deriveKey(passphrase, kdf, params)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	// A version 4 UUID
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	ks := &Keystore{
+		Version:           KeystoreVersion,
+		ID:                fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		BlockchainAddress: w.blockchainAddress,
+	}
+	ks.Crypto.Cipher = KeystoreCipher
+	ks.Crypto.CipherText = hex.EncodeToString(aead.Seal(nil, nonce, w.privateKey.D.FillBytes(make([]byte, 32)), []byte(w.blockchainAddress)))
+	ks.Crypto.CipherParams.Nonce = hex.EncodeToString(nonce)
+	ks.Crypto.KDF = kdf
+	ks.Crypto.KDFParams = params
+	return json.MarshalIndent(ks, "", "  ")
+}
+
+// DecryptKeystore returns the Wallet of a JSON encoded Keystore encrypted with passphrase
+func DecryptKeystore(data []byte, passphrase string) (*Wallet, error) {
+	var ks Keystore
+	if err := json.Unmarshal(data, &ks); err != nil || ks.Version != KeystoreVersion || ks.Crypto.Cipher != KeystoreCipher {
+		return nil, ErrInvalidKeystore
+	}
+	key, err := deriveKey(passphrase, ks.Crypto.KDF, ks.Crypto.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(ks.Crypto.CipherParams.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, ErrInvalidKeystore
+	}
+	ciphertext, err := hex.DecodeString(ks.Crypto.CipherText)
+	if err != nil {
+		return nil, ErrInvalidKeystore
+	}
+	d, err := aead.Open(nil, nonce, ciphertext, []byte(ks.BlockchainAddress))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	w, err := NewWalletFromPrivateKeyStr(hex.EncodeToString(d))
+	if err != nil {
+		return nil, err
+	}
+	if w.blockchainAddress != ks.BlockchainAddress {
+		return nil, ErrInvalidKeystore
+	}
+	return w, nil
+}
+
+// IsKeystore reports whether data looks like a Keystore rather than a Backup
+func IsKeystore(data []byte) bool {
+	var v struct {
+		Crypto *json.RawMessage `json:"crypto"`
+	}
+	return json.Unmarshal(data, &v) == nil && v.Crypto != nil
+}
+
+// DecryptWalletFile returns the Wallet of a Keystore or a Backup encrypted with passphrase
+func DecryptWalletFile(data []byte, passphrase string) (*Wallet, error) {
+	if IsKeystore(data) {
+		return DecryptKeystore(data, passphrase)
+	}
+	return DecryptBackup(data, passphrase)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,133 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// mnemonicEnglish is the English wordlist of BIP-39, one word per line
+//
+//go:embed mnemonic_english.txt
+var mnemonicEnglish string
+
+var (
+	mnemonicWords = strings.Fields(mnemonicEnglish)
+	mnemonicIndex = func() map[string]int {
+		index := make(map[string]int, len(mnemonicWords))
+		for i, w := range mnemonicWords {
+			index[w] = i
+		}
+		return index
+	}()
+)
+
+// ErrInvalidMnemonic is returned for a phrase that is not a BIP-39 mnemonic of the English wordlist
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// NewMnemonic returns a random BIP-39 mnemonic of words words: 12, 15, 18, 21 or 24
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	}
+	// Every 3 words encode 32 bits of entropy and 1 bit of checksum
+	entropy := make([]byte, words/3*4)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return mnemonicFromEntropy(entropy), nil
+}
+
+// mnemonicFromEntropy returns the words of entropy followed by the first bits of its SHA-256, 11 bits a word
+func mnemonicFromEntropy(entropy []byte) string {
+	checksumBits := uint(len(entropy) / 4)
+	h := sha256.Sum256(entropy)
+	n := new(big.Int).SetBytes(entropy)
+	n.Lsh(n, checksumBits)
+	n.Or(n, big.NewInt(int64(h[0]>>(8-checksumBits))))
+
+	count := (len(entropy)*8 + int(checksumBits)) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = mnemonicWords[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 11)
+	}
+	return strings.Join(words, " ")
+}
+
+// ValidMnemonic reports whether mnemonic is a BIP-39 mnemonic with a valid checksum
+func ValidMnemonic(mnemonic string) bool {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return false
+	}
+	n := new(big.Int)
+	for _, w := range words {
+		i, ok := mnemonicIndex[strings.ToLower(w)]
+		if !ok {
+			return false
+		}
+		n.Lsh(n, 11)
+		n.Or(n, big.NewInt(int64(i)))
+	}
+	checksumBits := uint(len(words) / 3)
+	checksum := new(big.Int).And(n, big.NewInt(1<<checksumBits-1)).Int64()
+	entropy := n.Rsh(n, checksumBits).FillBytes(make([]byte, len(words)/3*4))
+	h := sha256.Sum256(entropy)
+	return int64(h[0]>>(8-checksumBits)) == checksum
+}
+
+// MnemonicSeed returns the 64 byte BIP-39 seed of mnemonic protected by passphrase, "" for none
+func MnemonicSeed(mnemonic string, passphrase string) ([]byte, error) {
+	if !ValidMnemonic(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	normalized := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
+
+// NewWalletFromMnemonic returns the wallet of mnemonic and passphrase, the first wallet of the HDWallet
+// with the BIP-39 seed. The same phrase and passphrase always restore the same wallet.
+func NewWalletFromMnemonic(mnemonic string, passphrase string) (*Wallet, error) {
+	seed, err := MnemonicSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	w := NewHDWallet(seed).Derive(0)
+	w.mnemonic = strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+	return w, nil
+}
+
+// NewMnemonicWallet returns a new wallet backed up by a random mnemonic of words words
+func NewMnemonicWallet(words int, passphrase string) (*Wallet, error) {
+	mnemonic, err := NewMnemonic(words)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalletFromMnemonic(mnemonic, passphrase)
+}
+
+// Mnemonic returns the mnemonic the wallet was made from, "" when it was not
+func (w *Wallet) Mnemonic() string {
+	return w.mnemonic
+}
+
+// MnemonicRequest is a request to restore a wallet from its mnemonic and optional passphrase
+type MnemonicRequest struct {
+	Mnemonic   *string `json:"mnemonic"`
+	Passphrase *string `json:"passphrase,omitempty"`
+}
+
+func (mr *MnemonicRequest) Validate() bool {
+	if mr.Mnemonic == nil || *mr.Mnemonic == "" {
+		return false
+	}
+	return true
+}
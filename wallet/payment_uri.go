@@ -0,0 +1,57 @@
+package wallet
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PaymentURIScheme is the scheme of payment URIs
+const PaymentURIScheme = "blockchain"
+
+// PaymentURI is a payment request with recipient blockchain address, amount and memo.
+// It is rendered as blockchain:<address>?amount=<amount>&memo=<memo>
+type PaymentURI struct {
+	BlockchainAddress string  `json:"blockchain_address"`
+	Amount            float32 `json:"amount,omitempty"`
+	Memo              string  `json:"memo,omitempty"`
+}
+
+// String is returns the payment URI
+func (p *PaymentURI) String() string {
+	q := url.Values{}
+	if p.Amount > 0 {
+		q.Set("amount", strconv.FormatFloat(float64(p.Amount), 'f', -1, 32))
+	}
+	if p.Memo != "" {
+		q.Set("memo", p.Memo)
+	}
+	u := url.URL{Scheme: PaymentURIScheme, Opaque: p.BlockchainAddress, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// ParsePaymentURI parses a blockchain: payment URI
+func ParsePaymentURI(s string) (*PaymentURI, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != PaymentURIScheme {
+		return nil, errors.New("invalid payment uri scheme")
+	}
+	if u.Opaque == "" {
+		return nil, errors.New("missing blockchain address")
+	}
+	p := &PaymentURI{BlockchainAddress: u.Opaque}
+	q := u.Query()
+	if a := q.Get("amount"); a != "" {
+		amount, err := strconv.ParseFloat(a, 32)
+		if err != nil || amount < 0 {
+			return nil, errors.New("invalid amount")
+		}
+		p.Amount = float32(amount)
+	}
+	p.Memo = q.Get("memo")
+	return p, nil
+}
@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+const (
+	pemPrivateKey   = "PRIVATE KEY"
+	pemECPrivateKey = "EC PRIVATE KEY"
+	pemPublicKey    = "PUBLIC KEY"
+)
+
+// ErrUnsupportedKey is returned for a key that is not a P-256 ECDSA key
+var ErrUnsupportedKey = errors.New("not a P-256 ECDSA key")
+
+// PrivateKeyDER returns the private key in PKCS#8 DER encoding
+func (w *Wallet) PrivateKeyDER() ([]byte, error) {
+	return x509.MarshalPKCS8PrivateKey(w.privateKey)
+}
+
+// PrivateKeyPEM returns the private key as a PKCS#8 "PRIVATE KEY" PEM block,
+// as written by `openssl genpkey` or `openssl pkcs8 -topk8 -nocrypt`
+func (w *Wallet) PrivateKeyPEM() ([]byte, error) {
+	der, err := w.PrivateKeyDER()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil
+}
+
+// ECPrivateKeyPEM returns the private key as a SEC1 "EC PRIVATE KEY" PEM block,
+// as written by `openssl ecparam -genkey`
+func (w *Wallet) ECPrivateKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(w.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemECPrivateKey, Bytes: der}), nil
+}
+
+// PublicKeyDER returns the public key in PKIX DER encoding
+func (w *Wallet) PublicKeyDER() ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(w.publicKey)
+}
+
+// PublicKeyPEM returns the public key as a PKIX "PUBLIC KEY" PEM block
+func (w *Wallet) PublicKeyPEM() ([]byte, error) {
+	der, err := w.PublicKeyDER()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPublicKey, Bytes: der}), nil
+}
+
+// NewWalletFromDER returns the Wallet of a PKCS#8 or SEC1 DER encoded P-256 private key
+func NewWalletFromDER(der []byte) (*Wallet, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		privateKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, ErrUnsupportedKey
+		}
+		return newWalletFromP256Key(privateKey)
+	}
+	privateKey, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return newWalletFromP256Key(privateKey)
+}
+
+// NewWalletFromPEM returns the Wallet of the first "PRIVATE KEY" or "EC PRIVATE KEY" PEM block of data
+func NewWalletFromPEM(data []byte) (*Wallet, error) {
+	for {
+		var b *pem.Block
+		b, data = pem.Decode(data)
+		if b == nil {
+			return nil, errors.New("no private key PEM block found")
+		}
+		if b.Type == pemPrivateKey || b.Type == pemECPrivateKey {
+			return NewWalletFromDER(b.Bytes)
+		}
+	}
+}
+
+// PublicKeyFromPEM returns the P-256 public key of a PKIX "PUBLIC KEY" PEM block
+func PublicKeyFromPEM(data []byte) (*ecdsa.PublicKey, error) {
+	b, _ := pem.Decode(data)
+	if b == nil || b.Type != pemPublicKey {
+		return nil, errors.New("no public key PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(b.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return nil, ErrUnsupportedKey
+	}
+	return publicKey, nil
+}
+
+func newWalletFromP256Key(privateKey *ecdsa.PrivateKey) (*Wallet, error) {
+	if privateKey.Curve != elliptic.P256() {
+		return nil, ErrUnsupportedKey
+	}
+	return newWalletFromPrivateKey(privateKey), nil
+}
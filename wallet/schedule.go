@@ -0,0 +1,42 @@
+package wallet
+
+// ScheduleRequest is a request to create a recurring payment. SenderPrivateKey is left out when the
+// wallet server signs with a signer daemon.
+type ScheduleRequest struct {
+	SenderPrivateKey           *string `json:"sender_private_key,omitempty"`
+	SenderBlockchainAddress    *string `json:"sender_blockchain_address,omitempty"`
+	RecipientBlockchainAddress *string `json:"recipient_blockchain_address,omitempty"`
+	SenderPublicKey            *string `json:"sender_public_key,omitempty"`
+	Value                      *string `json:"value,omitempty"`
+	IntervalSec                *int64  `json:"interval_sec,omitempty"`
+	// ConfirmationCode is the passphrase or TOTP code required for amounts above the spending limit
+	ConfirmationCode *string `json:"confirmation_code,omitempty"`
+}
+
+func (sr *ScheduleRequest) Validate() bool {
+	if sr.SenderBlockchainAddress == nil ||
+		sr.RecipientBlockchainAddress == nil ||
+		sr.SenderPublicKey == nil ||
+		sr.Value == nil ||
+		sr.IntervalSec == nil ||
+		*sr.IntervalSec <= 0 {
+		return false
+	}
+	return true
+}
+
+// UnlockRequest is a request to hold the private key of a sender in memory so its schedules can be signed
+type UnlockRequest struct {
+	SenderPrivateKey        *string `json:"sender_private_key,omitempty"`
+	SenderBlockchainAddress *string `json:"sender_blockchain_address,omitempty"`
+	SenderPublicKey         *string `json:"sender_public_key,omitempty"`
+}
+
+func (ur *UnlockRequest) Validate() bool {
+	if ur.SenderPrivateKey == nil ||
+		ur.SenderBlockchainAddress == nil ||
+		ur.SenderPublicKey == nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// Signer holds an ECDSA P-256 key, in memory like a Wallet or in an HSM, and signs hashes with it
+type Signer interface {
+	PublicKey() *ecdsa.PublicKey
+	SignHash(h []byte) (*utils.Signature, error)
+}
+
+// NewWalletFromPrivateKey returns the Wallet of privateKey
+func NewWalletFromPrivateKey(privateKey *ecdsa.PrivateKey) *Wallet {
+	return newWalletFromPrivateKey(privateKey)
+}
+
+// SignHash returns the signature of h by the wallet key
+func (w *Wallet) SignHash(h []byte) (*utils.Signature, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, w.privateKey, h)
+	if err != nil {
+		return nil, err
+	}
+	return &utils.Signature{R: r, S: s}, nil
+}
+
+// SignWith returns the signature of the transaction by s, which need not hold the private key of the transaction
+func (t *Transaction) SignWith(s Signer) (*utils.Signature, error) {
+	h := sha256.Sum256(t.SigningPayload())
+	return s.SignHash(h[:])
+}
@@ -7,10 +7,11 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
 
-	"github.com/btcsuite/btcutil/base58"
+	"github.com/hirasawayuki/block_chain/address"
 	"github.com/hirasawayuki/block_chain/utils"
-	"golang.org/x/crypto/ripemd160"
 )
 
 // Wallet is struct that dedicated to cryptocurrencies
@@ -18,12 +19,47 @@ type Wallet struct {
 	privateKey        *ecdsa.PrivateKey
 	publicKey         *ecdsa.PublicKey
 	blockchainAddress string
+	// mnemonic is the BIP-39 phrase the key is derived from, "" for other wallets
+	mnemonic string
 }
 
 // NewWallet is return Wallet struct with public key and private key
 func NewWallet() *Wallet {
+	return NewWalletFromReader(rand.Reader)
+}
+
+// NewWalletFromReader is return Wallet struct with a key generated from the entropy of r.
+// The same entropy always gives the same keys and blockchain address, which makes it usable for tests and demos.
+func NewWalletFromReader(r io.Reader) *Wallet {
+	privateKey, err := generateKey(r)
+	if err != nil {
+		return nil
+	}
+	return newWalletFromPrivateKey(privateKey)
+}
+
+// generateKey returns a P-256 private key derived from 40 bytes of r.
+// Unlike ecdsa.GenerateKey, the result only depends on the bytes read.
+func generateKey(r io.Reader) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	b := make([]byte, curve.Params().BitSize/8+8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	n := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	d := new(big.Int).SetBytes(b)
+	d.Mod(d, n)
+	d.Add(d, big.NewInt(1))
+
+	privateKey := new(ecdsa.PrivateKey)
+	privateKey.PublicKey.Curve = curve
+	privateKey.D = d
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+	return privateKey, nil
+}
+
+func newWalletFromPrivateKey(privateKey *ecdsa.PrivateKey) *Wallet {
 	w := new(Wallet)
-	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 
 	// 0 - Having a private ECDSA key
 	// 18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725
@@ -33,52 +69,9 @@ func NewWallet() *Wallet {
 	// 0250863ad64a87ae8a2fe83c1af1a8403cb53f53e486d8511dad8a04887e5b2352
 	w.publicKey = &w.privateKey.PublicKey
 
-	// 2 - Perform SHA-256 hashing on the public key
-	// 0b7c28c9b7290c98d7438e70b3d3f7c848fbd7d1dc194ff83f4f7cc9b1378e98
-	h2 := sha256.New()
-	h2.Write(w.publicKey.X.Bytes())
-	h2.Write(w.publicKey.Y.Bytes())
-	digest2 := h2.Sum(nil)
-
-	// 3 - Perform RIPEMD-160 hashing on the result of SHA-256
-	// f54a5851e9372b87810a8e60cdd2e7cfd80b6e31
-	h3 := ripemd160.New()
-	h3.Write(digest2)
-	digest3 := h3.Sum(nil)
-
-	// 4 - Add version byte in front of RIPEMD-160 hash (0x00 for Main Network)
-	// 00f54a5851e9372b87810a8e60cdd2e7cfd80b6e31
-	// (note that below steps are the Base58Check encoding, which has multiple library options available implementing it)
-	vb4 := make([]byte, 21)
-	copy(vb4[1:], digest3)
-
-	// 5 - Perform SHA-256 hash on the extended RIPEMD-160 result
-	// ad3c854da227c7e99c4abfad4ea41d71311160df2e415e713318c70d67c6b41c
-
-	h5 := sha256.New()
-	h5.Write(vb4)
-	digest5 := h5.Sum(nil)
-
-	// 6 - Perform SHA-256 hash on the result of the previous SHA-256 hash
-	// c7f18fe8fcbed6396741e58ad259b5cb16b7fd7f041904147ba1dcffabf747fd
-	h6 := sha256.New()
-	h6.Write(digest5)
-	digest6 := h6.Sum(nil)
-
-	// 7 - Take the first 4 bytes of the second SHA-256 hash. This is the address checksum
-	// c7f18fe8
-	checksum := digest6[:4]
-
-	// 8 - Add the 4 checksum bytes from stage 7 at the end of extended RIPEMD-160 hash from stage 4. This is the 25-byte binary Bitcoin Address.
-	// 00f54a5851e9372b87810a8e60cdd2e7cfd80b6e31c7f18fe8
-	h8 := make([]byte, 25)
-	copy(h8[:21], vb4)
-	copy(h8[21:], checksum)
-
-	// 9 - Convert the result from a byte string into a base58 string using Base58Check encoding. This is the most commonly used Bitcoin Address format
+	// 2 - Derive the blockchain address from the public key
 	// 1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs
-	address := base58.Encode(h8)
-	w.blockchainAddress = address
+	w.blockchainAddress = address.FromPublicKey(w.publicKey)
 	return w
 }
 
@@ -113,63 +106,180 @@ func (w *Wallet) MarshalJSON() ([]byte, error) {
 		PrivateKey        string `json:"private_key,omitempty"`
 		PublicKey         string `json:"public_key,omitempty"`
 		BlockchainAddress string `json:"blockchain_address,omitempty"`
+		Mnemonic          string `json:"mnemonic,omitempty"`
 	}{
 		PrivateKey:        w.PrivateKeyStr(),
 		PublicKey:         w.PublicKeyStr(),
 		BlockchainAddress: w.blockchainAddress,
+		Mnemonic:          w.mnemonic,
 	})
 }
 
-// Transaction is struct of transaction with senderPrivateKey, senderPublickKey, senderBlockchainAddress, recipientBlockchainAddress, value
+// Transaction is struct of transaction with senderPrivateKey, senderPublickKey, senderBlockchainAddress, recipientBlockchainAddress, value, lockUntil, fee, nonce,
+// and chainID, the network it is signed for
 type Transaction struct {
 	senderPrivateKey           *ecdsa.PrivateKey
 	senderPublickKey           *ecdsa.PublicKey
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	lockUntil                  int64
+	name                       string
+	fee                        float32
+	nonce                      uint64
+	chainID                    string
+}
+
+// transactionFields are the JSON fields of a Transaction
+type transactionFields struct {
+	SenderBlockchainAddress    string  `json:"sender_blockchain_address,omitempty"`
+	RecipientBlockchainAddress string  `json:"recipient_blockchain_address,omitempty"`
+	Value                      float32 `json:"value,omitempty"`
+	LockUntil                  int64   `json:"lock_until,omitempty"`
+	Name                       string  `json:"name,omitempty"`
+	Fee                        float32 `json:"fee,omitempty"`
+	Nonce                      uint64  `json:"nonce,omitempty"`
 }
 
-// MarshalJSON is returns a struct with sender_blockchain_address, recipient_blockchain_address, value
+// MarshalJSON is returns a struct with sender_blockchain_address, recipient_blockchain_address, value, lock_until, name, fee, nonce
 func (t *Transaction) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		SenderBlockchainAddress    string  `json:"sender_blockchain_address,omitempty"`
-		RecipientBlockchainAddress string  `json:"recipient_blockchain_address,omitempty"`
-		Value                      float32 `json:"value,omitempty"`
-	}{
+	return json.Marshal(t.fields())
+}
+
+func (t *Transaction) fields() transactionFields {
+	return transactionFields{
 		SenderBlockchainAddress:    t.senderBlockchainAddress,
 		RecipientBlockchainAddress: t.recipientBlockchainAddress,
 		Value:                      t.value,
-	})
+		LockUntil:                  t.lockUntil,
+		Name:                       t.name,
+		Fee:                        t.fee,
+		Nonce:                      t.nonce,
+	}
 }
 
 // NewTransaction is returns a pointer that Transaction struct
-func NewTransaction(privateKey *ecdsa.PrivateKey, publickKey *ecdsa.PublicKey, sender string, recipient string, value float32) *Transaction {
-	return &Transaction{privateKey, publickKey, sender, recipient, value}
+func NewTransaction(privateKey *ecdsa.PrivateKey, publickKey *ecdsa.PublicKey, sender string, recipient string, value float32, lockUntil int64) *Transaction {
+	return &Transaction{
+		senderPrivateKey:           privateKey,
+		senderPublickKey:           publickKey,
+		senderBlockchainAddress:    sender,
+		recipientBlockchainAddress: recipient,
+		value:                      value,
+		lockUntil:                  lockUntil,
+	}
+}
+
+// NewNameTransaction is returns a pointer that Transaction struct registering name to the sender.
+// recipient must be the burn address of the blockchain.
+func NewNameTransaction(privateKey *ecdsa.PrivateKey, publickKey *ecdsa.PublicKey, sender string, recipient string, name string, value float32) *Transaction {
+	t := NewTransaction(privateKey, publickKey, sender, recipient, value, 0)
+	t.name = name
+	return t
+}
+
+// Value returns the amount sent to the recipient
+func (t *Transaction) Value() float32 {
+	return t.value
+}
+
+// Fee returns the amount paid to the miner
+func (t *Transaction) Fee() float32 {
+	return t.fee
+}
+
+// SetFee sets the fee paid to the miner and the nonce. Signing again with the same
+// nonce and a higher fee replaces the transaction while it is pending.
+func (t *Transaction) SetFee(fee float32, nonce uint64) {
+	t.fee = fee
+	t.nonce = nonce
+}
+
+// SetChainID sets the ID of the network the transaction is signed for, the chain_id its nodes report on /version
+func (t *Transaction) SetChainID(chainID string) {
+	t.chainID = chainID
+}
+
+// SigningPayload returns the canonical bytes whose SHA-256 hash is signed, the JSON encoding of the transaction
+// after the chain ID, so that the signature is not valid on another network
+func (t *Transaction) SigningPayload() []byte {
+	m, _ := json.Marshal(struct {
+		ChainID string `json:"chain_id"`
+		transactionFields
+	}{t.chainID, t.fields()})
+	return m
+}
+
+// TxID returns the transaction id, the hex encoded SHA-256 hash of the JSON encoding of the transaction.
+// It does not depend on the chain ID.
+func (t *Transaction) TxID() string {
+	m, _ := json.Marshal(t)
+	return fmt.Sprintf("%x", sha256.Sum256(m))
+}
+
+// VerifySignature reports whether s is a signature of the transaction by publicKey
+func (t *Transaction) VerifySignature(publicKey *ecdsa.PublicKey, s *utils.Signature) bool {
+	h := sha256.Sum256(t.SigningPayload())
+	return ecdsa.Verify(publicKey, h[:], s.R, s.S)
 }
 
 // GenerateSignature is returns a Signature struct
 func (t *Transaction) GenerateSignature() *utils.Signature {
-	m, _ := json.Marshal(t)
-	h := sha256.Sum256(m)
+	h := sha256.Sum256(t.SigningPayload())
 	r, s, _ := ecdsa.Sign(rand.Reader, t.senderPrivateKey, h[:])
 	return &utils.Signature{R: r, S: s}
 }
 
+// TransactionRequest is a transaction signed by the client. The signature covers the
+// SigningPayload of the transaction; the private key never leaves the client.
 type TransactionRequest struct {
-	SenderPrivateKey           *string `json:"sender_private_key,omitempty"`
 	SenderBlockchainAddress    *string `json:"sender_blockchain_address,omitempty"`
 	RecipientBlockchainAddress *string `json:"recipient_blockchain_address,omitempty"`
 	SenderPublicKey            *string `json:"sender_public_key,omitempty"`
 	Value                      *string `json:"value,omitempty"`
+	LockUntil                  *string `json:"lock_until,omitempty"`
+	Fee                        *string `json:"fee,omitempty"`
+	Nonce                      *string `json:"nonce,omitempty"`
+	Signature                  *string `json:"signature,omitempty"`
+	// ConfirmationCode is the passphrase or TOTP code required for amounts above the spending limit
+	ConfirmationCode *string `json:"confirmation_code,omitempty"`
 }
 
-func (tr *TransactionRequest) Validate() bool {
-	if tr.SenderPrivateKey == nil ||
-		tr.SenderBlockchainAddress == nil ||
+// ValidatePayload reports whether the fields needed to build the signing payload are present
+func (tr *TransactionRequest) ValidatePayload() bool {
+	if tr.SenderBlockchainAddress == nil ||
 		tr.RecipientBlockchainAddress == nil ||
-		tr.SenderPublicKey == nil ||
 		tr.Value == nil {
 		return false
 	}
 	return true
 }
+
+func (tr *TransactionRequest) Validate() bool {
+	if !tr.ValidatePayload() ||
+		tr.SenderPublicKey == nil ||
+		tr.Signature == nil {
+		return false
+	}
+	return true
+}
+
+// NameRequest is a request to register a name to the sender blockchain address. SenderPrivateKey is
+// left out when the wallet server signs with a signer daemon.
+type NameRequest struct {
+	SenderPrivateKey        *string `json:"sender_private_key,omitempty"`
+	SenderBlockchainAddress *string `json:"sender_blockchain_address,omitempty"`
+	SenderPublicKey         *string `json:"sender_public_key,omitempty"`
+	Name                    *string `json:"name,omitempty"`
+	// ConfirmationCode is the passphrase or TOTP code required for amounts above the spending limit
+	ConfirmationCode *string `json:"confirmation_code,omitempty"`
+}
+
+func (nr *NameRequest) Validate() bool {
+	if nr.SenderBlockchainAddress == nil ||
+		nr.SenderPublicKey == nil ||
+		nr.Name == nil {
+		return false
+	}
+	return true
+}
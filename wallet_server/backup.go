@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// WalletBackup is handler function that returns the wallet of a private key as a keystore file
+func (ws *WalletServer) WalletBackup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var br wallet.BackupRequest
+		if err := json.NewDecoder(r.Body).Decode(&br); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !br.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		myWallet, err := wallet.NewWalletFromPrivateKeyStr(*br.PrivateKey)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		kdf, params := br.KDFParams()
+		m, err := wallet.EncryptKeystore(myWallet, *br.Passphrase, kdf, params)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Header().Add("Content-Disposition", "attachment; filename=\"wallet-"+myWallet.BlockchainAddress()+".json\"")
+		w.Write(m)
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// WalletRestore is handler function that returns the wallet.Wallet data of a keystore or encrypted backup
+func (ws *WalletServer) WalletRestore(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var rr wallet.RestoreRequest
+		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !rr.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		myWallet, err := wallet.DecryptWalletFile(rr.Backup, *rr.Passphrase)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		m, _ := myWallet.MarshalJSON()
+		io.WriteString(w, string(m[:]))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// WalletBackupVerify is handler function that checks that a keystore or encrypted backup decrypts
+// with the passphrase and returns its blockchain address, never the private key
+func (ws *WalletServer) WalletBackupVerify(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var rr wallet.RestoreRequest
+		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil || !rr.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		myWallet, err := wallet.DecryptWalletFile(rr.Backup, *rr.Passphrase)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Message           string `json:"message"`
+			Keystore          bool   `json:"keystore"`
+			BlockchainAddress string `json:"blockchain_address"`
+		}{
+			Message:           "success",
+			Keystore:          wallet.IsKeystore(rr.Backup),
+			BlockchainAddress: myWallet.BlockchainAddress(),
+		})
+		io.WriteString(w, string(m))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BalanceCacheTTLSec is how long a balance fetched from the gateway is served from the cache
+	BalanceCacheTTLSec = 30
+	// EventRetrySec is the delay before reconnecting to the gateway event stream
+	EventRetrySec = 5
+)
+
+type cachedBalance struct {
+	amount    float32
+	spendable float32
+	fetchedAt time.Time
+}
+
+// balanceCache keeps the balances fetched from the gateway for BalanceCacheTTLSec.
+// Entries are dropped early when a new block touches their address.
+type balanceCache struct {
+	balances map[string]cachedBalance
+	mux      sync.Mutex
+}
+
+func newBalanceCache() *balanceCache {
+	return &balanceCache{balances: make(map[string]cachedBalance)}
+}
+
+func (c *balanceCache) get(address string, now time.Time) (cachedBalance, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	b, ok := c.balances[address]
+	if !ok || now.Sub(b.fetchedAt) >= BalanceCacheTTLSec*time.Second {
+		return cachedBalance{}, false
+	}
+	return b, true
+}
+
+func (c *balanceCache) put(address string, amount float32, spendable float32, now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.balances[address] = cachedBalance{amount, spendable, now}
+}
+
+func (c *balanceCache) invalidate(addresses []string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, a := range addresses {
+		delete(c.balances, a)
+	}
+}
+
+func (c *balanceCache) clear() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.balances = make(map[string]cachedBalance)
+}
+
+// StartEventListener follows the block events of the gateway to invalidate cached balances until ctx is done.
+// Balances cached while the stream is down could miss an event, so they are dropped on every reconnect.
+func (ws *WalletServer) StartEventListener(ctx context.Context) {
+	go func() {
+		for {
+			ws.balances.clear()
+			if err := ws.listenEvents(ctx, ws.Gateway()); err != nil && ctx.Err() == nil {
+				ws.logger.Printf("ERROR: event stream: %v", err)
+			}
+			select {
+			case <-time.After(EventRetrySec * time.Second):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (ws *WalletServer) listenEvents(ctx context.Context, gateway string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gateway+"/events", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	kind := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			kind = strings.TrimPrefix(line, "event: ")
+			continue
+		}
+		// Pool transactions change no confirmed balance
+		if !strings.HasPrefix(line, "data: ") || kind == "transaction" {
+			continue
+		}
+		var e struct {
+			Addresses []string `json:"addresses"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+			ws.logger.Printf("ERROR: event stream: %v", err)
+			continue
+		}
+		// Every block adds a confirmation, which can make coins of any address spendable
+		if ws.confirmations > 1 {
+			ws.balances.clear()
+			continue
+		}
+		ws.balances.invalidate(e.Addresses)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SetChainID sets the ID of the network transactions are signed for. By default it is the chain_id
+// the gateway reports on /version.
+func (ws *WalletServer) SetChainID(chainID string) {
+	ws.muxChainID.Lock()
+	defer ws.muxChainID.Unlock()
+	ws.chainID = chainID
+}
+
+// ChainID returns the ID of the network transactions are signed for, asking the gateway the first time
+// when it was not set. It reports false when the gateway does not answer.
+func (ws *WalletServer) ChainID() (string, bool) {
+	ws.muxChainID.Lock()
+	defer ws.muxChainID.Unlock()
+	if ws.chainID != "" {
+		return ws.chainID, true
+	}
+	resp, err := http.Get(fmt.Sprintf("%s/version", ws.Gateway()))
+	if err != nil {
+		ws.logger.Printf("ERROR: %v", err)
+		return "", false
+	}
+	defer resp.Body.Close()
+	var v struct {
+		ChainID string `json:"chain_id"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&v) != nil || v.ChainID == "" {
+		ws.logger.Printf("ERROR: no chain id from the gateway %s", ws.Gateway())
+		return "", false
+	}
+	ws.chainID = v.ChainID
+	ws.logger.Printf("Signing for chain %s", ws.chainID)
+	return ws.chainID, true
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/address"
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/signer"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+const (
+	escrowStatusCreated  = "created"
+	escrowStatusAccepted = "accepted"
+	escrowStatusReleased = "released"
+	escrowStatusRefunded = "refunded"
+
+	escrowActionRelease = "release"
+	escrowActionRefund  = "refund"
+)
+
+// escrow is the wallet server record of an escrow and the signatures collected for its release or refund
+type escrow struct {
+	Address                 string  `json:"escrow_address"`
+	BuyerBlockchainAddress  string  `json:"buyer_blockchain_address"`
+	SellerBlockchainAddress string  `json:"seller_blockchain_address"`
+	BuyerPublicKey          string  `json:"buyer_public_key"`
+	SellerPublicKey         string  `json:"seller_public_key"`
+	ArbiterPublicKey        string  `json:"arbiter_public_key"`
+	Value                   float32 `json:"value"`
+	Status                  string  `json:"status"`
+
+	// signatures is action -> signer public key -> signature
+	signatures map[string]map[string]string
+}
+
+func (e *escrow) isParticipant(publicKey string) bool {
+	return publicKey == e.BuyerPublicKey || publicKey == e.SellerPublicKey || publicKey == e.ArbiterPublicKey
+}
+
+type escrowStore struct {
+	escrows map[string]*escrow
+	mux     sync.Mutex
+}
+
+func newEscrowStore() *escrowStore {
+	return &escrowStore{escrows: make(map[string]*escrow)}
+}
+
+// Escrow is handler function that creates (POST) or returns (GET) an escrow
+func (ws *WalletServer) Escrow(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ws.escrows.mux.Lock()
+		defer ws.escrows.mux.Unlock()
+		w.Header().Add("Content-Type", "application/json")
+		e, ok := ws.escrows.escrows[r.URL.Query().Get("escrow_address")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(e)
+		io.WriteString(w, string(m))
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var er wallet.EscrowRequest
+		if err := decoder.Decode(&er); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if !er.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		value, err := strconv.ParseFloat(*er.Value, 32)
+		if err != nil {
+			ws.logger.Println("ERROR: parse error")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		be, ok := block.EscrowFromStrings([]string{*er.BuyerPublicKey, *er.SellerPublicKey, *er.ArbiterPublicKey})
+		if !ok {
+			ws.logger.Println("ERROR: invalid or repeated public key(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+		e := &escrow{
+			Address:                 be.Address(),
+			BuyerBlockchainAddress:  *er.BuyerBlockchainAddress,
+			SellerBlockchainAddress: *er.SellerBlockchainAddress,
+			BuyerPublicKey:          *er.BuyerPublicKey,
+			SellerPublicKey:         *er.SellerPublicKey,
+			ArbiterPublicKey:        *er.ArbiterPublicKey,
+			Value:                   float32(value),
+			Status:                  escrowStatusCreated,
+			signatures:              make(map[string]map[string]string),
+		}
+		ws.escrows.mux.Lock()
+		ws.escrows.escrows[e.Address] = e
+		ws.escrows.mux.Unlock()
+
+		m, _ := json.Marshal(e)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(m))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// acceptance is the escrow transaction the seller signs to accept an escrow. It has no value, so its
+// signature can not release the escrowed funds.
+func (e *escrow) acceptance() signer.Transaction {
+	return signer.Transaction{
+		SenderBlockchainAddress:    e.Address,
+		RecipientBlockchainAddress: e.SellerBlockchainAddress,
+	}
+}
+
+// AcceptEscrow is handler function that lets the seller accept an escrow by signing its acceptance
+func (ws *WalletServer) AcceptEscrow(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var ear wallet.EscrowActionRequest
+		if err := decoder.Decode(&ear); err != nil || !ear.Validate() || !ws.keyAllowed(ear.SignerPrivateKey) {
+			ws.logger.Println("ERROR: invalid escrow request")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		ws.escrows.mux.Lock()
+		defer ws.escrows.mux.Unlock()
+		w.Header().Add("Content-Type", "application/json")
+		e, ok := ws.escrows.escrows[*ear.EscrowAddress]
+		if !ok || e.Status != escrowStatusCreated || e.SellerPublicKey != *ear.SignerPublicKey {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+		publicKey := utils.PublicKeyFromString(e.SellerPublicKey)
+		var privateKey *ecdsa.PrivateKey
+		if ear.SignerPrivateKey != nil {
+			privateKey = utils.PrivateKeyFromString(*ear.SignerPrivateKey, publicKey)
+		}
+		acceptance := e.acceptance()
+		if !ws.confirmed(w, acceptance.Value, ear.ConfirmationCode) {
+			return
+		}
+		signatureStr, err := ws.sign(r.Context(), address.FromPublicKey(publicKey), privateKey, acceptance)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		// A private key not matching the seller's public key makes a signature that does not verify
+		signature, err := utils.ParseSignature(signatureStr)
+		chainID, _ := ws.ChainID()
+		t := wallet.NewTransaction(nil, publicKey, acceptance.SenderBlockchainAddress, acceptance.RecipientBlockchainAddress, acceptance.Value, acceptance.LockUntil)
+		t.SetChainID(chainID)
+		if err != nil || !t.VerifySignature(publicKey, signature) {
+			ws.logger.Println("ERROR: escrow acceptance is not signed by the seller")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		e.Status = escrowStatusAccepted
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// ReleaseEscrow is handler function that signs the release of escrowed funds to the seller
+func (ws *WalletServer) ReleaseEscrow(w http.ResponseWriter, r *http.Request) {
+	ws.signEscrow(w, r, escrowActionRelease)
+}
+
+// RefundEscrow is handler function that signs the refund of escrowed funds to the buyer
+func (ws *WalletServer) RefundEscrow(w http.ResponseWriter, r *http.Request) {
+	ws.signEscrow(w, r, escrowActionRefund)
+}
+
+// signEscrow records the signature of a participant for the action and submits the
+// escrow transaction to the gateway once enough participants have signed.
+func (ws *WalletServer) signEscrow(w http.ResponseWriter, r *http.Request, action string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		return
+	}
+	decoder := json.NewDecoder(r.Body)
+	var ear wallet.EscrowActionRequest
+	if err := decoder.Decode(&ear); err != nil || !ear.Validate() || !ws.keyAllowed(ear.SignerPrivateKey) {
+		ws.logger.Println("ERROR: invalid escrow request")
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+		return
+	}
+
+	ws.escrows.mux.Lock()
+	defer ws.escrows.mux.Unlock()
+	w.Header().Add("Content-Type", "application/json")
+	e, ok := ws.escrows.escrows[*ear.EscrowAddress]
+	if !ok || !e.isParticipant(*ear.SignerPublicKey) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+		return
+	}
+	recipient := e.SellerBlockchainAddress
+	status := escrowStatusReleased
+	if action == escrowActionRefund {
+		recipient = e.BuyerBlockchainAddress
+		status = escrowStatusRefunded
+	}
+	if (action == escrowActionRelease && e.Status != escrowStatusAccepted) ||
+		e.Status == escrowStatusReleased || e.Status == escrowStatusRefunded {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+		return
+	}
+
+	if !ws.confirmed(w, e.Value, ear.ConfirmationCode) {
+		return
+	}
+
+	publicKey := utils.PublicKeyFromString(*ear.SignerPublicKey)
+	var privateKey *ecdsa.PrivateKey
+	if ear.SignerPrivateKey != nil {
+		privateKey = utils.PrivateKeyFromString(*ear.SignerPrivateKey, publicKey)
+	}
+	signatureStr, err := ws.sign(r.Context(), address.FromPublicKey(publicKey), privateKey, signer.Transaction{
+		SenderBlockchainAddress:    e.Address,
+		RecipientBlockchainAddress: recipient,
+		Value:                      e.Value,
+	})
+	if err != nil {
+		ws.logger.Printf("ERROR: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+		return
+	}
+	if e.signatures[action] == nil {
+		e.signatures[action] = make(map[string]string)
+	}
+	e.signatures[action][*ear.SignerPublicKey] = signatureStr
+	if len(e.signatures[action]) < block.EscrowRequiredSignatures {
+		io.WriteString(w, string(utils.JsonStatus("pending")))
+		return
+	}
+
+	publicKeys := []string{e.BuyerPublicKey, e.SellerPublicKey, e.ArbiterPublicKey}
+	signatures := make([]string, 0, len(e.signatures[action]))
+	for _, s := range e.signatures[action] {
+		signatures = append(signatures, s)
+	}
+	bt := &block.TransactionRequest{
+		SenderBlockchainAddress:    &e.Address,
+		RecipientBlockchainAddress: &recipient,
+		Value:                      &e.Value,
+		EscrowPublicKeys:           &publicKeys,
+		EscrowSignatures:           &signatures,
+	}
+	if !ws.postTransaction(bt) {
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+		return
+	}
+	e.Status = status
+	io.WriteString(w, string(utils.JsonStatus("success")))
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+const (
+	// GatewayCheckSec is the interval of probing the known blockchain nodes
+	GatewayCheckSec = 10
+	// GatewayTimeoutSec is the timeout of a single probe
+	GatewayTimeoutSec = 3
+)
+
+// gatewayNode is a blockchain node the wallet server can route requests to
+type gatewayNode struct {
+	URL       string `json:"url"`
+	Healthy   bool   `json:"healthy"`
+	Syncing   bool   `json:"syncing"`
+	Height    int    `json:"height"`
+	LatencyMs int64  `json:"latency_ms"`
+	CheckedAt int64  `json:"checked_at,omitempty"`
+}
+
+// gatewayPool keeps the seed nodes and the peers they report, and picks the
+// healthy node with the highest chain, the fastest one among equals. Nodes still
+// syncing are only picked when every healthy node is syncing.
+type gatewayPool struct {
+	nodes  map[string]*gatewayNode
+	seeds  []string
+	best   string
+	client *http.Client
+	logger utils.Logger
+	mux    sync.Mutex
+}
+
+func newGatewayPool(seeds string) *gatewayPool {
+	p := &gatewayPool{
+		nodes:  make(map[string]*gatewayNode),
+		client: &http.Client{Timeout: GatewayTimeoutSec * time.Second},
+		logger: utils.StdLogger{},
+	}
+	for _, s := range strings.Split(seeds, ",") {
+		s = strings.TrimRight(strings.TrimSpace(s), "/")
+		if s == "" {
+			continue
+		}
+		p.seeds = append(p.seeds, s)
+		p.nodes[s] = &gatewayNode{URL: s}
+	}
+	if len(p.seeds) > 0 {
+		p.best = p.seeds[0]
+	}
+	return p
+}
+
+// current returns the URL of the best node, the first seed until the nodes were probed
+func (p *gatewayPool) current() string {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.best
+}
+
+// list returns the known nodes, best first
+func (p *gatewayPool) list() []gatewayNode {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	nodes := make([]gatewayNode, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		nodes = append(nodes, *n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return better(&nodes[i], &nodes[j])
+	})
+	return nodes
+}
+
+func better(a *gatewayNode, b *gatewayNode) bool {
+	if a.Healthy != b.Healthy {
+		return a.Healthy
+	}
+	if a.Syncing != b.Syncing {
+		return !a.Syncing
+	}
+	if a.Height != b.Height {
+		return a.Height > b.Height
+	}
+	if a.LatencyMs != b.LatencyMs {
+		return a.LatencyMs < b.LatencyMs
+	}
+	return a.URL < b.URL
+}
+
+// check probes every known node, adds the peers they report and picks the best node
+func (p *gatewayPool) check() {
+	p.mux.Lock()
+	urls := make([]string, 0, len(p.nodes))
+	for u := range p.nodes {
+		urls = append(urls, u)
+	}
+	p.mux.Unlock()
+
+	results := make([]gatewayNode, len(urls))
+	peers := make([][]string, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			results[i], peers[i] = p.probe(u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for i := range results {
+		*p.nodes[urls[i]] = results[i]
+	}
+	for _, ps := range peers {
+		for _, peer := range ps {
+			u := "http://" + peer
+			if _, ok := p.nodes[u]; !ok {
+				p.nodes[u] = &gatewayNode{URL: u}
+			}
+		}
+	}
+	var best *gatewayNode
+	for _, n := range p.nodes {
+		if n.Healthy && (best == nil || better(n, best)) {
+			best = n
+		}
+	}
+	if best != nil && best.URL != p.best {
+		p.logger.Printf("Gateway switched to %s at height %d", best.URL, best.Height)
+		p.best = best.URL
+	}
+}
+
+// probe asks a node for its height, whether it is syncing and its peers. Nodes without /sync are taken as synced.
+func (p *gatewayPool) probe(u string) (gatewayNode, []string) {
+	n := gatewayNode{URL: u, CheckedAt: time.Now().Unix()}
+	start := time.Now()
+	var stats struct {
+		Height int `json:"height"`
+	}
+	if !p.getJSON(u+"/stats", &stats) {
+		return n, nil
+	}
+	n.Healthy = true
+	n.Height = stats.Height
+	n.LatencyMs = time.Since(start).Milliseconds()
+
+	var status struct {
+		Syncing bool `json:"syncing"`
+	}
+	p.getJSON(u+"/sync", &status)
+	n.Syncing = status.Syncing
+
+	var peers struct {
+		Peers []string `json:"peers"`
+	}
+	p.getJSON(u+"/peers", &peers)
+	return n, peers.Peers
+}
+
+func (p *gatewayPool) getJSON(u string, v interface{}) bool {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	return json.NewDecoder(resp.Body).Decode(v) == nil
+}
+
+// StartGatewayMonitor probes the blockchain nodes now and every GatewayCheckSec until ctx is done
+func (ws *WalletServer) StartGatewayMonitor(ctx context.Context) {
+	every(ctx, time.Second*GatewayCheckSec, ws.gateways.check)
+}
+
+// Gateways is handler function that returns the known blockchain nodes, the one in use first
+func (ws *WalletServer) Gateways(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(struct {
+			Current string        `json:"current"`
+			Nodes   []gatewayNode `json:"nodes"`
+		}{
+			Current: ws.Gateway(),
+			Nodes:   ws.gateways.list(),
+		})
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
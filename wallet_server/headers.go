@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+)
+
+const (
+	// HeaderSyncSec is the interval of syncing block headers from the gateway
+	HeaderSyncSec = 10
+	// HeaderRollback is the number of headers dropped when the gateway no longer extends the synced ones,
+	// after a reorg, so they are synced again
+	HeaderRollback = 10
+)
+
+// headerChain is the chain of block headers the wallet server checked itself,
+// against which it verifies the TransactionProof of the gateway
+type headerChain struct {
+	headers []block.BlockHeader
+	mux     sync.Mutex
+}
+
+func newHeaderChain() *headerChain {
+	return &headerChain{}
+}
+
+// height returns the number of synced headers
+func (c *headerChain) height() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.headers)
+}
+
+// headerAt returns the synced header at height
+func (c *headerChain) headerAt(height int) (block.BlockHeader, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if height < 0 || height >= len(c.headers) {
+		return block.BlockHeader{}, false
+	}
+	return c.headers[height], true
+}
+
+// extend appends the headers from height from after checking them with block.VerifyHeaders.
+// Headers that do not link to the synced ones make it drop the last HeaderRollback headers.
+func (c *headerChain) extend(from int, headers []block.BlockHeader) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if from != len(c.headers) {
+		return fmt.Errorf("headers from %d do not follow %d synced headers", from, len(c.headers))
+	}
+	tip := ""
+	if len(c.headers) > 0 {
+		tip = c.headers[len(c.headers)-1].Hash
+	}
+	if err := block.VerifyHeaders(tip, headers); err != nil {
+		rollback := len(c.headers) - HeaderRollback
+		if rollback < 0 {
+			rollback = 0
+		}
+		c.headers = c.headers[:rollback]
+		return err
+	}
+	c.headers = append(c.headers, headers...)
+	return nil
+}
+
+// syncHeaders fetches the headers after the synced ones from the gateway
+func (ws *WalletServer) syncHeaders() error {
+	for {
+		from := ws.headers.height()
+		resp, err := http.Get(fmt.Sprintf("%s/headers?from=%d", ws.Gateway(), from))
+		if err != nil {
+			return err
+		}
+		var headers []block.BlockHeader
+		err = json.NewDecoder(resp.Body).Decode(&headers)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if len(headers) == 0 {
+			return nil
+		}
+		if err := ws.headers.extend(from, headers); err != nil {
+			return err
+		}
+		if len(headers) < block.MaxHeaders {
+			return nil
+		}
+	}
+}
+
+// StartHeaderSync syncs the block headers from the gateway now and every HeaderSyncSec until ctx is done
+func (ws *WalletServer) StartHeaderSync(ctx context.Context) {
+	every(ctx, time.Second*HeaderSyncSec, func() {
+		if err := ws.syncHeaders(); err != nil {
+			ws.logger.Printf("ERROR: header sync: %v", err)
+		}
+	})
+}
+
+// fetchTransactionProof returns the TransactionProof of the confirmed transaction reported by the gateway
+func (ws *WalletServer) fetchTransactionProof(txid string) (*block.TransactionProof, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/%s/proof", ws.Gateway(), txid))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transaction proof: %s", resp.Status)
+	}
+	var p block.TransactionProof
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// verifyTransaction reports whether the gateway proves that the transaction txid is in a block
+// of the synced headers, syncing them first when the block is newer
+func (ws *WalletServer) verifyTransaction(txid string) bool {
+	p, err := ws.fetchTransactionProof(txid)
+	if err != nil || p.TxID != txid {
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+		}
+		return false
+	}
+	if p.Height >= ws.headers.height() {
+		if err := ws.syncHeaders(); err != nil {
+			ws.logger.Printf("ERROR: header sync: %v", err)
+		}
+	}
+	h, ok := ws.headers.headerAt(p.Height)
+	return ok && p.VerifyHeader(h)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// WalletTransactions is handler function that returns the confirmed and pending transactions of
+// ?blockchain_address= reported by the gateway, for the history of the wallet
+func (ws *WalletServer) WalletTransactions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		blockchainAddress := r.URL.Query().Get("blockchain_address")
+		if blockchainAddress == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		resp, err := http.Get(fmt.Sprintf("%s/address/%s/transactions?pending=true", ws.Gateway(), url.PathEscape(blockchainAddress)))
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			w.WriteHeader(resp.StatusCode)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		io.Copy(w, resp.Body)
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+const (
+	// InvoiceCheckSec is the interval of checking open invoices for payments
+	InvoiceCheckSec = 10
+	// InvoiceDefaultExpirySec is the lifetime of invoices created without expiry_sec
+	InvoiceDefaultExpirySec = 3600
+
+	invoiceStatusOpen    = "open"
+	invoiceStatusPaid    = "paid"
+	invoiceStatusExpired = "expired"
+)
+
+// invoice is a payment request with its own receive address
+type invoice struct {
+	ID                string  `json:"id"`
+	BlockchainAddress string  `json:"blockchain_address"`
+	DerivationIndex   uint32  `json:"derivation_index"`
+	Value             float32 `json:"value"`
+	Memo              string  `json:"memo,omitempty"`
+	PaymentURI        string  `json:"payment_uri"`
+	CreatedAt         int64   `json:"created_at"`
+	ExpiresAt         int64   `json:"expires_at"`
+	PaidAt            int64   `json:"paid_at,omitempty"`
+	Status            string  `json:"status"`
+}
+
+type invoiceStore struct {
+	invoices map[string]*invoice
+	mux      sync.Mutex
+}
+
+func newInvoiceStore() *invoiceStore {
+	return &invoiceStore{invoices: make(map[string]*invoice)}
+}
+
+func (s *invoiceStore) get(id string) (*invoice, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	i, ok := s.invoices[id]
+	if !ok {
+		return nil, false
+	}
+	c := *i
+	return &c, true
+}
+
+// StartInvoiceWatcher marks open invoices paid or expired now and every InvoiceCheckSec until ctx is done
+func (ws *WalletServer) StartInvoiceWatcher(ctx context.Context) {
+	every(ctx, time.Second*InvoiceCheckSec, func() {
+		ws.checkInvoices(time.Now().Unix())
+	})
+}
+
+func (ws *WalletServer) checkInvoices(now int64) {
+	s := ws.invoices
+	s.mux.Lock()
+	open := make([]*invoice, 0)
+	for _, i := range s.invoices {
+		if i.Status == invoiceStatusOpen {
+			open = append(open, i)
+		}
+	}
+	s.mux.Unlock()
+
+	for _, i := range open {
+		amount, ok := ws.fetchAmount(i.BlockchainAddress)
+		s.mux.Lock()
+		switch {
+		case ok && amount >= i.Value:
+			i.Status = invoiceStatusPaid
+			i.PaidAt = now
+			ws.logger.Printf("invoice %s paid", i.ID)
+		case now > i.ExpiresAt:
+			i.Status = invoiceStatusExpired
+		}
+		s.mux.Unlock()
+	}
+}
+
+// Invoices is handler function that creates an invoice (POST /invoices) and returns its status (GET /invoices/{id})
+func (ws *WalletServer) Invoices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		i, ok := ws.invoices.get(strings.TrimPrefix(r.URL.Path, "/invoices/"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(i)
+		io.WriteString(w, string(m))
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var ir wallet.InvoiceRequest
+		if err := decoder.Decode(&ir); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if !ir.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		value, err := strconv.ParseFloat(*ir.Value, 32)
+		if err != nil || value <= 0 {
+			ws.logger.Println("ERROR: parse error")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var expirySec int64 = InvoiceDefaultExpirySec
+		if ir.ExpirySec != nil {
+			expirySec = *ir.ExpirySec
+		}
+		var memo string
+		if ir.Memo != nil {
+			memo = *ir.Memo
+		}
+
+		// Every invoice is paid to a fresh receive address so payments can not be confused
+		receiveWallet, index := ws.freshWallet()
+		now := time.Now().Unix()
+		i := &invoice{
+			ID:                newScheduleID(),
+			BlockchainAddress: receiveWallet.BlockchainAddress(),
+			DerivationIndex:   index,
+			Value:             float32(value),
+			Memo:              memo,
+			CreatedAt:         now,
+			ExpiresAt:         now + expirySec,
+			Status:            invoiceStatusOpen,
+		}
+		p := &wallet.PaymentURI{BlockchainAddress: i.BlockchainAddress, Amount: i.Value, Memo: i.Memo}
+		i.PaymentURI = p.String()
+		ws.invoices.mux.Lock()
+		ws.invoices.invoices[i.ID] = i
+		c := *i
+		ws.invoices.mux.Unlock()
+
+		m, _ := json.Marshal(struct {
+			*invoice
+			Wallet *wallet.Wallet `json:"wallet"`
+		}{
+			invoice: &c,
+			Wallet:  receiveWallet,
+		})
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(m))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
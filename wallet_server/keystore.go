@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/hirasawayuki/block_chain/address"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// SetKeystoreDir sets the directory wallets are saved to and loaded from, one keystore file per
+// blockchain address. It is created when it does not exist.
+func (ws *WalletServer) SetKeystoreDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	ws.keystoreDir = dir
+	return nil
+}
+
+// keystorePath returns the keystore file of blockchainAddress
+func (ws *WalletServer) keystorePath(blockchainAddress string) string {
+	return filepath.Join(ws.keystoreDir, blockchainAddress+".json")
+}
+
+// WalletImport is handler function that returns the wallet.Wallet data of an existing private key,
+// hex encoded or a PEM block
+func (ws *WalletServer) WalletImport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var ir wallet.ImportRequest
+		if err := json.NewDecoder(r.Body).Decode(&ir); err != nil || !ir.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		myWallet, err := wallet.ParsePrivateKey(*ir.PrivateKey)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := myWallet.MarshalJSON()
+		io.WriteString(w, string(m[:]))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// WalletMnemonic is handler function that returns the wallet.Wallet data restored from a recovery phrase
+func (ws *WalletServer) WalletMnemonic(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		var mr wallet.MnemonicRequest
+		if err := json.NewDecoder(r.Body).Decode(&mr); err != nil || !mr.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		passphrase := ""
+		if mr.Passphrase != nil {
+			passphrase = *mr.Passphrase
+		}
+		myWallet, err := wallet.NewWalletFromMnemonic(*mr.Mnemonic, passphrase)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := myWallet.MarshalJSON()
+		io.WriteString(w, string(m[:]))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// WalletSave is handler function that saves the wallet of a private key to the keystore directory,
+// encrypted with the passphrase, and returns its blockchain address
+func (ws *WalletServer) WalletSave(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		if ws.keystoreDir == "" {
+			ws.logger.Println("ERROR: no keystore directory")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var br wallet.BackupRequest
+		if err := json.NewDecoder(r.Body).Decode(&br); err != nil || !br.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		myWallet, err := wallet.ParsePrivateKey(*br.PrivateKey)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if err := myWallet.Save(ws.keystorePath(myWallet.BlockchainAddress()), *br.Passphrase); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Message           string `json:"message"`
+			BlockchainAddress string `json:"blockchain_address"`
+		}{
+			Message:           "success",
+			BlockchainAddress: myWallet.BlockchainAddress(),
+		})
+		io.WriteString(w, string(m))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// WalletLoad is handler function that returns the wallet.Wallet data of a blockchain address saved
+// to the keystore directory, decrypted with the passphrase
+func (ws *WalletServer) WalletLoad(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Add("Content-Type", "application/json")
+		if ws.keystoreDir == "" {
+			ws.logger.Println("ERROR: no keystore directory")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		var lr wallet.LoadRequest
+		if err := json.NewDecoder(r.Body).Decode(&lr); err != nil || !lr.Validate() {
+			ws.logger.Println("ERROR: missing field(s)")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		// The address names the file, so it must not be a path
+		if err := address.Validate(*lr.BlockchainAddress, address.MainNetVersion); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		myWallet, err := wallet.Load(ws.keystorePath(*lr.BlockchainAddress), *lr.Passphrase)
+		switch {
+		case os.IsNotExist(err):
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		case errors.Is(err, wallet.ErrWrongPassphrase):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		case err != nil:
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := myWallet.MarshalJSON()
+		io.WriteString(w, string(m[:]))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
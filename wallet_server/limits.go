@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// SpendPassphraseEnv is the default environment variable of the spending limit passphrase
+	SpendPassphraseEnv = "WALLET_SPEND_PASSPHRASE"
+	// TOTPSecretEnv is the default environment variable of the spending limit TOTP secret
+	TOTPSecretEnv = "WALLET_TOTP_SECRET"
+	// TOTPStepSec is the time step of the confirmation codes
+	TOTPStepSec = 30
+	// TOTPDigits is the number of digits of the confirmation codes
+	TOTPDigits = 6
+)
+
+// spendingLimit asks for a confirmation code for amounts above limit. The code is the
+// passphrase, or the current TOTP code (RFC 6238) of the secret shared with an authenticator app.
+type spendingLimit struct {
+	limit      float32
+	passphrase string
+	totpSecret []byte
+}
+
+// newSpendingLimit returns a spendingLimit; limit 0 disables it. totpSecret is base32 encoded.
+func newSpendingLimit(limit float32, passphrase string, totpSecret string) (*spendingLimit, error) {
+	l := &spendingLimit{limit: limit, passphrase: passphrase}
+	if totpSecret != "" {
+		s, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(strings.TrimRight(totpSecret, "=")))
+		if err != nil {
+			return nil, fmt.Errorf("totp secret: %w", err)
+		}
+		l.totpSecret = s
+	}
+	if limit > 0 && l.passphrase == "" && l.totpSecret == nil {
+		return nil, errors.New("a spending limit needs a passphrase or a totp secret")
+	}
+	return l, nil
+}
+
+// readSecret returns the secret of source, the variable NAME of the environment for "env:NAME", empty if it
+// is not set, or the first line of the file at PATH for "file:PATH", which only its owner may access.
+// A secret is never taken from the command line, where other users see it.
+func readSecret(source string) (string, error) {
+	switch {
+	case strings.HasPrefix(source, "env:"):
+		name := strings.TrimPrefix(source, "env:")
+		secret := os.Getenv(name)
+		os.Unsetenv(name)
+		return secret, nil
+	case strings.HasPrefix(source, "file:"):
+		path := strings.TrimPrefix(source, "file:")
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode().Perm()&0077 != 0 {
+			return "", fmt.Errorf("%s is accessible to other users (mode %04o), it must be 0600", path, fi.Mode().Perm())
+		}
+		m, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(strings.SplitN(string(m), "\n", 2)[0], "\r"), nil
+	default:
+		return "", fmt.Errorf("invalid secret source, must be env:NAME or file:PATH")
+	}
+}
+
+// allows reports whether amount can be sent with the confirmation code at time now
+func (l *spendingLimit) allows(amount float32, code string, now time.Time) bool {
+	if l == nil || l.limit <= 0 || amount <= l.limit {
+		return true
+	}
+	if code == "" {
+		return false
+	}
+	if l.passphrase != "" && subtle.ConstantTimeCompare([]byte(code), []byte(l.passphrase)) == 1 {
+		return true
+	}
+	if l.totpSecret != nil {
+		// Accept the previous and the next step too, for clock skew and typing time
+		counter := now.Unix() / TOTPStepSec
+		for c := counter - 1; c <= counter+1; c++ {
+			if subtle.ConstantTimeCompare([]byte(code), []byte(totp(l.totpSecret, c))) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// totp returns the HOTP code (RFC 4226) of secret for counter
+func totp(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, code%1000000)
+}
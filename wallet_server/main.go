@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
 func init() {
@@ -10,10 +15,50 @@ func init() {
 }
 
 func main() {
-	port := flag.Uint("port", 8080, "TCP Number for Wallet Server")
-	gateway := flag.String("gateway", "http://127.0.0.1:5001", "Blockchain Gateway")
+	port := flag.Uint("port", 8080, "TCP Number for Wallet Server (0: pick a free port)")
+	gateway := flag.String("gateway", "http://127.0.0.1:5001", "Blockchain Gateway, or comma separated seed nodes to discover the others from")
+	schedules := flag.String("schedules", "schedules.json", "File of recurring payment schedules")
+	keychain := flag.String("keychain", "keychain.json", "File of the master seed receive addresses are derived from")
+	confirmations := flag.Int("confirmations", 1, "Blocks, counting its own, a payment needs to be reported as spendable")
+	spendLimit := flag.Float64("spend-limit", 0, "Amount above which sends need a confirmation code (0: no limit)")
+	spendPassphrase := flag.String("spend-passphrase", "env:"+SpendPassphraseEnv, "Where the passphrase accepted as confirmation code above the spending limit is read from, env:NAME or file:PATH of a 0600 file")
+	totpSecret := flag.String("totp-secret", "env:"+TOTPSecretEnv, "Where the base32 TOTP secret whose codes are accepted as confirmation code above the spending limit is read from, env:NAME or file:PATH of a 0600 file")
+	currency := flag.String("currency", "", "Fiat currency to show balances and amounts in (empty: coin units only)")
+	price := flag.Float64("price", 0, "Static price of one coin in -currency")
+	signerSocket := flag.String("signer", "", "Unix socket of the signer daemon holding the keys of schedules, names and escrow approvals (default: keys sent with the requests)")
+	chainID := flag.String("chain-id", "", "ID of the network transactions are signed for (default: the chain_id of the gateway)")
+	keystoreDir := flag.String("keystore-dir", "", "Directory wallets are saved to and loaded from as encrypted keystore files (default: disabled)")
 	flag.Parse()
 
-	app := NewWalletServer(uint16(*port), string(*gateway))
-	app.Run()
+	passphrase, err := readSecret(*spendPassphrase)
+	if err != nil {
+		log.Fatalf("-spend-passphrase: %v", err)
+	}
+	secret, err := readSecret(*totpSecret)
+	if err != nil {
+		log.Fatalf("-totp-secret: %v", err)
+	}
+	limit, err := newSpendingLimit(float32(*spendLimit), passphrase, secret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	app := NewWalletServer(uint16(*port), string(*gateway), *schedules, *keychain, *confirmations, limit)
+	app.SetChainID(*chainID)
+	if *keystoreDir != "" {
+		if err := app.SetKeystoreDir(*keystoreDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *signerSocket != "" {
+		app.SetSigner(*signerSocket)
+	}
+	if *currency != "" {
+		app.SetPriceProvider(StaticPriceProvider{strings.ToUpper(*currency): *price}, *currency)
+	}
+	// Stop on Ctrl-C or SIGTERM, finishing the requests being served
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hirasawayuki/block_chain/address"
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/signer"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// namePrefix marks a recipient as a registered name instead of a raw blockchain address
+const namePrefix = "@"
+
+// resolveRecipient returns the blockchain address for recipient, looking up "@name" recipients on the gateway
+// and validating plain addresses
+func (ws *WalletServer) resolveRecipient(recipient string) (string, bool) {
+	if !strings.HasPrefix(recipient, namePrefix) {
+		if err := address.Validate(recipient); err != nil {
+			ws.logger.Printf("ERROR: recipient %s: %v", recipient, err)
+			return "", false
+		}
+		return recipient, true
+	}
+	name := strings.TrimPrefix(recipient, namePrefix)
+	resp, err := http.Get(fmt.Sprintf("%s/names/%s", ws.Gateway(), url.PathEscape(name)))
+	if err != nil {
+		ws.logger.Printf("ERROR: %v", err)
+		return "", false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		ws.logger.Printf("ERROR: name %s is not registered", name)
+		return "", false
+	}
+	var nr struct {
+		BlockchainAddress string `json:"blockchain_address"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&nr); err != nil {
+		ws.logger.Printf("ERROR: %v", err)
+		return "", false
+	}
+	return nr.BlockchainAddress, true
+}
+
+// RegisterName is handler function that registers a name to the sender blockchain address
+func (ws *WalletServer) RegisterName(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var nr wallet.NameRequest
+		if err := decoder.Decode(&nr); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if !nr.Validate() || !block.ValidName(*nr.Name) || !ws.keyAllowed(nr.SenderPrivateKey) {
+			ws.logger.Println("ERROR: missing or invalid field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+		var fee float32 = block.NameRegistrationFee
+		if !ws.confirmed(w, fee, nr.ConfirmationCode) {
+			return
+		}
+
+		var privateKey *ecdsa.PrivateKey
+		if nr.SenderPrivateKey != nil {
+			privateKey = utils.PrivateKeyFromString(*nr.SenderPrivateKey, utils.PublicKeyFromString(*nr.SenderPublicKey))
+		}
+		recipient := block.BurnAddress
+		signatureStr, err := ws.sign(r.Context(), *nr.SenderBlockchainAddress, privateKey, signer.Transaction{
+			SenderBlockchainAddress:    *nr.SenderBlockchainAddress,
+			RecipientBlockchainAddress: recipient,
+			Value:                      fee,
+			Name:                       *nr.Name,
+		})
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+		bt := &block.TransactionRequest{
+			SenderBlockchainAddress:    nr.SenderBlockchainAddress,
+			RecipientBlockchainAddress: &recipient,
+			SenderPublicKey:            nr.SenderPublicKey,
+			Value:                      &fee,
+			Name:                       nr.Name,
+			Signature:                  &signatureStr,
+		}
+		if !ws.postTransaction(bt) {
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"path"
+
+	"github.com/hirasawayuki/block_chain/wallet"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// paperWallet is the content of a printed paper wallet
+type paperWallet struct {
+	BlockchainAddress string
+	PublicKey         string
+	PrivateKey        string
+	AddressQR         template.URL
+	PrivateKeyQR      template.URL
+}
+
+// qrDataURI returns the QR code of s as a PNG data URI, so the page needs no further requests
+func qrDataURI(s string) (template.URL, error) {
+	png, err := qrcode.Encode(s, qrcode.High, 256)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)), nil
+}
+
+// PaperWallet is handler function that renders a printable paper wallet of the private_key form value.
+// The key is posted rather than put in the URL to keep it out of logs and the browser history.
+func (ws *WalletServer) PaperWallet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		myWallet, err := wallet.NewWalletFromPrivateKeyStr(r.PostFormValue("private_key"))
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p := paperWallet{
+			BlockchainAddress: myWallet.BlockchainAddress(),
+			PublicKey:         myWallet.PublicKeyStr(),
+			PrivateKey:        myWallet.PrivateKeyStr(),
+		}
+		if p.AddressQR, err = qrDataURI(p.BlockchainAddress); err == nil {
+			p.PrivateKeyQR, err = qrDataURI(p.PrivateKey)
+		}
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		t, err := template.ParseFiles(path.Join(tempDir, "paper_wallet.html"))
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Cache-Control", "no-store")
+		t.Execute(w, p)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// paymentURIFromQuery returns the PaymentURI described by the blockchain_address, amount and memo query parameters
+func paymentURIFromQuery(r *http.Request) (*wallet.PaymentURI, bool) {
+	q := r.URL.Query()
+	p := &wallet.PaymentURI{
+		BlockchainAddress: q.Get("blockchain_address"),
+		Memo:              q.Get("memo"),
+	}
+	if p.BlockchainAddress == "" {
+		return nil, false
+	}
+	if a := q.Get("amount"); a != "" {
+		amount, err := strconv.ParseFloat(a, 32)
+		if err != nil {
+			return nil, false
+		}
+		p.Amount = float32(amount)
+	}
+	return p, true
+}
+
+// PaymentURI is handler function that generates a payment URI
+func (ws *WalletServer) PaymentURI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		p, ok := paymentURIFromQuery(r)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			URI string `json:"uri"`
+		}{
+			URI: p.String(),
+		})
+		io.WriteString(w, string(m))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// PaymentURIQR is handler function that responses the payment URI as a PNG QR code
+func (ws *WalletServer) PaymentURIQR(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		p, ok := paymentURIFromQuery(r)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		png, err := qrcode.Encode(p.String(), qrcode.Medium, 256)
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "image/png")
+		w.Write(png)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// ParsePaymentURI is handler function that parses the uri query parameter into its fields
+func (ws *WalletServer) ParsePaymentURI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		p, err := wallet.ParsePaymentURI(r.URL.Query().Get("uri"))
+		if err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(p)
+		io.WriteString(w, string(m))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
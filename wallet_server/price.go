@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// ErrUnknownCurrency is returned by a PriceProvider without a price in the currency
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// PriceProvider is a source of the price of one coin in fiat currencies
+type PriceProvider interface {
+	Price(currency string) (float64, error)
+}
+
+// StaticPriceProvider is a PriceProvider with fixed prices, keyed by currency code
+type StaticPriceProvider map[string]float64
+
+// Price returns the fixed price of one coin in currency
+func (p StaticPriceProvider) Price(currency string) (float64, error) {
+	price, ok := p[strings.ToUpper(currency)]
+	if !ok {
+		return 0, ErrUnknownCurrency
+	}
+	return price, nil
+}
+
+// SetPriceProvider sets the source of the price balances and amounts are shown with in currency
+func (ws *WalletServer) SetPriceProvider(p PriceProvider, currency string) {
+	ws.prices = p
+	ws.currency = strings.ToUpper(currency)
+}
+
+// price returns the price of one coin in the configured currency, false without a price provider
+func (ws *WalletServer) price() (float64, bool) {
+	if ws.prices == nil {
+		return 0, false
+	}
+	price, err := ws.prices.Price(ws.currency)
+	if err != nil {
+		ws.logger.Printf("ERROR: price of %s: %v", ws.currency, err)
+		return 0, false
+	}
+	return price, true
+}
+
+// Price is handler function that returns the price of one coin in the configured currency
+func (ws *WalletServer) Price(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		price, ok := ws.price()
+		if !ok {
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Message  string  `json:"message"`
+			Currency string  `json:"currency"`
+			Price    float64 `json:"price"`
+		}{
+			Message:  "success",
+			Currency: ws.currency,
+			Price:    price,
+		})
+		io.WriteString(w, string(m))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
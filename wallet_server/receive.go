@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// receiveAddress is an address derived from the keychain and handed out for a payment
+type receiveAddress struct {
+	Index             uint32 `json:"index"`
+	BlockchainAddress string `json:"blockchain_address"`
+	PublicKey         string `json:"public_key"`
+}
+
+// keychain hands out fresh receive addresses derived from a master seed. The seed and the
+// next derivation index are persisted in file, so addresses are never handed out twice.
+type keychain struct {
+	file      string
+	hd        *wallet.HDWallet
+	nextIndex uint32
+	logger    utils.Logger
+	mux       sync.Mutex
+}
+
+type keychainFile struct {
+	Seed      string `json:"seed"`
+	NextIndex uint32 `json:"next_index"`
+}
+
+// newKeychain returns the keychain persisted in file, creating a new seed when there is none
+func newKeychain(file string) *keychain {
+	k := &keychain{file: file, logger: utils.StdLogger{}}
+	m, err := ioutil.ReadFile(file)
+	if err == nil {
+		var kf keychainFile
+		if err := json.Unmarshal(m, &kf); err != nil {
+			log.Fatalf("ERROR: keychain %s: %v", file, err)
+		}
+		seed, err := hex.DecodeString(kf.Seed)
+		if err != nil || len(seed) == 0 {
+			log.Fatalf("ERROR: keychain %s: invalid seed", file)
+		}
+		k.hd = wallet.NewHDWallet(seed)
+		k.nextIndex = kf.NextIndex
+		return k
+	}
+	if !os.IsNotExist(err) {
+		log.Fatalf("ERROR: keychain %s: %v", file, err)
+	}
+	k.hd, err = wallet.NewRandomHDWallet()
+	if err != nil {
+		log.Fatalf("ERROR: keychain: %v", err)
+	}
+	k.save()
+	return k
+}
+
+// save writes the seed and the next index to the file. The caller must hold mux unless k is not shared yet.
+func (k *keychain) save() {
+	m, _ := json.MarshalIndent(keychainFile{Seed: hex.EncodeToString(k.hd.Seed()), NextIndex: k.nextIndex}, "", "  ")
+	if err := ioutil.WriteFile(k.file, m, 0600); err != nil {
+		k.logger.Printf("ERROR: %v", err)
+	}
+}
+
+// next derives the wallet at the next index and advances the index
+func (k *keychain) next() (*wallet.Wallet, uint32) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	index := k.nextIndex
+	k.nextIndex++
+	k.save()
+	return k.hd.Derive(index), index
+}
+
+// issued returns the addresses handed out so far
+func (k *keychain) issued() []*receiveAddress {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	addresses := make([]*receiveAddress, 0, k.nextIndex)
+	for i := uint32(0); i < k.nextIndex; i++ {
+		w := k.hd.Derive(i)
+		addresses = append(addresses, &receiveAddress{i, w.BlockchainAddress(), w.PublicKeyStr()})
+	}
+	return addresses
+}
+
+// freshWallet returns the next derived wallet that has never received coins. Addresses
+// with a balance, e.g. from a restored seed, are skipped.
+func (ws *WalletServer) freshWallet() (*wallet.Wallet, uint32) {
+	for {
+		w, index := ws.keychain.next()
+		if amount, ok := ws.fetchAmount(w.BlockchainAddress()); ok && amount != 0 {
+			continue
+		}
+		return w, index
+	}
+}
+
+// ReceiveAddress is handler function that hands out a fresh receive address (POST) or lists the issued ones (GET)
+func (ws *WalletServer) ReceiveAddress(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m, _ := json.Marshal(ws.keychain.issued())
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(m))
+	case http.MethodPost:
+		receiveWallet, index := ws.freshWallet()
+		m, _ := json.Marshal(&receiveAddress{index, receiveWallet.BlockchainAddress(), receiveWallet.PublicKeyStr()})
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(m))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, string(utils.JsonStatus("fail")))
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/signer"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// ScheduleCheckSec is the interval of checking schedules for due payments
+const ScheduleCheckSec = 5
+
+// schedule is a recurring payment. Private keys are never persisted; a schedule
+// is only paid while the sender wallet is unlocked, or its key is held by the signer.
+type schedule struct {
+	ID                         string  `json:"id"`
+	SenderBlockchainAddress    string  `json:"sender_blockchain_address"`
+	SenderPublicKey            string  `json:"sender_public_key"`
+	RecipientBlockchainAddress string  `json:"recipient_blockchain_address"`
+	Value                      float32 `json:"value"`
+	IntervalSec                int64   `json:"interval_sec"`
+	NextRun                    int64   `json:"next_run"`
+	Unlocked                   bool    `json:"unlocked"`
+}
+
+type scheduler struct {
+	file      string
+	schedules map[string]*schedule
+	keys      map[string]*ecdsa.PrivateKey
+	logger    utils.Logger
+	mux       sync.Mutex
+}
+
+// newScheduler returns a scheduler with the schedules persisted in file
+func newScheduler(file string) *scheduler {
+	s := &scheduler{
+		file:      file,
+		schedules: make(map[string]*schedule),
+		keys:      make(map[string]*ecdsa.PrivateKey),
+		logger:    utils.StdLogger{},
+	}
+	m, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Printf("ERROR: %v", err)
+		}
+		return s
+	}
+	if err := json.Unmarshal(m, &s.schedules); err != nil {
+		s.logger.Printf("ERROR: %v", err)
+	}
+	return s
+}
+
+// save writes the schedules to the file. The caller must hold mux.
+func (s *scheduler) save() {
+	m, _ := json.MarshalIndent(s.schedules, "", "  ")
+	if err := ioutil.WriteFile(s.file, m, 0600); err != nil {
+		s.logger.Printf("ERROR: %v", err)
+	}
+}
+
+// list returns the schedules, unlocked when the scheduler or the signer holds the key of the sender
+func (s *scheduler) list(held map[string]bool) []*schedule {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	schedules := make([]*schedule, 0, len(s.schedules))
+	for _, sc := range s.schedules {
+		c := *sc
+		_, c.Unlocked = s.keys[sc.SenderBlockchainAddress]
+		c.Unlocked = c.Unlocked || held[sc.SenderBlockchainAddress]
+		schedules = append(schedules, &c)
+	}
+	return schedules
+}
+
+func newScheduleID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// StartScheduler pays due schedules of unlocked wallets now and every ScheduleCheckSec until ctx is done
+func (ws *WalletServer) StartScheduler(ctx context.Context) {
+	every(ctx, time.Second*ScheduleCheckSec, func() {
+		ws.runSchedules(ctx, time.Now().Unix())
+	})
+}
+
+func (ws *WalletServer) runSchedules(ctx context.Context, now int64) {
+	s := ws.scheduler
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	changed := false
+	for _, sc := range s.schedules {
+		privateKey, ok := s.keys[sc.SenderBlockchainAddress]
+		if (!ok && ws.signer == nil) || sc.NextRun > now {
+			continue
+		}
+		signatureStr, err := ws.sign(ctx, sc.SenderBlockchainAddress, privateKey, signer.Transaction{
+			SenderBlockchainAddress:    sc.SenderBlockchainAddress,
+			RecipientBlockchainAddress: sc.RecipientBlockchainAddress,
+			Value:                      sc.Value,
+		})
+		if err != nil {
+			// Paid once the signer holds the key
+			continue
+		}
+		bt := &block.TransactionRequest{
+			SenderBlockchainAddress:    &sc.SenderBlockchainAddress,
+			RecipientBlockchainAddress: &sc.RecipientBlockchainAddress,
+			SenderPublicKey:            &sc.SenderPublicKey,
+			Value:                      &sc.Value,
+			Signature:                  &signatureStr,
+		}
+		if !ws.postTransaction(bt) {
+			ws.logger.Printf("ERROR: scheduled payment %s failed", sc.ID)
+		}
+		sc.NextRun = now + sc.IntervalSec
+		changed = true
+	}
+	if changed {
+		s.save()
+	}
+}
+
+// Schedules is handler function that lists (GET), creates (POST) and cancels (DELETE) recurring payments
+func (ws *WalletServer) Schedules(w http.ResponseWriter, r *http.Request) {
+	s := ws.scheduler
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(struct {
+			Schedules []*schedule `json:"schedules"`
+		}{
+			Schedules: s.list(ws.signerKeys(r.Context())),
+		})
+		io.WriteString(w, string(m))
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var sr wallet.ScheduleRequest
+		if err := decoder.Decode(&sr); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if !sr.Validate() || !ws.keyAllowed(sr.SenderPrivateKey) {
+			ws.logger.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		value, err := strconv.ParseFloat(*sr.Value, 32)
+		if err != nil {
+			ws.logger.Println("ERROR: parse error")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !ws.confirmed(w, float32(value), sr.ConfirmationCode) {
+			return
+		}
+		recipient, ok := ws.resolveRecipient(*sr.RecipientBlockchainAddress)
+		if !ok {
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+		sc := &schedule{
+			ID:                         newScheduleID(),
+			SenderBlockchainAddress:    *sr.SenderBlockchainAddress,
+			SenderPublicKey:            *sr.SenderPublicKey,
+			RecipientBlockchainAddress: recipient,
+			Value:                      float32(value),
+			IntervalSec:                *sr.IntervalSec,
+			NextRun:                    time.Now().Unix() + *sr.IntervalSec,
+		}
+		s.mux.Lock()
+		s.schedules[sc.ID] = sc
+		if sr.SenderPrivateKey != nil {
+			s.keys[sc.SenderBlockchainAddress] = utils.PrivateKeyFromString(*sr.SenderPrivateKey, utils.PublicKeyFromString(*sr.SenderPublicKey))
+		}
+		s.save()
+		s.mux.Unlock()
+
+		m, _ := json.Marshal(sc)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(m))
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		w.Header().Add("Content-Type", "application/json")
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		if _, ok := s.schedules[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		delete(s.schedules, id)
+		s.save()
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
+
+// UnlockSchedules is handler function that holds the sender private key in memory (POST)
+// or forgets it (DELETE), enabling or pausing the sender's schedules. With a signer, the schedules
+// of the keys it holds are unlocked and keys are refused.
+func (ws *WalletServer) UnlockSchedules(w http.ResponseWriter, r *http.Request) {
+	s := ws.scheduler
+	switch r.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var ur wallet.UnlockRequest
+		w.Header().Add("Content-Type", "application/json")
+		if err := decoder.Decode(&ur); err != nil || !ur.Validate() || ws.signer != nil {
+			ws.logger.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		publicKey := utils.PublicKeyFromString(*ur.SenderPublicKey)
+		s.mux.Lock()
+		s.keys[*ur.SenderBlockchainAddress] = utils.PrivateKeyFromString(*ur.SenderPrivateKey, publicKey)
+		s.mux.Unlock()
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	case http.MethodDelete:
+		s.mux.Lock()
+		delete(s.keys, r.URL.Query().Get("blockchain_address"))
+		s.mux.Unlock()
+		w.Header().Add("Content-Type", "application/json")
+		io.WriteString(w, string(utils.JsonStatus("success")))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hirasawayuki/block_chain/signer"
+	"github.com/hirasawayuki/block_chain/utils"
+	"github.com/hirasawayuki/block_chain/wallet"
+)
+
+// errUnknownChainID is returned when a transaction can not be signed because the gateway did not tell the chain ID
+var errUnknownChainID = errors.New("unknown chain id")
+
+// SetSigner makes the server sign scheduled payments, names and escrow approvals with the signer daemon
+// listening on the unix socket at path. Requests then carry no private keys and those with one are refused,
+// and the routes generating, importing or exporting private keys are disabled.
+func (ws *WalletServer) SetSigner(path string) {
+	ws.signer = signer.NewClient(path)
+}
+
+// keyAllowed reports whether a request carries a private key exactly when the server signs without a signer
+func (ws *WalletServer) keyAllowed(privateKey *string) bool {
+	return (ws.signer == nil) == (privateKey != nil)
+}
+
+// withoutSigner wraps h, which generates or takes private keys in the server process, so that it is only
+// served when the server has no signer. With one, private keys never leave the signer daemon.
+func (ws *WalletServer) withoutSigner(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ws.signer != nil {
+			ws.logger.Printf("ERROR: %s %s handles private keys, which the signer holds", r.Method, r.URL.Path)
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// sign returns the signature of t for the chain of the gateway by the key of the blockchain address keyAddress,
+// made by the signer when the server has one and with privateKey otherwise
+func (ws *WalletServer) sign(ctx context.Context, keyAddress string, privateKey *ecdsa.PrivateKey, t signer.Transaction) (string, error) {
+	chainID, ok := ws.ChainID()
+	if !ok {
+		return "", errUnknownChainID
+	}
+	t.ChainID = chainID
+	if ws.signer == nil {
+		sig, err := signer.Sign(wallet.NewWalletFromPrivateKey(privateKey), t)
+		if err != nil {
+			return "", err
+		}
+		return sig.String(), nil
+	}
+	resp, err := ws.signer.Sign(ctx, keyAddress, t)
+	if err != nil {
+		return "", err
+	}
+	return resp.Signature, nil
+}
+
+// signerKeys returns the blockchain addresses whose keys the signer holds, none without a signer
+func (ws *WalletServer) signerKeys(ctx context.Context) map[string]bool {
+	held := make(map[string]bool)
+	if ws.signer == nil {
+		return held
+	}
+	keys, err := ws.signer.Keys(ctx)
+	if err != nil {
+		ws.logger.Printf("ERROR: signer: %v", err)
+		return held
+	}
+	for _, k := range keys {
+		held[k.BlockchainAddress] = true
+	}
+	return held
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/utils"
+)
+
+// transactionFailed is the status of a transaction sent by this wallet server that the gateway no longer knows,
+// because it was rejected or dropped from the pool
+const transactionFailed = "failed"
+
+// sentTransactions are the txids of the transactions this wallet server sent to the gateway
+type sentTransactions struct {
+	txids map[string]bool
+	mux   sync.Mutex
+}
+
+func newSentTransactions() *sentTransactions {
+	return &sentTransactions{txids: make(map[string]bool)}
+}
+
+func (s *sentTransactions) add(txid string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.txids[txid] = true
+}
+
+func (s *sentTransactions) contains(txid string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.txids[txid]
+}
+
+// fetchTransactionStatus returns the status of the transaction reported by the gateway
+func (ws *WalletServer) fetchTransactionStatus(txid string) (*block.TransactionStatus, int, bool) {
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/%s", ws.Gateway(), txid))
+	if err != nil {
+		ws.logger.Printf("ERROR: %v", err)
+		return nil, 0, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, false
+	}
+	var v struct {
+		TxID          string          `json:"txid"`
+		Status        string          `json:"status"`
+		Height        int             `json:"height"`
+		Confirmations int             `json:"confirmations"`
+		Transaction   json.RawMessage `json:"transaction"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		ws.logger.Printf("ERROR: %v", err)
+		return nil, resp.StatusCode, false
+	}
+	t, err := block.DecodeTransaction(v.Transaction)
+	if err != nil {
+		ws.logger.Printf("ERROR: %v", err)
+		return nil, resp.StatusCode, false
+	}
+	return &block.TransactionStatus{TxID: v.TxID, Status: v.Status, Height: v.Height, Confirmations: v.Confirmations, Transaction: t}, resp.StatusCode, true
+}
+
+// TransactionStatus is handler function that returns the status of /transaction/{txid}/status:
+// pending, confirmed with its confirmations, or failed for transactions sent here that the gateway does not know,
+// and whether the confirmation is verified with a merkle proof against the synced headers
+func (ws *WalletServer) TransactionStatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Add("Content-Type", "application/json")
+		p := strings.TrimPrefix(r.URL.Path, "/transaction/")
+		if !strings.HasSuffix(p, "/status") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		txid := strings.TrimSuffix(p, "/status")
+		if _, err := block.ParseTxID(txid); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		status, code, ok := ws.fetchTransactionStatus(txid)
+		if !ok {
+			if code == http.StatusNotFound && ws.sent.contains(txid) {
+				status = &block.TransactionStatus{TxID: txid, Status: transactionFailed}
+			} else {
+				if code == 0 {
+					code = http.StatusBadGateway
+				}
+				w.WriteHeader(code)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+		}
+		// A confirmed transaction is verified when the gateway proves it is in a block of the synced headers
+		m, _ := json.Marshal(struct {
+			*block.TransactionStatus
+			Verified bool `json:"verified"`
+		}{
+			TransactionStatus: status,
+			Verified:          status.Status == block.TransactionConfirmed && ws.verifyTransaction(txid),
+		})
+		io.WriteString(w, string(m))
+	default:
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
@@ -2,16 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"path"
 	"strconv"
+	"sync"
 	"text/template"
+	"time"
 
+	"github.com/hirasawayuki/block_chain/address"
 	"github.com/hirasawayuki/block_chain/block"
+	"github.com/hirasawayuki/block_chain/signer"
 	"github.com/hirasawayuki/block_chain/utils"
 	"github.com/hirasawayuki/block_chain/wallet"
 )
@@ -20,26 +24,63 @@ const tempDir = "wallet_server/templates/"
 
 // WalletServer is wallet server
 type WalletServer struct {
-	port    uint16
-	gateway string
+	port      uint16
+	gateways  *gatewayPool
+	escrows   *escrowStore
+	scheduler *scheduler
+	invoices  *invoiceStore
+	balances  *balanceCache
+	sent      *sentTransactions
+	headers   *headerChain
+	keychain  *keychain
+	signer    *signer.Client
+	chainID   string
+	limit     *spendingLimit
+	prices    PriceProvider
+	currency  string
+	logger    utils.Logger
+	// keystoreDir is the directory of the wallets saved with /wallet/save, "" when disabled
+	keystoreDir string
+	// confirmations is the number of blocks, counting its own, a payment needs to become spendable
+	confirmations int
+	muxChainID    sync.Mutex
 }
 
-// NewWalletServer is returns a WalletServer struct
-func NewWalletServer(port uint16, gateway string) *WalletServer {
+// NewWalletServer is returns a WalletServer struct.
+// gateway is a comma separated list of blockchain node URLs to start discovering nodes from.
+func NewWalletServer(port uint16, gateway string, schedulesFile string, keychainFile string, confirmations int, limit *spendingLimit) *WalletServer {
 	return &WalletServer{
-		port:    port,
-		gateway: gateway,
+		port:          port,
+		gateways:      newGatewayPool(gateway),
+		escrows:       newEscrowStore(),
+		scheduler:     newScheduler(schedulesFile),
+		invoices:      newInvoiceStore(),
+		balances:      newBalanceCache(),
+		sent:          newSentTransactions(),
+		headers:       newHeaderChain(),
+		keychain:      newKeychain(keychainFile),
+		limit:         limit,
+		confirmations: confirmations,
+		logger:        utils.StdLogger{},
 	}
 }
 
+// SetLogger sets where the server writes its logs. It must be called before Run.
+func (ws *WalletServer) SetLogger(l utils.Logger) {
+	ws.logger = l
+	ws.gateways.logger = l
+	ws.keychain.logger = l
+	ws.scheduler.logger = l
+}
+
 // Port is returns a WalletServer port
 func (ws *WalletServer) Port() uint16 {
 	return ws.port
 }
 
-// Gateway is returns a WalletServer gateway
+// Gateway is returns the URL of the healthiest, most up-to-date blockchain node known
 func (ws *WalletServer) Gateway() string {
-	return ws.gateway
+	return ws.gateways.current()
 }
 
 // Index is handler function that is response index.html
@@ -49,7 +90,7 @@ func (ws *WalletServer) Index(w http.ResponseWriter, r *http.Request) {
 		t, _ := template.ParseFiles(path.Join(tempDir, "index.html"))
 		t.Execute(w, "")
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
+		ws.logger.Println("ERROR: Invalid HTTP Method")
 	}
 }
 
@@ -59,122 +100,363 @@ func (ws *WalletServer) Wallet(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		w.Header().Add("Content-Type", "application/json")
 		myWallet := wallet.NewWallet()
+		// ?mnemonic=N derives the wallet from a new recovery phrase of N words
+		if words := r.URL.Query().Get("mnemonic"); words != "" {
+			n, err := strconv.Atoi(words)
+			if err == nil {
+				myWallet, err = wallet.NewMnemonicWallet(n, "")
+			}
+			if err != nil {
+				ws.logger.Printf("ERROR: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, string(utils.JsonStatus("fail")))
+				return
+			}
+		}
 		m, _ := myWallet.MarshalJSON()
 		io.WriteString(w, string(m[:]))
 	default:
 		w.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method")
+		ws.logger.Println("ERROR: Invalid HTTP Method")
 	}
 }
 
-// CreateTransaction is handler function that is create Transaction
-func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, r *http.Request) {
+// parseTransaction builds the unsigned transaction described by the request, resolving "@name" recipients
+func (ws *WalletServer) parseTransaction(t *wallet.TransactionRequest) (*wallet.Transaction, bool) {
+	if err := address.Validate(*t.SenderBlockchainAddress, address.MainNetVersion); err != nil {
+		ws.logger.Printf("ERROR: sender %s: %v", *t.SenderBlockchainAddress, err)
+		return nil, false
+	}
+	value, err := strconv.ParseFloat(*t.Value, 32)
+	if err != nil {
+		ws.logger.Println("ERROR: parse error")
+		return nil, false
+	}
+	recipient, ok := ws.resolveRecipient(*t.RecipientBlockchainAddress)
+	if !ok {
+		return nil, false
+	}
+	var lockUntil int64
+	if t.LockUntil != nil && *t.LockUntil != "" {
+		lockUntil, err = strconv.ParseInt(*t.LockUntil, 10, 64)
+		if err != nil {
+			ws.logger.Println("ERROR: parse error")
+			return nil, false
+		}
+	}
+	var fee float64
+	if t.Fee != nil && *t.Fee != "" {
+		fee, err = strconv.ParseFloat(*t.Fee, 32)
+		if err != nil || fee < 0 {
+			ws.logger.Println("ERROR: parse error")
+			return nil, false
+		}
+	}
+	var nonce uint64
+	if t.Nonce != nil && *t.Nonce != "" {
+		nonce, err = strconv.ParseUint(*t.Nonce, 10, 64)
+		if err != nil {
+			ws.logger.Println("ERROR: parse error")
+			return nil, false
+		}
+	}
+	chainID, ok := ws.ChainID()
+	if !ok {
+		return nil, false
+	}
+	transaction := wallet.NewTransaction(nil, nil, *t.SenderBlockchainAddress, recipient, float32(value), lockUntil)
+	transaction.SetFee(float32(fee), nonce)
+	transaction.SetChainID(chainID)
+	return transaction, true
+}
+
+// TransactionPayload is handler function that returns the payload the client signs to create a transaction
+func (ws *WalletServer) TransactionPayload(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
 		decoder := json.NewDecoder(r.Body)
 		var t wallet.TransactionRequest
 		if err := decoder.Decode(&t); err != nil {
-			log.Printf("ERROR: %v", err)
+			ws.logger.Printf("ERROR: %v", err)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
 		w.Header().Add("Content-Type", "application/json")
-		if !t.Validate() {
-			log.Println("ERROR: missing field(s)")
+		if !t.ValidatePayload() {
+			ws.logger.Println("ERROR: missing field(s)")
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
+		transaction, ok := ws.parseTransaction(&t)
+		if !ok {
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Message     string              `json:"message"`
+			Payload     string              `json:"payload"`
+			Transaction *wallet.Transaction `json:"transaction"`
+		}{
+			Message:     "success",
+			Payload:     string(transaction.SigningPayload()),
+			Transaction: transaction,
+		})
+		io.WriteString(w, string(m))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Println("ERROR: Invalid HTTP Method")
+	}
+}
 
+// CreateTransaction is handler function that is create Transaction signed by the client
+func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		var t wallet.TransactionRequest
+		if err := decoder.Decode(&t); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		if !t.Validate() || len(*t.SenderPublicKey) != 128 || len(*t.Signature) != 128 {
+			ws.logger.Println("ERROR: missing field(s)")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		transaction, ok := ws.parseTransaction(&t)
+		if !ok {
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+		if !ws.confirmed(w, transaction.Value()+transaction.Fee(), t.ConfirmationCode) {
+			return
+		}
 		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		privateKey := utils.PrivateKeyFromString(*t.SenderPrivateKey, publicKey)
-		value, err := strconv.ParseFloat(*t.Value, 32)
+		signature, err := utils.ParseSignature(*t.Signature)
 		if err != nil {
-			log.Println("ERROR: parse error")
+			ws.logger.Printf("ERROR: %v", err)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		value32 := float32(value)
-		transaction := wallet.NewTransaction(privateKey, publicKey, *t.SenderBlockchainAddress, *t.RecipientBlockchainAddress, value32)
-		signature := transaction.GenerateSignature()
-		signatureStr := signature.String()
-
-		bt := &block.TransactionRequest{
-			SenderBlockchainAddress:    t.SenderBlockchainAddress,
-			RecipientBlockchainAddress: t.RecipientBlockchainAddress,
-			SenderPublicKey:            t.SenderPublicKey,
-			Value:                      &value32,
-			Signature:                  &signatureStr,
-		}
-		m, _ := json.Marshal(bt)
-		buf := bytes.NewBuffer(m)
-		resp, err := http.Post(ws.Gateway()+"/transactions", "application/json", buf)
-		if err != nil {
-			log.Printf("ERROR %v\n", err)
+		if !transaction.VerifySignature(publicKey, signature) {
+			ws.logger.Println("ERROR: Verify Transaction")
+			io.WriteString(w, string(utils.JsonStatus("fail")))
+			return
+		}
+
+		// The signed payload has the field names of a block.TransactionRequest
+		var bt block.TransactionRequest
+		if err := json.Unmarshal(transaction.SigningPayload(), &bt); err != nil {
+			ws.logger.Printf("ERROR: %v", err)
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-		if resp.StatusCode == 201 {
-			log.Printf("Success")
+		if bt.Value == nil {
+			var zero float32
+			bt.Value = &zero
+		}
+		bt.SenderPublicKey = t.SenderPublicKey
+		bt.Signature = t.Signature
+		if ws.postTransaction(&bt) {
+			ws.logger.Printf("Success")
+			ws.sent.add(transaction.TxID())
+			m, _ := json.Marshal(struct {
+				Message string `json:"message"`
+				TxID    string `json:"txid"`
+			}{
+				Message: "succ",
+				TxID:    transaction.TxID(),
+			})
 			w.WriteHeader(http.StatusOK)
-			io.WriteString(w, string(utils.JsonStatus("succ")))
+			io.WriteString(w, string(m))
 			return
 		}
 		io.WriteString(w, string(utils.JsonStatus("fail")))
 	default:
 		w.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method (CreateTransaction)")
+		ws.logger.Println("ERROR: Invalid HTTP Method (CreateTransaction)")
+	}
+}
+
+func confirmationCode(code *string) string {
+	if code == nil {
+		return ""
+	}
+	return *code
+}
+
+// confirmed reports whether amount may be signed for with the confirmation code of the request, answering
+// confirmation_required otherwise. Every path signing with keys of the wallet checks the spending limit.
+func (ws *WalletServer) confirmed(w http.ResponseWriter, amount float32, code *string) bool {
+	if ws.limit.allows(amount, confirmationCode(code), time.Now()) {
+		return true
+	}
+	ws.logger.Println("ERROR: Confirmation required above the spending limit")
+	w.WriteHeader(http.StatusForbidden)
+	io.WriteString(w, string(utils.JsonStatus("confirmation_required")))
+	return false
+}
+
+// postTransaction sends a signed transaction to the gateway and reports whether it was created
+func (ws *WalletServer) postTransaction(bt *block.TransactionRequest) bool {
+	m, _ := json.Marshal(bt)
+	buf := bytes.NewBuffer(m)
+	resp, err := http.Post(ws.Gateway()+"/transactions", "application/json", buf)
+	if err != nil {
+		ws.logger.Printf("ERROR %v\n", err)
+		return false
 	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusCreated
+}
+
+// fetchAmount returns the confirmed balance of the blockchain address reported by the gateway
+func (ws *WalletServer) fetchAmount(blockchainAddress string) (float32, bool) {
+	b, ok := ws.fetchBalance(blockchainAddress)
+	return b.amount, ok
+}
+
+// fetchBalance returns the confirmed and the spendable balance of the blockchain address reported by the gateway,
+// cached for BalanceCacheTTLSec or until a block touching the address arrives
+func (ws *WalletServer) fetchBalance(blockchainAddress string) (cachedBalance, bool) {
+	if b, ok := ws.balances.get(blockchainAddress, time.Now()); ok {
+		return b, true
+	}
+	endpoint := fmt.Sprintf("%s/amount", ws.Gateway())
+	client := &http.Client{}
+	bcsReq, _ := http.NewRequest("GET", endpoint, nil)
+	q := bcsReq.URL.Query()
+	q.Add("blockchain_address", blockchainAddress)
+	q.Add("confirmations", strconv.Itoa(ws.confirmations))
+	bcsReq.URL.RawQuery = q.Encode()
+	bcsResp, err := client.Do(bcsReq)
+	if err != nil {
+		ws.logger.Printf("ERROR: %s\n", err)
+		return cachedBalance{}, false
+	}
+	defer bcsResp.Body.Close()
+	if bcsResp.StatusCode != 200 {
+		return cachedBalance{}, false
+	}
+	decoder := json.NewDecoder(bcsResp.Body)
+	var bar block.AmountResponse
+	if err := decoder.Decode(&bar); err != nil {
+		ws.logger.Printf("ERROR: %s\n", err)
+		return cachedBalance{}, false
+	}
+	spendable := bar.Amount
+	if bar.Spendable != nil {
+		spendable = *bar.Spendable
+	}
+	ws.balances.put(blockchainAddress, bar.Amount, spendable, time.Now())
+	return cachedBalance{amount: bar.Amount, spendable: spendable}, true
 }
 
 func (ws *WalletServer) WalletAmount(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		blockchainAddress := r.URL.Query().Get("blockchain_address")
-		endpoint := fmt.Sprintf("%s/amount", ws.Gateway())
-		client := &http.Client{}
-		bcsReq, _ := http.NewRequest("GET", endpoint, nil)
-		q := bcsReq.URL.Query()
-		q.Add("blockchain_address", blockchainAddress)
-		bcsReq.URL.RawQuery = q.Encode()
-		bcsResp, err := client.Do(bcsReq)
-		if err != nil {
-			log.Printf("ERROR: %s\n", err)
+		b, ok := ws.fetchBalance(blockchainAddress)
+		w.Header().Add("Content-Type", "application/json")
+		if !ok {
 			io.WriteString(w, string(utils.JsonStatus("fail")))
 			return
 		}
-
-		w.Header().Add("Content-Type", "application/json")
-		if bcsResp.StatusCode == 200 {
-			decoder := json.NewDecoder(bcsResp.Body)
-			var bar block.AmountResponse
-			err := decoder.Decode(&bar)
-			if err != nil {
-				log.Printf("ERROR: %s\n", err)
-				io.WriteString(w, string(utils.JsonStatus("fail")))
-				return
-			}
-			m, err := json.Marshal(struct {
-				Message string  `json:"message,omitempty"`
-				Amount  float32 `json:"amount,omitempty"`
-			}{
-				Message: "success",
-				Amount:  bar.Amount,
-			})
-			io.WriteString(w, string(m))
-		} else {
-			io.WriteString(w, string(utils.JsonStatus("fail")))
+		resp := struct {
+			Message       string  `json:"message,omitempty"`
+			Amount        float32 `json:"amount,omitempty"`
+			Spendable     float32 `json:"spendable"`
+			Confirmations int     `json:"confirmations"`
+			// The fiat values are only set with a price provider
+			Currency      string   `json:"currency,omitempty"`
+			FiatAmount    *float64 `json:"fiat_amount,omitempty"`
+			FiatSpendable *float64 `json:"fiat_spendable,omitempty"`
+		}{
+			Message:       "success",
+			Amount:        b.amount,
+			Spendable:     b.spendable,
+			Confirmations: ws.confirmations,
+		}
+		if price, ok := ws.price(); ok {
+			fiatAmount := float64(b.amount) * price
+			fiatSpendable := float64(b.spendable) * price
+			resp.Currency = ws.currency
+			resp.FiatAmount = &fiatAmount
+			resp.FiatSpendable = &fiatSpendable
 		}
+		m, _ := json.Marshal(resp)
+		io.WriteString(w, string(m))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
+		ws.logger.Println("ERROR: Invalid HTTP Method")
 		w.WriteHeader(http.StatusBadRequest)
 	}
 }
 
-// Run is start WalletServer
-func (ws *WalletServer) Run() {
-	http.HandleFunc("/", ws.Index)
-	http.HandleFunc("/wallet", ws.Wallet)
-	http.HandleFunc("/wallet/amount", ws.WalletAmount)
-	http.HandleFunc("/transaction", ws.CreateTransaction)
-	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(ws.Port())), nil))
+// every calls f now and then every interval in a new goroutine until ctx is done
+func every(ctx context.Context, interval time.Duration, f func()) {
+	f()
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Run is start WalletServer, serving until ctx is done. The background checks stop with ctx.
+func (ws *WalletServer) Run(ctx context.Context) error {
+	l, err := utils.Listen(ws.port)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	ws.port = utils.ListenPort(l)
+	ws.logger.Printf("Listening on port %d", ws.port)
+	ws.StartGatewayMonitor(ctx)
+	ws.StartHeaderSync(ctx)
+	ws.StartEventListener(ctx)
+	ws.StartScheduler(ctx)
+	ws.StartInvoiceWatcher(ctx)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ws.Index)
+	mux.HandleFunc("/wallet", ws.withoutSigner(ws.Wallet))
+	mux.HandleFunc("/wallet/amount", ws.WalletAmount)
+	mux.HandleFunc("/wallet/transactions", ws.WalletTransactions)
+	mux.HandleFunc("/wallet/backup", ws.withoutSigner(ws.WalletBackup))
+	mux.HandleFunc("/wallet/restore", ws.withoutSigner(ws.WalletRestore))
+	mux.HandleFunc("/wallet/backup/verify", ws.withoutSigner(ws.WalletBackupVerify))
+	mux.HandleFunc("/wallet/import", ws.withoutSigner(ws.WalletImport))
+	mux.HandleFunc("/wallet/mnemonic", ws.withoutSigner(ws.WalletMnemonic))
+	mux.HandleFunc("/wallet/save", ws.withoutSigner(ws.WalletSave))
+	mux.HandleFunc("/wallet/load", ws.withoutSigner(ws.WalletLoad))
+	mux.HandleFunc("/paper_wallet", ws.withoutSigner(ws.PaperWallet))
+	mux.HandleFunc("/transaction", ws.CreateTransaction)
+	mux.HandleFunc("/transaction/payload", ws.TransactionPayload)
+	mux.HandleFunc("/transaction/", ws.TransactionStatus)
+	mux.HandleFunc("/name", ws.RegisterName)
+	mux.HandleFunc("/payment_uri", ws.PaymentURI)
+	mux.HandleFunc("/payment_uri/qr", ws.PaymentURIQR)
+	mux.HandleFunc("/payment_uri/parse", ws.ParsePaymentURI)
+	mux.HandleFunc("/invoices", ws.Invoices)
+	mux.HandleFunc("/invoices/", ws.Invoices)
+	mux.HandleFunc("/schedules", ws.Schedules)
+	mux.HandleFunc("/schedules/unlock", ws.UnlockSchedules)
+	mux.HandleFunc("/escrow", ws.Escrow)
+	mux.HandleFunc("/escrow/accept", ws.AcceptEscrow)
+	mux.HandleFunc("/escrow/release", ws.ReleaseEscrow)
+	mux.HandleFunc("/escrow/refund", ws.RefundEscrow)
+	mux.HandleFunc("/gateways", ws.Gateways)
+	mux.HandleFunc("/receive_address", ws.ReceiveAddress)
+	mux.HandleFunc("/price", ws.Price)
+	err = utils.Serve(ctx, l, mux)
+	ws.logger.Println("Shut down")
+	return err
 }